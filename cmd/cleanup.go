@@ -0,0 +1,62 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"golang.org/x/xerrors"
+
+	"github.com/marco79423/nats-jetstream-test/config"
+	"github.com/marco79423/nats-jetstream-test/embedded"
+	"github.com/marco79423/nats-jetstream-test/tester"
+)
+
+// Cleanup 刪除名稱符合前綴的 Stream (之前的執行被強制結束或沒有啟用 teardown 時留下的資源)
+func Cleanup(args []string) error {
+	flagSet := newFlagSet("cleanup")
+	configPath := flagSet.String("config", config.DefaultConfigPath, "設定檔的位置")
+	env := flagSet.String("env", os.Getenv(config.EnvName), "環境的名稱 (會額外讀取 config.<env>.yml，預設為 NJT_ENV 環境變數)")
+	prefix := flagSet.String("prefix", "", "資源名稱的前綴 (會覆蓋設定檔的 isolation.prefix)")
+	dryRun := flagSet.Bool("dry-run", false, "只列出符合的 Stream，不實際刪除")
+	if err := flagSet.Parse(args); err != nil {
+		return xerrors.Errorf("解析參數失敗: %w", err)
+	}
+
+	conf, err := config.LoadConfigWithEnv(*configPath, *env)
+	if err != nil {
+		return xerrors.Errorf("取得設定檔失敗: %w", err)
+	}
+	if *prefix != "" {
+		conf.Isolation.Prefix = *prefix
+	}
+	// 不會執行 Tester，只需要檢查連線和資源前綴的設定
+	if err := conf.ValidateConnection(); err != nil {
+		return xerrors.Errorf("檢查設定失敗: %w", err)
+	}
+
+	// 內嵌的 Server 只有指定 store_dir 時才會留下資料
+	if conf.Embedded.Enabled {
+		if conf.Embedded.StoreDir == "" {
+			fmt.Println("內嵌的 Server 使用暫存資料夾，結束後資料已一併刪除，不需要清除")
+			return nil
+		}
+
+		servers, err := embedded.Start(conf)
+		if err != nil {
+			return xerrors.Errorf("啟動內嵌的 Server 失敗: %w", err)
+		}
+		defer servers.Shutdown()
+	}
+
+	streamNames, err := tester.CleanupLeftovers(conf, conf.IsolationPrefix(), *dryRun)
+	if err != nil {
+		return xerrors.Errorf("清除測試資源失敗: %w", err)
+	}
+
+	if *dryRun {
+		fmt.Printf("共 %d 個 Stream 符合前綴 %s_ (沒有刪除)\n", len(streamNames), conf.IsolationPrefix())
+	} else {
+		fmt.Printf("共刪除 %d 個 Stream (前綴 %s_)\n", len(streamNames), conf.IsolationPrefix())
+	}
+	return nil
+}
@@ -0,0 +1,87 @@
+package cmd
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"golang.org/x/xerrors"
+)
+
+const usage = `用法: nats-jetstream-test <指令> [參數]
+
+指令:
+  run     執行測試 (預設)
+  list    列出所有註冊的 Tester 和設定檔中的情境
+  compare 比較兩份 JSON 報告 (例如 compare baseline.json current.json)，有指標退步超過門檻時以非 0 結束
+  cleanup 刪除名稱符合前綴的 Stream (之前的執行留下的資源)
+
+執行 nats-jetstream-test <指令> -h 可查看該指令的參數
+`
+
+// Execute 依照命令列參數執行對應的指令
+func Execute(args []string) error {
+	command := "run"
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		command, args = args[0], args[1:]
+	}
+
+	var err error
+	switch command {
+	case "run":
+		err = Run(args)
+	case "list":
+		err = List(args)
+	case "compare":
+		err = Compare(args)
+	case "cleanup":
+		err = Cleanup(args)
+	case "help":
+		fmt.Print(usage)
+	default:
+		fmt.Fprint(os.Stderr, usage)
+		err = xerrors.Errorf("未知的指令: %s", command)
+	}
+
+	// 使用 -h 查看說明不算錯誤
+	if xerrors.Is(err, flag.ErrHelp) {
+		return nil
+	}
+	return err
+}
+
+// newFlagSet 建立指令用的 FlagSet
+func newFlagSet(command string) *flag.FlagSet {
+	flagSet := flag.NewFlagSet(command, flag.ContinueOnError)
+	flagSet.Usage = func() {
+		fmt.Fprintf(flagSet.Output(), "用法: nats-jetstream-test %s [參數]\n\n參數:\n", command)
+		flagSet.PrintDefaults()
+	}
+	return flagSet
+}
+
+// splitList 將逗號分隔的字串轉為列表 (會略過空白項目)
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// splitIntList 將逗號分隔的字串轉為整數列表
+func splitIntList(value string) ([]int, error) {
+	var numbers []int
+	for _, item := range splitList(value) {
+		number, err := strconv.Atoi(item)
+		if err != nil {
+			return nil, xerrors.Errorf("%s 不是合法的整數: %w", item, err)
+		}
+		numbers = append(numbers, number)
+	}
+	return numbers, nil
+}
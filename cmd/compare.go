@@ -0,0 +1,45 @@
+package cmd
+
+import (
+	"os"
+	"strings"
+
+	"golang.org/x/xerrors"
+
+	"github.com/marco79423/nats-jetstream-test/report"
+)
+
+// Compare 比較兩份 JSON 報告，有指標退步超過門檻時回傳錯誤 (程式會以非 0 結束)
+func Compare(args []string) error {
+	flagSet := newFlagSet("compare")
+	threshold := flagSet.Float64("threshold", 10, "判斷為退步的變化幅度 (百分比，吞吐量下降或延遲上升超過此值即為退步)")
+	if err := flagSet.Parse(args); err != nil {
+		return xerrors.Errorf("解析參數失敗: %w", err)
+	}
+	if flagSet.NArg() != 2 {
+		flagSet.Usage()
+		return xerrors.Errorf("需要指定基準報告和目前報告 (例如 compare baseline.json current.json)")
+	}
+	if *threshold < 0 {
+		return xerrors.Errorf("threshold 不可小於 0 (目前為 %v)", *threshold)
+	}
+
+	baseline, err := report.LoadJSONFile(flagSet.Arg(0))
+	if err != nil {
+		return xerrors.Errorf("讀取基準報告失敗: %w", err)
+	}
+	current, err := report.LoadJSONFile(flagSet.Arg(1))
+	if err != nil {
+		return xerrors.Errorf("讀取目前報告失敗: %w", err)
+	}
+
+	comparison := report.Compare(baseline, current, *threshold)
+	if err := report.WriteComparison(os.Stdout, comparison); err != nil {
+		return xerrors.Errorf("輸出比較結果失敗: %w", err)
+	}
+
+	if regressions := comparison.Regressions(); len(regressions) > 0 {
+		return xerrors.Errorf("%d 項退步 (門檻 %.2f%%):\n  - %s", len(regressions), *threshold, strings.Join(regressions, "\n  - "))
+	}
+	return nil
+}
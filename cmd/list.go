@@ -0,0 +1,34 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"golang.org/x/xerrors"
+
+	"github.com/marco79423/nats-jetstream-test/config"
+	"github.com/marco79423/nats-jetstream-test/tester"
+)
+
+// List 列出所有註冊的 Tester 和設定檔中的情境
+func List(args []string) error {
+	flagSet := newFlagSet("list")
+	configPath := flagSet.String("config", config.DefaultConfigPath, "設定檔的位置 (用來列出設定檔中的情境)")
+	env := flagSet.String("env", os.Getenv(config.EnvName), "環境的名稱 (會額外讀取 config.<env>.yml，預設為 NJT_ENV 環境變數)")
+	if err := flagSet.Parse(args); err != nil {
+		return xerrors.Errorf("解析參數失敗: %w", err)
+	}
+
+	conf, err := config.LoadConfigWithEnv(*configPath, *env)
+	if err != nil {
+		return xerrors.Errorf("取得設定檔失敗: %w", err)
+	}
+
+	writer := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(writer, "KEY\tNAME")
+	for _, t := range tester.NewTesters(conf, nil) {
+		fmt.Fprintf(writer, "%s\t%s\n", t.Key(), t.Name())
+	}
+	return writer.Flush()
+}
@@ -0,0 +1,125 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"golang.org/x/xerrors"
+
+	"github.com/marco79423/nats-jetstream-test/config"
+	"github.com/marco79423/nats-jetstream-test/tester"
+)
+
+// Run 執行測試
+func Run(args []string) error {
+	flagSet := newFlagSet("run")
+	configPath := flagSet.String("config", config.DefaultConfigPath, "設定檔的位置")
+	env := flagSet.String("env", os.Getenv(config.EnvName), "環境的名稱 (會額外讀取 config.<env>.yml，預設為 NJT_ENV 環境變數)")
+	only := flagSet.String("only", "", "只執行指定的 Tester (以逗號分隔，會取代設定檔的 enabled_testers)")
+	skip := flagSet.String("skip", "", "略過指定的 Tester (以逗號分隔)")
+	times := flagSet.Int("times", 0, "覆蓋所有 Tester 的測試次數 (0 代表使用設定檔)")
+	sizes := flagSet.String("sizes", "", "覆蓋所有 Tester 的訊息大小 (以逗號分隔)")
+	jsonPath := flagSet.String("json", "", "JSON 報告的輸出路徑 (會覆蓋設定檔的 report.json_path)")
+	csvPath := flagSet.String("csv", "", "CSV 報告的輸出路徑 (會覆蓋設定檔的 report.csv_path)")
+	warmup := flagSet.Int("warmup", -1, "每個 Tester 正式測量前的暖身次數 (會覆蓋設定檔的 warmup)")
+	iterations := flagSet.Int("iterations", 0, "每個 Tester 正式測量的次數 (會覆蓋設定檔的 iterations，0 代表使用設定檔)")
+	timeout := flagSet.Duration("timeout", 0, "每個 Tester 的時間限制 (會覆蓋設定檔的 timeout，0 代表使用設定檔)")
+	verify := flagSet.Bool("verify", false, "驗證收到的訊息 (會覆蓋設定檔的 verify)")
+	profile := flagSet.Bool("profile", false, "記錄每個 Tester 的 Client Runtime 統計並輸出 CPU 和 Heap Profile (會覆蓋設定檔的 profiling)")
+	if err := flagSet.Parse(args); err != nil {
+		return xerrors.Errorf("解析參數失敗: %w", err)
+	}
+
+	conf, err := config.LoadConfigWithEnv(*configPath, *env)
+	if err != nil {
+		return xerrors.Errorf("取得設定檔失敗: %w", err)
+	}
+
+	if onlyKeys := splitList(*only); len(onlyKeys) > 0 {
+		conf.EnabledTesters = onlyKeys
+	}
+	conf.EnabledTesters = excludeKeys(conf.EnabledTesters, splitList(*skip))
+
+	if *times > 0 {
+		conf.OverrideTimes(*times)
+	}
+
+	if *sizes != "" {
+		messageSizes, err := splitIntList(*sizes)
+		if err != nil {
+			return xerrors.Errorf("解析 sizes 參數失敗: %w", err)
+		}
+		conf.OverrideMessageSizes(messageSizes)
+	}
+
+	if *jsonPath != "" {
+		conf.Report.JSONPath = *jsonPath
+	}
+	if *csvPath != "" {
+		conf.Report.CSVPath = *csvPath
+	}
+	if *warmup >= 0 {
+		conf.Warmup = *warmup
+	}
+	if *iterations > 0 {
+		conf.Iterations = *iterations
+	}
+	if *timeout > 0 {
+		conf.Timeout = *timeout
+	}
+	if *verify {
+		conf.Verify = true
+	}
+	if *profile {
+		conf.Profiling.Enabled = true
+		conf.Profiling.CPUProfile = true
+		conf.Profiling.HeapProfile = true
+	}
+
+	ctx, cancel := contextWithSignals()
+	defer cancel()
+
+	if _, err := tester.RunTesters(ctx, conf); err != nil {
+		return xerrors.Errorf("執行測試失敗: %w", err)
+	}
+	return nil
+}
+
+// contextWithSignals 收到 SIGINT 或 SIGTERM 時取消的 Context (之後再收到訊號會以預設的方式直接結束程式)
+func contextWithSignals() (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		defer signal.Stop(signals)
+
+		select {
+		case sig := <-signals:
+			fmt.Printf("\n收到 %v，停止目前的測試並輸出已完成的報告 (再按一次 Ctrl-C 強制結束)\n", sig)
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	return ctx, cancel
+}
+
+// excludeKeys 移除 keys 中出現在 excludedKeys 的項目
+func excludeKeys(keys, excludedKeys []string) []string {
+	excludedKeySet := map[string]bool{}
+	for _, key := range excludedKeys {
+		excludedKeySet[key] = true
+	}
+
+	var remainingKeys []string
+	for _, key := range keys {
+		if !excludedKeySet[key] {
+			remainingKeys = append(remainingKeys, key)
+		}
+	}
+	return remainingKeys
+}
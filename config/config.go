@@ -1,43 +1,141 @@
 package config
 
 import (
+	"fmt"
 	"os"
 	"path/filepath"
+	"reflect"
+	"strings"
+	"time"
 
 	"github.com/spf13/viper"
 	"golang.org/x/xerrors"
 )
 
+// DefaultConfigPath 預設的設定檔位置
+const DefaultConfigPath = "conf.d/config.yml"
+
+// EnvPrefix 覆蓋設定用的環境變數前綴 (例如 NJT_NATS_JET_STREAM_SERVERS 會覆蓋 nats_jet_stream.servers)
+const EnvPrefix = "NJT"
+
+// EnvName 指定環境的環境變數 (例如 NJT_ENV=staging 會額外讀取 config.staging.yml)
+const EnvName = EnvPrefix + "_ENV"
+
+// GetConfig 讀取預設位置的設定檔
 func GetConfig() (*Config, error) {
-	// 讀取設定檔
+	return LoadConfig(DefaultConfigPath)
+}
+
+// LoadConfig 讀取指定位置的設定檔 (環境由 NJT_ENV 決定)
+func LoadConfig(configPath string) (*Config, error) {
+	return LoadConfigWithEnv(configPath, os.Getenv(EnvName))
+}
+
+// LoadConfigWithEnv 讀取指定位置的設定檔，依序套用指定環境的設定檔 (env 為空字串代表不使用) 和 NJT_* 環境變數
+func LoadConfigWithEnv(configPath, env string) (*Config, error) {
 	config := &Config{}
-	if err := loadConfig(config, "conf.d/config.yml"); err != nil {
+	if err := loadConfig(config, configPath, env); err != nil {
 		return nil, xerrors.Errorf("無法取得設定檔: %w", err)
 	}
 
 	return config, nil
 }
 
+// EnvConfigPath 取得指定環境的設定檔位置 (例如 conf.d/config.yml 的 staging 環境為 conf.d/config.staging.yml)
+func EnvConfigPath(configPath, env string) string {
+	ext := filepath.Ext(configPath)
+	return fmt.Sprintf("%s.%s%s", strings.TrimSuffix(configPath, ext), env, ext)
+}
+
+// OverrideTimes 覆蓋所有 Tester 的測試次數 (times)
+func (config *Config) OverrideTimes(times int) {
+	setTesterConfigField(&config.Testers, "Times", times)
+	for _, scenario := range config.Scenarios {
+		scenario.Times = times
+	}
+}
+
+// OverrideMessageSizes 覆蓋所有 Tester 的訊息大小 (message_sizes)
+func (config *Config) OverrideMessageSizes(messageSizes []int) {
+	setTesterConfigField(&config.Testers, "MessageSizes", messageSizes)
+	for _, scenario := range config.Scenarios {
+		scenario.MessageSizes = messageSizes
+	}
+}
+
 type Config struct {
 	NATSStreaming NATSStreamingConfig `mapstructure:"nats_streaming"`
 	NATSJetStream NATSJetStreamConfig `mapstructure:"nats_jet_stream"`
+	Embedded      EmbeddedConfig      `mapstructure:"embedded"`
+	Report        ReportConfig        `mapstructure:"report"`
+	Monitoring    MonitoringConfig    `mapstructure:"monitoring"`
+	Profiling     ProfilingConfig     `mapstructure:"profiling"`
+	Verify        bool                `mapstructure:"verify"`     // 是否驗證收到的訊息 (序號和 Checksum)，會統計遺失、重複、亂序和損毀的訊息
+	Warmup        int                 `mapstructure:"warmup"`     // 每個 Tester 正式測量前的暖身次數 (結果不列入報告)
+	Iterations    int                 `mapstructure:"iterations"` // 每個 Tester 正式測量的次數 (大於 1 時報告為平均值並附上統計，0 視為 1)
+	Timeout       time.Duration       `mapstructure:"timeout"`    // 每個 Tester 的時間限制 (包含暖身和重複測量，0 代表不限制)，超過時記為失敗並繼續下一個 Tester
+	Isolation     IsolationConfig     `mapstructure:"isolation"`
 
-	EnabledTesters []string `mapstructure:"enabled_testers"`
-	Testers        Testers  `mapstructure:"testers"`
+	EnabledTesters []string          `mapstructure:"enabled_testers"`
+	Testers        Testers           `mapstructure:"testers"`
+	Scenarios      []*ScenarioConfig `mapstructure:"scenarios"` // 通用的測試情境 (以 name 加入 enabled_testers 即可執行)
 }
 
 type NATSStreamingConfig struct {
-	Servers   []string `mapstructure:"servers"`
-	Token     string   `mapstructure:"token"`
-	ClusterID string   `mapstructure:"cluster_id"`
-	ClientID  string   `mapstructure:"client_id"`
+	Servers      []string  `mapstructure:"servers"`
+	Token        string    `mapstructure:"token"`
+	Username     string    `mapstructure:"username"`
+	Password     string    `mapstructure:"password"`
+	CredsFile    string    `mapstructure:"creds_file"`     // JWT 認證使用的 .creds 檔案 (空字串代表不使用)
+	NKeySeedFile string    `mapstructure:"nkey_seed_file"` // NKey 認證使用的 Seed 檔案 (空字串代表不使用)
+	TLS          TLSConfig `mapstructure:"tls"`
+	ClusterID    string    `mapstructure:"cluster_id"`
+	ClientID     string    `mapstructure:"client_id"`
 }
 
 type NATSJetStreamConfig struct {
-	Servers  []string `mapstructure:"servers"`
-	Token    string   `mapstructure:"token"`
-	Username string   `mapstructure:"username"`
-	Password string   `mapstructure:"password"`
+	Servers      []string  `mapstructure:"servers"`
+	Token        string    `mapstructure:"token"`
+	Username     string    `mapstructure:"username"`
+	Password     string    `mapstructure:"password"`
+	CredsFile    string    `mapstructure:"creds_file"`     // JWT 認證使用的 .creds 檔案 (空字串代表不使用)
+	NKeySeedFile string    `mapstructure:"nkey_seed_file"` // NKey 認證使用的 Seed 檔案 (空字串代表不使用)
+	TLS          TLSConfig `mapstructure:"tls"`
+}
+
+type TLSConfig struct {
+	CAFile   string `mapstructure:"ca_file"`   // 驗證 Server 憑證用的 CA (空字串代表使用系統的 CA)
+	CertFile string `mapstructure:"cert_file"` // Client 憑證 (Server 要求驗證 Client 時需要)
+	KeyFile  string `mapstructure:"key_file"`
+}
+
+type EmbeddedConfig struct {
+	Enabled     bool   `mapstructure:"enabled"`      // 啟用後會改用內嵌 (In-Process) 的 Server 而非 servers 設定的位置
+	Port        int    `mapstructure:"port"`         // 0 代表隨機 (Cluster 模式下為第一個節點的 Port)
+	StoreDir    string `mapstructure:"store_dir"`    // 空字串代表使用暫存資料夾 (結束後刪除)
+	StoreType   string `mapstructure:"store_type"`   // NATS Streaming 使用的 Store (memory 或 file)
+	ClusterSize int    `mapstructure:"cluster_size"` // 大於 1 時會啟動 JetStream Cluster (0 或 1 代表單一 Server)
+	TLS         bool   `mapstructure:"tls"`          // 是否產生本機的 CA 和憑證並開啟 TLS (會要求 Client 憑證)
+	Auth        string `mapstructure:"auth"`         // 認證方式 (空字串代表不認證, user, nkey, jwt)，認證資料會自動產生
+}
+
+type ReportConfig struct {
+	JSONPath string `mapstructure:"json_path"` // 空字串代表不輸出
+	CSVPath  string `mapstructure:"csv_path"`  // 空字串代表不輸出
+}
+
+type MonitoringConfig struct {
+	Enabled      bool          `mapstructure:"enabled"`       // 每個 Tester 執行前後擷取 Server 監控端點的數據 (/varz, /jsz, /connz 和 /streaming/serverz)
+	NATSURLs     []string      `mapstructure:"nats_urls"`     // NATS Server 的監控位置 (例如 http://localhost:8222，Cluster 可列出每個節點)，啟用 embedded 時會自動設定
+	StreamingURL string        `mapstructure:"streaming_url"` // NATS Streaming Server 的監控位置 (空字串代表不擷取)，啟用 embedded 時會自動設定
+	Timeout      time.Duration `mapstructure:"timeout"`       // 每個端點的請求時間限制 (0 代表 5 秒)
+}
+
+type ProfilingConfig struct {
+	Enabled     bool   `mapstructure:"enabled"`      // 記錄每個 Tester 執行期間 Client 的 Go Runtime 統計 (記憶體配置、GC 和 Goroutine)
+	CPUProfile  bool   `mapstructure:"cpu_profile"`  // 輸出每個 Tester 的 pprof CPU Profile (<dir>/<tester>.cpu.pprof)
+	HeapProfile bool   `mapstructure:"heap_profile"` // 輸出每個 Tester 開始和結束時的 pprof Heap Profile (<dir>/<tester>.heap_base.pprof 和 <dir>/<tester>.heap.pprof)
+	Dir         string `mapstructure:"dir"`          // Profile 的輸出資料夾 (空字串代表報告所在的資料夾)
 }
 
 type Testers struct {
@@ -45,15 +143,28 @@ type Testers struct {
 	StreamingPublishTester *StreamingPublishTesterConfig `mapstructure:"streaming_publish_tester"`
 	NATSPublishTester      *NATSPublishTesterConfig      `mapstructure:"nats_publish_tester"`
 
+	NATSSubscribeTester    *NATSSubscribeTesterConfig    `mapstructure:"nats_subscribe_tester"`
+	NATSRequestReplyTester *NATSRequestReplyTesterConfig `mapstructure:"nats_request_reply_tester"`
+
 	StreamingSubscribeTester     *StreamingSubscribeTesterConfig     `mapstructure:"streaming_subscribe_tester"`
 	StreamingLatencyTester       *StreamingLatencyTesterConfig       `mapstructure:"streaming_latency_tester"`
 	JetStreamPurgeStreamTester   *JetStreamPurgeStreamTesterConfig   `mapstructure:"jetstream_purge_stream_tester"`
 	JetStreamMemoryStorageTester *JetStreamMemoryStorageTesterConfig `mapstructure:"jetstream_memory_storage_tester"`
 	JetStreamLatencyTester       *JetStreamLatencyTesterConfig       `mapstructure:"jetstream_latency_tester"`
+	NATSLatencyTester            *NATSLatencyTesterConfig            `mapstructure:"nats_latency_tester"`
 	JetStreamAsyncPublishTester  *JetStreamAsyncPublishTesterConfig  `mapstructure:"jetstream_async_publish_tester"`
 	JetStreamSubscribeTester     *JetStreamSubscribeTesterConfig     `mapstructure:"jetstream_subscribe_tester"`
 	JetStreamChanSubscribeTester *JetStreamChanSubscribeTesterConfig `mapstructure:"jetstream_chan_subscribe_tester"`
 	JetStreamPullSubscribeTester *JetStreamPullSubscribeTesterConfig `mapstructure:"jetstream_pull_subscribe_tester"`
+	JetStreamConsumerTester      *JetStreamConsumerTesterConfig      `mapstructure:"jetstream_consumer_tester"`
+	JetStreamReplicasTester      *JetStreamReplicasTesterConfig      `mapstructure:"jetstream_replicas_tester"`
+	FaultInjectionTester         *FaultInjectionTesterConfig         `mapstructure:"fault_injection_tester"`
+	JetStreamDedupTester         *JetStreamDedupTesterConfig         `mapstructure:"jetstream_dedup_tester"`
+	JetStreamRetentionTester     *JetStreamRetentionTesterConfig     `mapstructure:"jetstream_retention_tester"`
+
+	JetStreamLoadTester *JetStreamLoadTesterConfig `mapstructure:"jetstream_load_tester"`
+	StreamingLoadTester *StreamingLoadTesterConfig `mapstructure:"streaming_load_tester"`
+	NATSLoadTester      *NATSLoadTesterConfig      `mapstructure:"nats_load_tester"`
 }
 
 type JetStreamPublishTesterConfig struct {
@@ -69,6 +180,22 @@ type NATSPublishTesterConfig struct {
 	MessageSizes []int  `mapstructure:"message_sizes"`
 }
 
+type NATSSubscribeTesterConfig struct {
+	Subject      string `mapstructure:"subject"`
+	Times        int    `mapstructure:"times"`
+	MessageSizes []int  `mapstructure:"message_sizes"`
+}
+
+type NATSRequestReplyTesterConfig struct {
+	Subject      string    `mapstructure:"subject"`
+	Times        int       `mapstructure:"times"`
+	MessageSizes []int     `mapstructure:"message_sizes"`
+	Responders   int       `mapstructure:"responders"`
+	QueueGroup   string    `mapstructure:"queue_group"` // 空字串代表不使用 Queue Group (每個 Responder 都會收到請求)
+	Percentiles  []float64 `mapstructure:"percentiles"`
+	ShowChart    bool      `mapstructure:"show_chart"`
+}
+
 type StreamingPublishTesterConfig struct {
 	Channel      string `mapstructure:"channel"`
 	Times        int    `mapstructure:"times"`
@@ -110,15 +237,88 @@ type JetStreamPullSubscribeTesterConfig struct {
 	MessageSizes []int  `mapstructure:"message_sizes"`
 }
 
+type JetStreamConsumerTesterConfig struct {
+	Stream          string   `mapstructure:"stream"`
+	Subject         string   `mapstructure:"subject"`
+	Times           int      `mapstructure:"times"`
+	MessageSizes    []int    `mapstructure:"message_sizes"`
+	AckPolicies     []string `mapstructure:"ack_policies"`     // none, all, explicit
+	DeliverPolicies []string `mapstructure:"deliver_policies"` // all, last, new, by_start_sequence, by_start_time
+	MaxAckPendings  []int    `mapstructure:"max_ack_pendings"` // 0 代表使用預設值
+	ReplayPolicies  []string `mapstructure:"replay_policies"`  // instant, original
+}
+
+type JetStreamReplicasTesterConfig struct {
+	Stream       string    `mapstructure:"stream"`
+	Subject      string    `mapstructure:"subject"`
+	Times        int       `mapstructure:"times"`
+	MessageSizes []int     `mapstructure:"message_sizes"`
+	Replicas     []int     `mapstructure:"replicas"` // 副本數不能超過 Cluster 的節點數
+	Percentiles  []float64 `mapstructure:"percentiles"`
+	ShowChart    bool      `mapstructure:"show_chart"`
+}
+
+type FaultInjectionTesterConfig struct {
+	Transports      []string      `mapstructure:"transports"` // jetstream, streaming
+	Stream          string        `mapstructure:"stream"`
+	Subject         string        `mapstructure:"subject"`
+	Channel         string        `mapstructure:"channel"`
+	Times           int           `mapstructure:"times"`
+	MessageSizes    []int         `mapstructure:"message_sizes"`
+	PublishInterval time.Duration `mapstructure:"publish_interval"` // 每筆訊息的發布間隔 (讓 Server 在發布途中重啟)
+	Downtime        time.Duration `mapstructure:"downtime"`         // Server 停止的時間
+	ReceiveTimeout  time.Duration `mapstructure:"receive_timeout"`  // 發布完後等待剩餘訊息的時間上限
+	ServerIndex     int           `mapstructure:"server_index"`     // 重啟內嵌 Cluster 的第幾個節點 (從 0 開始)
+	StopCommand     string        `mapstructure:"stop_command"`     // 沒有啟用內嵌 Server 時用來停止 Server 的指令
+	StartCommand    string        `mapstructure:"start_command"`    // 沒有啟用內嵌 Server 時用來啟動 Server 的指令
+}
+
+type JetStreamDedupTesterConfig struct {
+	Stream            string        `mapstructure:"stream"`
+	Subject           string        `mapstructure:"subject"`
+	Times             int           `mapstructure:"times"`
+	MessageSizes      []int         `mapstructure:"message_sizes"`
+	DuplicateRatios   []float64     `mapstructure:"duplicate_ratios"`    // 重複發布的比例 (0 ~ 1)
+	DuplicateWindow   time.Duration `mapstructure:"duplicate_window"`    // Stream 的 Duplicates Window (0 代表使用 Server 預設的 2 分鐘)
+	CheckWindowExpiry bool          `mapstructure:"check_window_expiry"` // 是否確認超過 Window 後不再視為重複 (需要等待 Window 的時間)
+}
+
+type JetStreamRetentionTesterConfig struct {
+	Stream         string        `mapstructure:"stream"`
+	Subject        string        `mapstructure:"subject"`
+	Times          int           `mapstructure:"times"`
+	MessageSizes   []int         `mapstructure:"message_sizes"`
+	Retentions     []string      `mapstructure:"retentions"`      // limits, interest, workqueue
+	Discards       []string      `mapstructure:"discards"`        // old, new
+	MaxMsgs        int64         `mapstructure:"max_msgs"`        // 0 代表不限制
+	MaxBytes       int64         `mapstructure:"max_bytes"`       // 0 代表不限制
+	MaxAge         time.Duration `mapstructure:"max_age"`         // 0 代表不限制
+	CreateConsumer bool          `mapstructure:"create_consumer"` // 是否先建立 Consumer (Interest 沒有 Consumer 時訊息會直接被丟棄)
+}
+
 type JetStreamLatencyTesterConfig struct {
-	Stream  string `mapstructure:"stream"`
-	Subject string `mapstructure:"subject"`
-	Times   int    `mapstructure:"times"`
+	Stream      string    `mapstructure:"stream"`
+	Subject     string    `mapstructure:"subject"`
+	Times       int       `mapstructure:"times"`
+	Rates       []float64 `mapstructure:"rates"` // 每秒發布的筆數 (0 代表不限速)
+	Percentiles []float64 `mapstructure:"percentiles"`
+	ShowChart   bool      `mapstructure:"show_chart"`
 }
 
 type StreamingLatencyTesterConfig struct {
-	Channel string `mapstructure:"channel"`
-	Times   int    `mapstructure:"times"`
+	Channel     string    `mapstructure:"channel"`
+	Times       int       `mapstructure:"times"`
+	Rates       []float64 `mapstructure:"rates"` // 每秒發布的筆數 (0 代表不限速)
+	Percentiles []float64 `mapstructure:"percentiles"`
+	ShowChart   bool      `mapstructure:"show_chart"`
+}
+
+type NATSLatencyTesterConfig struct {
+	Subject     string    `mapstructure:"subject"`
+	Times       int       `mapstructure:"times"`
+	Rates       []float64 `mapstructure:"rates"` // 每秒發布的筆數 (0 代表不限速)
+	Percentiles []float64 `mapstructure:"percentiles"`
+	ShowChart   bool      `mapstructure:"show_chart"`
 }
 
 type JetStreamPurgeStreamTesterConfig struct {
@@ -135,8 +335,124 @@ type JetStreamMemoryStorageTesterConfig struct {
 	MessageSizes []int  `mapstructure:"message_sizes"`
 }
 
+type JetStreamLoadTesterConfig struct {
+	Stream       string `mapstructure:"stream"`
+	Subject      string `mapstructure:"subject"`
+	Publishers   int    `mapstructure:"publishers"`
+	Subscribers  int    `mapstructure:"subscribers"`
+	Times        int    `mapstructure:"times"` // 每個發布者的發布次數
+	MessageSizes []int  `mapstructure:"message_sizes"`
+}
+
+type StreamingLoadTesterConfig struct {
+	Channel      string `mapstructure:"channel"`
+	Publishers   int    `mapstructure:"publishers"`
+	Subscribers  int    `mapstructure:"subscribers"`
+	Times        int    `mapstructure:"times"` // 每個發布者的發布次數
+	MessageSizes []int  `mapstructure:"message_sizes"`
+}
+
+type NATSLoadTesterConfig struct {
+	Subject      string `mapstructure:"subject"`
+	Publishers   int    `mapstructure:"publishers"`
+	Subscribers  int    `mapstructure:"subscribers"`
+	Times        int    `mapstructure:"times"` // 每個發布者的發布次數
+	MessageSizes []int  `mapstructure:"message_sizes"`
+}
+
+// ScenarioConfig 通用的測試情境 (不需要為每個情境撰寫 Tester)
+type ScenarioConfig struct {
+	Name         string                  `mapstructure:"name"`        // 情境的名稱 (同時作為 enabled_testers 使用的 Key，不可和 Tester 重複)
+	Description  string                  `mapstructure:"description"` // 空字串代表使用預設的說明
+	Transport    string                  `mapstructure:"transport"`   // jetstream, streaming, nats
+	Operation    string                  `mapstructure:"operation"`   // publish, async_publish, publish_latency, subscribe, chan_subscribe, pull_subscribe, consume, request_reply, load
+	Subject      string                  `mapstructure:"subject"`     // Streaming 代表 Channel
+	Stream       *ScenarioStreamConfig   `mapstructure:"stream"`      // 只有 JetStream 使用
+	Consumer     *ScenarioConsumerConfig `mapstructure:"consumer"`    // 只有 JetStream 的 pull_subscribe 和 consume 使用
+	Times        int                     `mapstructure:"times"`       // load 代表每個發布者的發布次數
+	MessageSizes []int                   `mapstructure:"message_sizes"`
+	Publishers   *int                    `mapstructure:"publishers"`  // 只有 load 使用 (沒有設定時為 1)
+	Subscribers  *int                    `mapstructure:"subscribers"` // load 的訂閱者數量 (0 代表只測發布) 或 request_reply 的 Responder 數量 (沒有設定時為 1)
+	Percentiles  []float64               `mapstructure:"percentiles"` // 只有 publish_latency 和 request_reply 使用
+	ShowChart    bool                    `mapstructure:"show_chart"`
+}
+
+type ScenarioStreamConfig struct {
+	Name            string        `mapstructure:"name"`
+	Storage         string        `mapstructure:"storage"`          // file 或 memory (空字串代表 file)
+	Replicas        int           `mapstructure:"replicas"`         // 0 代表使用預設值
+	Retention       string        `mapstructure:"retention"`        // limits, interest, workqueue (空字串代表 limits)
+	Discard         string        `mapstructure:"discard"`          // old 或 new (空字串代表 old)
+	MaxMsgs         int64         `mapstructure:"max_msgs"`         // 0 代表不限制
+	MaxBytes        int64         `mapstructure:"max_bytes"`        // 0 代表不限制
+	MaxAge          time.Duration `mapstructure:"max_age"`          // 0 代表不限制
+	DuplicateWindow time.Duration `mapstructure:"duplicate_window"` // 0 代表使用 Server 預設的 2 分鐘
+}
+
+type ScenarioConsumerConfig struct {
+	Durable       string `mapstructure:"durable"`         // 空字串代表使用情境的名稱 (pull_subscribe 需要 Durable)
+	AckPolicy     string `mapstructure:"ack_policy"`      // none, all, explicit (空字串代表 explicit，只有 consume 可以調整)
+	DeliverPolicy string `mapstructure:"deliver_policy"`  // all, last, new, by_start_sequence, by_start_time (空字串代表 all，只有 consume 可以調整)
+	MaxAckPending int    `mapstructure:"max_ack_pending"` // 0 代表使用預設值 (consume 和 pull_subscribe 使用)
+	ReplayPolicy  string `mapstructure:"replay_policy"`   // instant 或 original (空字串代表 instant，只有 consume 可以調整)
+	FetchCount    int    `mapstructure:"fetch_count"`     // pull_subscribe 每次 Fetch 的數量 (0 代表 1)
+}
+
+// bindEnvs 綁定設定中每個欄位對應的環境變數 (設定檔沒有出現的欄位也能用環境變數設定，但不包含 scenarios 這類結構的列表)
+func bindEnvs(v *viper.Viper, configType reflect.Type, prefix string) error {
+	for configType.Kind() == reflect.Ptr {
+		configType = configType.Elem()
+	}
+
+	for i := 0; i < configType.NumField(); i++ {
+		field := configType.Field(i)
+		key := field.Tag.Get("mapstructure")
+		if key == "" {
+			continue
+		}
+		if prefix != "" {
+			key = prefix + "." + key
+		}
+
+		fieldType := field.Type
+		for fieldType.Kind() == reflect.Ptr {
+			fieldType = fieldType.Elem()
+		}
+
+		switch {
+		case fieldType.Kind() == reflect.Struct:
+			if err := bindEnvs(v, fieldType, key); err != nil {
+				return err
+			}
+		case fieldType.Kind() == reflect.Slice && fieldType.Elem().Kind() == reflect.Ptr:
+			continue
+		default:
+			if err := v.BindEnv(key); err != nil {
+				return xerrors.Errorf("無法綁定 %s 的環境變數: %w", key, err)
+			}
+		}
+	}
+	return nil
+}
+
+// setTesterConfigField 設定每個 Tester 設定中名為 fieldName 的欄位 (沒有該欄位或沒有設定的 Tester 會略過)
+func setTesterConfigField(testers *Testers, fieldName string, value interface{}) {
+	testersValue := reflect.ValueOf(testers).Elem()
+	for i := 0; i < testersValue.NumField(); i++ {
+		testerConfig := testersValue.Field(i)
+		if testerConfig.Kind() != reflect.Ptr || testerConfig.IsNil() {
+			continue
+		}
+
+		field := testerConfig.Elem().FieldByName(fieldName)
+		if field.IsValid() && field.CanSet() && field.Type() == reflect.TypeOf(value) {
+			field.Set(reflect.ValueOf(value))
+		}
+	}
+}
+
 // loadConfig 讀取設定檔
-func loadConfig(rawConfig interface{}, configPath string) error {
+func loadConfig(rawConfig interface{}, configPath, env string) error {
 	absConfigPath, err := filepath.Abs(configPath)
 	if err != nil {
 		return xerrors.Errorf("無法讀取設定檔 %s: %w", configPath, err)
@@ -146,14 +462,36 @@ func loadConfig(rawConfig interface{}, configPath string) error {
 		return xerrors.Errorf("無法讀取設定檔 %s: %w", configPath, err)
 	}
 
-	viper.SetConfigType("yaml")
-	viper.SetConfigFile(absConfigPath)
+	// 每次讀取都使用新的 Viper，避免重複讀取時殘留上一次的設定
+	v := viper.New()
+	v.SetConfigType("yaml")
+	v.SetConfigFile(absConfigPath)
 
-	if err := viper.ReadInConfig(); err != nil {
+	if err := v.ReadInConfig(); err != nil {
 		return xerrors.Errorf("無法讀取設定檔 %s: %w", configPath, err)
 	}
 
-	if err := viper.Unmarshal(rawConfig); err != nil {
+	// 指定環境的設定檔會合併到基本的設定檔上 (列表會整個取代)
+	if env != "" {
+		envConfigPath := EnvConfigPath(absConfigPath, env)
+		if _, err := os.Stat(envConfigPath); os.IsNotExist(err) {
+			return xerrors.Errorf("無法讀取環境 %s 的設定檔 %s: %w", env, envConfigPath, err)
+		}
+
+		v.SetConfigFile(envConfigPath)
+		if err := v.MergeInConfig(); err != nil {
+			return xerrors.Errorf("無法讀取環境 %s 的設定檔 %s: %w", env, envConfigPath, err)
+		}
+	}
+
+	// 環境變數的優先權最高 (列表以逗號分隔，例如 NJT_ENABLED_TESTERS=nats_publish_tester,nats_subscribe_tester)
+	v.SetEnvPrefix(EnvPrefix)
+	v.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
+	if err := bindEnvs(v, reflect.TypeOf(rawConfig), ""); err != nil {
+		return xerrors.Errorf("無法綁定環境變數: %w", err)
+	}
+
+	if err := v.Unmarshal(rawConfig); err != nil {
 		return xerrors.Errorf("無法讀取設定檔 %s: %w", configPath, err)
 	}
 
@@ -0,0 +1,352 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+const testBaseConfig = `
+nats_jet_stream:
+  servers:
+    - nats://base-1:4222
+    - nats://base-2:4222
+  tls:
+    ca_file: base-ca.pem
+embedded:
+  enabled: false
+  port: 4333
+  cluster_size: 1
+timeout: 1m
+enabled_testers:
+  - nats_publish_tester
+  - jetstream_consumer_tester
+testers:
+  nats_publish_tester:
+    subject: base_subject
+    times: 100
+    message_sizes:
+      - 1
+      - 80000
+  jetstream_consumer_tester:
+    stream: base_stream
+    subject: base_subject
+    times: 1000
+    message_sizes:
+      - 100
+    ack_policies:
+      - none
+      - explicit
+  jetstream_purge_stream_tester:
+    stream: purge_stream
+    subject: purge_subject
+    counts:
+      - 10
+    message_sizes:
+      - 100
+  nats_latency_tester:
+    subject: latency_subject
+    times: 100
+    rates:
+      - 100
+scenarios:
+  - name: my_scenario
+    transport: nats
+    operation: publish
+    subject: my.scenario
+    times: 10
+    message_sizes:
+      - 64
+`
+
+// writeTestConfig 在暫存資料夾中建立 config.yml (staging 不為空字串時同時建立 config.staging.yml)，回傳 config.yml 的位置
+func writeTestConfig(t *testing.T, staging string) string {
+	t.Helper()
+
+	dir := newTempDir(t)
+	configPath := filepath.Join(dir, "config.yml")
+	if err := ioutil.WriteFile(configPath, []byte(testBaseConfig), 0600); err != nil {
+		t.Fatalf("建立設定檔失敗: %v", err)
+	}
+	if staging != "" {
+		if err := ioutil.WriteFile(EnvConfigPath(configPath, "staging"), []byte(staging), 0600); err != nil {
+			t.Fatalf("建立環境的設定檔失敗: %v", err)
+		}
+	}
+	return configPath
+}
+
+// setEnv 設定環境變數 (測試結束後還原)
+func setEnv(t *testing.T, key, value string) {
+	t.Helper()
+
+	original, existed := os.LookupEnv(key)
+	if err := os.Setenv(key, value); err != nil {
+		t.Fatalf("設定環境變數 %s 失敗: %v", key, err)
+	}
+	t.Cleanup(func() {
+		if existed {
+			_ = os.Setenv(key, original)
+		} else {
+			_ = os.Unsetenv(key)
+		}
+	})
+}
+
+func TestEnvConfigPath(t *testing.T) {
+	testCases := []struct {
+		configPath string
+		env        string
+		want       string
+	}{
+		{configPath: "conf.d/config.yml", env: "staging", want: "conf.d/config.staging.yml"},
+		{configPath: "/etc/njt/config.yaml", env: "ci", want: "/etc/njt/config.ci.yaml"},
+		{configPath: "config", env: "local", want: "config.local"},
+	}
+
+	for _, testCase := range testCases {
+		if got := EnvConfigPath(testCase.configPath, testCase.env); got != testCase.want {
+			t.Errorf("EnvConfigPath(%q, %q) = %q，預期為 %q", testCase.configPath, testCase.env, got, testCase.want)
+		}
+	}
+}
+
+func TestLoadConfigWithEnvFile(t *testing.T) {
+	testCases := []struct {
+		name    string
+		staging string // config.staging.yml 的內容 (空字串代表不使用環境)
+		check   func(t *testing.T, config *Config)
+	}{
+		{
+			name: "base only",
+			check: func(t *testing.T, config *Config) {
+				assertEqual(t, "nats_jet_stream.servers", config.NATSJetStream.Servers, []string{"nats://base-1:4222", "nats://base-2:4222"})
+				assertEqual(t, "embedded.port", config.Embedded.Port, 4333)
+				assertEqual(t, "timeout", config.Timeout, time.Minute)
+				assertEqual(t, "testers.nats_publish_tester.times", config.Testers.NATSPublishTester.Times, 100)
+			},
+		},
+		{
+			name: "overrides only the keys it sets",
+			staging: `
+embedded:
+  enabled: true
+testers:
+  nats_publish_tester:
+    times: 5
+`,
+			check: func(t *testing.T, config *Config) {
+				assertEqual(t, "embedded.enabled", config.Embedded.Enabled, true)
+				assertEqual(t, "testers.nats_publish_tester.times", config.Testers.NATSPublishTester.Times, 5)
+
+				// 同一個區塊中沒有設定的值保留基本設定檔的內容
+				assertEqual(t, "embedded.port", config.Embedded.Port, 4333)
+				assertEqual(t, "embedded.cluster_size", config.Embedded.ClusterSize, 1)
+				assertEqual(t, "testers.nats_publish_tester.subject", config.Testers.NATSPublishTester.Subject, "base_subject")
+				assertEqual(t, "testers.nats_publish_tester.message_sizes", config.Testers.NATSPublishTester.MessageSizes, []int{1, 80000})
+				assertEqual(t, "testers.jetstream_consumer_tester.stream", config.Testers.JetStreamConsumerTester.Stream, "base_stream")
+				assertEqual(t, "nats_jet_stream.tls.ca_file", config.NATSJetStream.TLS.CAFile, "base-ca.pem")
+				assertEqual(t, "timeout", config.Timeout, time.Minute)
+				assertEqual(t, "scenarios", len(config.Scenarios), 1)
+			},
+		},
+		{
+			name: "lists are replaced",
+			staging: `
+nats_jet_stream:
+  servers:
+    - nats://staging:4222
+testers:
+  nats_publish_tester:
+    message_sizes:
+      - 64
+`,
+			check: func(t *testing.T, config *Config) {
+				assertEqual(t, "nats_jet_stream.servers", config.NATSJetStream.Servers, []string{"nats://staging:4222"})
+				assertEqual(t, "testers.nats_publish_tester.message_sizes", config.Testers.NATSPublishTester.MessageSizes, []int{64})
+				assertEqual(t, "enabled_testers", config.EnabledTesters, []string{"nats_publish_tester", "jetstream_consumer_tester"})
+			},
+		},
+		{
+			name: "adds a tester missing from the base file",
+			staging: `
+testers:
+  jetstream_dedup_tester:
+    stream: dedup
+    duplicate_window: 30s
+`,
+			check: func(t *testing.T, config *Config) {
+				if config.Testers.JetStreamDedupTester == nil {
+					t.Fatalf("testers.jetstream_dedup_tester 沒有設定")
+				}
+				assertEqual(t, "testers.jetstream_dedup_tester.stream", config.Testers.JetStreamDedupTester.Stream, "dedup")
+				assertEqual(t, "testers.jetstream_dedup_tester.duplicate_window", config.Testers.JetStreamDedupTester.DuplicateWindow, 30*time.Second)
+			},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			configPath := writeTestConfig(t, testCase.staging)
+			env := ""
+			if testCase.staging != "" {
+				env = "staging"
+			}
+
+			config, err := LoadConfigWithEnv(configPath, env)
+			if err != nil {
+				t.Fatalf("讀取設定檔失敗: %+v", err)
+			}
+			testCase.check(t, config)
+		})
+	}
+
+	t.Run("missing env file", func(t *testing.T) {
+		if _, err := LoadConfigWithEnv(writeTestConfig(t, ""), "production"); err == nil {
+			t.Errorf("環境的設定檔不存在時應該回傳錯誤")
+		}
+	})
+}
+
+func TestLoadConfigWithEnvVars(t *testing.T) {
+	testCases := []struct {
+		name  string
+		envs  map[string]string
+		check func(t *testing.T, config *Config)
+	}{
+		{
+			name: "top level",
+			envs: map[string]string{"NJT_TIMEOUT": "5s", "NJT_VERIFY": "true", "NJT_ITERATIONS": "3"},
+			check: func(t *testing.T, config *Config) {
+				assertEqual(t, "timeout", config.Timeout, 5*time.Second)
+				assertEqual(t, "verify", config.Verify, true)
+				assertEqual(t, "iterations", config.Iterations, 3)
+			},
+		},
+		{
+			name: "nested fields",
+			envs: map[string]string{
+				"NJT_EMBEDDED_CLUSTER_SIZE":             "3",
+				"NJT_NATS_JET_STREAM_TLS_CA_FILE":       "env-ca.pem",
+				"NJT_TESTERS_NATS_PUBLISH_TESTER_TIMES": "7",
+			},
+			check: func(t *testing.T, config *Config) {
+				assertEqual(t, "embedded.cluster_size", config.Embedded.ClusterSize, 3)
+				assertEqual(t, "nats_jet_stream.tls.ca_file", config.NATSJetStream.TLS.CAFile, "env-ca.pem")
+				assertEqual(t, "testers.nats_publish_tester.times", config.Testers.NATSPublishTester.Times, 7)
+
+				// 沒有覆蓋的欄位保留設定檔的內容
+				assertEqual(t, "embedded.port", config.Embedded.Port, 4333)
+				assertEqual(t, "testers.nats_publish_tester.subject", config.Testers.NATSPublishTester.Subject, "base_subject")
+				assertEqual(t, "testers.jetstream_consumer_tester.times", config.Testers.JetStreamConsumerTester.Times, 1000)
+			},
+		},
+		{
+			name: "comma separated lists",
+			envs: map[string]string{
+				"NJT_ENABLED_TESTERS":                                "nats_publish_tester,nats_latency_tester",
+				"NJT_NATS_JET_STREAM_SERVERS":                        "nats://env-1:4222,nats://env-2:4222",
+				"NJT_TESTERS_NATS_PUBLISH_TESTER_MESSAGE_SIZES":      "1,2,3",
+				"NJT_TESTERS_JETSTREAM_CONSUMER_TESTER_ACK_POLICIES": "all",
+				"NJT_TESTERS_NATS_LATENCY_TESTER_RATES":              "100,250.5",
+			},
+			check: func(t *testing.T, config *Config) {
+				assertEqual(t, "enabled_testers", config.EnabledTesters, []string{"nats_publish_tester", "nats_latency_tester"})
+				assertEqual(t, "nats_jet_stream.servers", config.NATSJetStream.Servers, []string{"nats://env-1:4222", "nats://env-2:4222"})
+				assertEqual(t, "testers.nats_publish_tester.message_sizes", config.Testers.NATSPublishTester.MessageSizes, []int{1, 2, 3})
+				assertEqual(t, "testers.jetstream_consumer_tester.ack_policies", config.Testers.JetStreamConsumerTester.AckPolicies, []string{"all"})
+				assertEqual(t, "testers.nats_latency_tester.rates", config.Testers.NATSLatencyTester.Rates, []float64{100, 250.5})
+			},
+		},
+		{
+			name: "tester missing from the config file",
+			envs: map[string]string{"NJT_TESTERS_JETSTREAM_DEDUP_TESTER_DUPLICATE_WINDOW": "30s"},
+			check: func(t *testing.T, config *Config) {
+				if config.Testers.JetStreamDedupTester == nil {
+					t.Fatalf("testers.jetstream_dedup_tester 沒有設定")
+				}
+				assertEqual(t, "testers.jetstream_dedup_tester.duplicate_window", config.Testers.JetStreamDedupTester.DuplicateWindow, 30*time.Second)
+			},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			for key, value := range testCase.envs {
+				setEnv(t, key, value)
+			}
+
+			config, err := LoadConfigWithEnv(writeTestConfig(t, ""), "")
+			if err != nil {
+				t.Fatalf("讀取設定檔失敗: %+v", err)
+			}
+			testCase.check(t, config)
+		})
+	}
+
+	t.Run("env vars take precedence over env file", func(t *testing.T) {
+		setEnv(t, "NJT_TESTERS_NATS_PUBLISH_TESTER_TIMES", "9")
+		configPath := writeTestConfig(t, "testers:\n  nats_publish_tester:\n    times: 5\n")
+
+		config, err := LoadConfigWithEnv(configPath, "staging")
+		if err != nil {
+			t.Fatalf("讀取設定檔失敗: %+v", err)
+		}
+		assertEqual(t, "testers.nats_publish_tester.times", config.Testers.NATSPublishTester.Times, 9)
+	})
+}
+
+func TestOverrideTimesAndMessageSizes(t *testing.T) {
+	config, err := LoadConfigWithEnv(writeTestConfig(t, ""), "")
+	if err != nil {
+		t.Fatalf("讀取設定檔失敗: %+v", err)
+	}
+
+	config.OverrideTimes(3)
+	config.OverrideMessageSizes([]int{16, 32})
+
+	// 每個有設定的 Tester 只要有 times 或 message_sizes 欄位都需要被覆蓋
+	overriddenTimes, overriddenSizes := 0, 0
+	testersValue := reflect.ValueOf(&config.Testers).Elem()
+	for i := 0; i < testersValue.NumField(); i++ {
+		key := testersValue.Type().Field(i).Tag.Get("mapstructure")
+		testerConfig := testersValue.Field(i)
+		if testerConfig.IsNil() {
+			continue
+		}
+
+		if times := testerConfig.Elem().FieldByName("Times"); times.IsValid() {
+			assertEqual(t, "testers."+key+".times", int(times.Int()), 3)
+			overriddenTimes++
+		}
+		if sizes := testerConfig.Elem().FieldByName("MessageSizes"); sizes.IsValid() {
+			assertEqual(t, "testers."+key+".message_sizes", sizes.Interface(), []int{16, 32})
+			overriddenSizes++
+		}
+	}
+	if overriddenTimes != 3 || overriddenSizes != 3 {
+		t.Errorf("覆蓋了 %d 個 times 和 %d 個 message_sizes，預期各為 3 個", overriddenTimes, overriddenSizes)
+	}
+
+	for _, scenario := range config.Scenarios {
+		assertEqual(t, "scenarios."+scenario.Name+".times", scenario.Times, 3)
+		assertEqual(t, "scenarios."+scenario.Name+".message_sizes", scenario.MessageSizes, []int{16, 32})
+	}
+
+	// 沒有 times 的欄位不受影響，沒有設定的 Tester 維持 nil
+	assertEqual(t, "testers.jetstream_purge_stream_tester.counts", config.Testers.JetStreamPurgeStreamTester.Counts, []int{10})
+	if config.Testers.NATSSubscribeTester != nil {
+		t.Errorf("沒有設定的 Tester 不應該被建立")
+	}
+}
+
+func assertEqual(t *testing.T, name string, got, want interface{}) {
+	t.Helper()
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("%s 為 %#v，預期為 %#v", name, got, want)
+	}
+}
@@ -0,0 +1,100 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultIsolationPrefix 沒有設定 isolation.prefix 時使用的前綴
+const DefaultIsolationPrefix = "njt"
+
+// IsolationConfig 資源隔離的設定
+type IsolationConfig struct {
+	Enabled  bool   `mapstructure:"enabled"`  // 每次執行的每個 Tester 都使用不重複的 Stream, Subject, Channel 和 Client ID
+	Prefix   string `mapstructure:"prefix"`   // 資源名稱的前綴 (cleanup 指令會刪除符合前綴的 Stream，空字串代表使用 njt)
+	Teardown bool   `mapstructure:"teardown"` // 每個 Tester 結束後刪除建立的 Stream (失敗或中斷時一律會刪除)
+}
+
+// IsolationPrefix 取得資源名稱的前綴
+func (config *Config) IsolationPrefix() string {
+	if config.Isolation.Prefix == "" {
+		return DefaultIsolationPrefix
+	}
+	return config.Isolation.Prefix
+}
+
+// NewRunID 產生這次執行的 ID (用於隔離資源的名稱)
+func NewRunID() string {
+	return strconv.FormatInt(time.Now().UnixNano(), 36)
+}
+
+// ApplyIsolation 將所有 Tester 和情境的 Stream, Subject 和 Channel 改為這次執行專用的名稱，Client ID 也會加上前綴
+//
+// Stream 會改為 <prefix>_<runID>_<key>_<stream>，Subject 和 Channel 會改為 <prefix>.<runID>.<key>.<subject>
+func (config *Config) ApplyIsolation(runID string) {
+	prefix := config.IsolationPrefix()
+	config.NATSStreaming.ClientID = fmt.Sprintf("%s-%s-%s", prefix, runID, config.NATSStreaming.ClientID)
+
+	testersValue := reflect.ValueOf(&config.Testers).Elem()
+	for i := 0; i < testersValue.NumField(); i++ {
+		testerConfig := testersValue.Field(i)
+		if testerConfig.IsNil() {
+			continue
+		}
+
+		key := testersValue.Type().Field(i).Tag.Get("mapstructure")
+		for _, fieldName := range []string{"Stream", "Subject", "Channel"} {
+			field := testerConfig.Elem().FieldByName(fieldName)
+			if !field.IsValid() || field.Kind() != reflect.String {
+				continue
+			}
+
+			if fieldName == "Stream" {
+				field.SetString(IsolatedStreamName(prefix, runID, key, field.String()))
+			} else {
+				field.SetString(IsolatedSubject(prefix, runID, key, field.String()))
+			}
+		}
+	}
+
+	for _, scenario := range config.Scenarios {
+		scenario.Subject = IsolatedSubject(prefix, runID, scenario.Name, scenario.Subject)
+		if scenario.Transport != "jetstream" {
+			continue
+		}
+
+		// 沒有設定 Stream 名稱時原本會使用情境的名稱
+		if scenario.Stream == nil {
+			scenario.Stream = &ScenarioStreamConfig{}
+		}
+		streamName := scenario.Stream.Name
+		if streamName == "" {
+			streamName = scenario.Name
+		}
+
+		scenario.Stream.Name = IsolatedStreamName(prefix, runID, scenario.Name, streamName)
+	}
+}
+
+// IsolatedStreamName 隔離後的 Stream 名稱 (Stream 名稱不可包含 . 所以用 _ 連接)
+func IsolatedStreamName(prefix, runID, key, streamName string) string {
+	return strings.Join([]string{prefix, runID, sanitizeStreamNameToken(key), streamName}, "_")
+}
+
+// IsolatedSubject 隔離後的 Subject 或 Channel
+func IsolatedSubject(prefix, runID, key, subject string) string {
+	return strings.Join([]string{prefix, runID, key, subject}, ".")
+}
+
+// sanitizeStreamNameToken 將 Stream 名稱不能使用的字元換成 _
+func sanitizeStreamNameToken(token string) string {
+	return strings.Map(func(r rune) rune {
+		if strings.ContainsRune(" \t\r\n.*>/\\", r) {
+			return '_'
+		}
+		return r
+	}, token)
+}
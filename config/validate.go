@@ -0,0 +1,450 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"reflect"
+	"regexp"
+	"strings"
+
+	"golang.org/x/xerrors"
+)
+
+// scenarioOperations 每個 transport 支援的情境 operation
+var scenarioOperations = map[string][]string{
+	"jetstream": {"publish", "async_publish", "publish_latency", "subscribe", "chan_subscribe", "pull_subscribe", "consume", "load"},
+	"streaming": {"publish", "subscribe", "load"},
+	"nats":      {"publish", "subscribe", "request_reply", "load"},
+}
+
+// scenarioNamePattern 情境名稱可以使用的字元 (和 Tester 的 Key 相同，會用在 Stream 名稱和 Profile 的檔名)
+var scenarioNamePattern = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// minVerifyMessageSize 開啟驗證時訊息大小的下限 (需要放得下驗證用的標頭，即 utils.IntegrityHeaderSize)
+const minVerifyMessageSize = 16
+
+// isolationPrefixPattern 資源前綴可以使用的字元 (需要同時符合 Stream 名稱、Subject 和 Client ID 的規則)
+var isolationPrefixPattern = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// Validate 在連線前檢查設定 (啟用的 Tester 是否都有設定、次數和大小是否為正數、Subject 和 Stream 名稱是否合法等)，會一次回報所有錯誤
+func (config *Config) Validate() error {
+	v := &validator{verify: config.Verify}
+
+	v.validateConnection(config)
+	if config.Warmup < 0 {
+		v.addf("warmup 不可小於 0 (目前為 %d)", config.Warmup)
+	}
+	if config.Iterations < 0 {
+		v.addf("iterations 不可小於 0 (目前為 %d)", config.Iterations)
+	}
+	if config.Timeout < 0 {
+		v.addf("timeout 不可小於 0 (目前為 %v)", config.Timeout)
+	}
+	if config.Monitoring.Enabled {
+		v.validateMonitoring(config)
+	}
+
+	testerConfigs := testerConfigsByKey(&config.Testers)
+	scenarios := map[string]*ScenarioConfig{}
+	for idx, scenario := range config.Scenarios {
+		switch {
+		case scenario.Name == "":
+			v.addf("scenarios[%d].name 沒有設定", idx)
+		case scenarios[scenario.Name] != nil:
+			v.addf("scenarios[%d].name %s 重複", idx, scenario.Name)
+		default:
+			if !scenarioNamePattern.MatchString(scenario.Name) {
+				v.addf("scenarios[%d].name %q 只能包含英數字、_ 和 - (會用來命名 Stream 和 Profile 檔案)", idx, scenario.Name)
+			}
+			if _, ok := testerConfigs[scenario.Name]; ok {
+				v.addf("scenarios[%d].name %s 和 Tester 的 Key 重複", idx, scenario.Name)
+			}
+			scenarios[scenario.Name] = scenario
+		}
+	}
+
+	if len(config.EnabledTesters) == 0 {
+		v.addf("enabled_testers 沒有啟用任何 Tester")
+	}
+	for _, key := range config.EnabledTesters {
+		if scenario, ok := scenarios[key]; ok {
+			v.validateScenario(fmt.Sprintf("scenarios.%s", key), scenario)
+			continue
+		}
+
+		testerConfig, ok := testerConfigs[key]
+		if !ok {
+			v.addf("enabled_testers 中的 %s 不是已知的 Tester 或情境 (可使用 list 指令查看)", key)
+			continue
+		}
+		if testerConfig.IsNil() {
+			v.addf("testers.%s 沒有設定 (已在 enabled_testers 啟用)", key)
+			continue
+		}
+		v.validateTesterConfig(fmt.Sprintf("testers.%s", key), testerConfig.Elem())
+	}
+
+	if faultConfig := config.Testers.FaultInjectionTester; faultConfig != nil && containsString(config.EnabledTesters, "fault_injection_tester") {
+		// 故障測試一律會驗證訊息 (開啟 verify 時已經檢查過)
+		if !v.verify {
+			v.validateVerifyMessageSizes("testers.fault_injection_tester.message_sizes", faultConfig.MessageSizes)
+		}
+
+		// 內嵌的 NATS Streaming 使用 memory store 時，重啟後訊息和訂閱都會遺失
+		if config.Embedded.Enabled && containsString(faultConfig.Transports, "streaming") && strings.ToLower(config.Embedded.StoreType) != "file" {
+			v.addf("testers.fault_injection_tester.transports 包含 streaming 時 embedded.store_type 需要設為 file (重啟後 memory store 的訊息和訂閱都會遺失)")
+		}
+	}
+
+	return v.err()
+}
+
+// ValidateConnection 只檢查連線 (包含內嵌 Server) 和資源隔離的設定 (給不會執行 Tester 的指令使用，例如 cleanup)
+func (config *Config) ValidateConnection() error {
+	v := &validator{}
+	v.validateConnection(config)
+	return v.err()
+}
+
+// validateConnection 檢查連線、內嵌 Server 和資源前綴的設定
+func (v *validator) validateConnection(config *Config) {
+	if config.Embedded.ClusterSize < 0 {
+		v.addf("embedded.cluster_size 不可小於 0 (目前為 %d)", config.Embedded.ClusterSize)
+	}
+	if config.Isolation.Prefix != "" && !isolationPrefixPattern.MatchString(config.Isolation.Prefix) {
+		v.addf("isolation.prefix %q 只能包含英數字、_ 和 - (會用在 Stream 名稱和 Client ID)", config.Isolation.Prefix)
+	}
+	v.validateChoice("embedded.auth", config.Embedded.Auth, "", "user", "nkey", "jwt")
+	if !config.Embedded.Enabled {
+		if len(config.NATSJetStream.Servers) == 0 {
+			v.addf("nats_jet_stream.servers 沒有設定 (沒有啟用 embedded 時需要設定)")
+		}
+
+		// 啟用 embedded 時會改用自動產生的認證資料和憑證
+		jetStreamConfig, streamingConfig := config.NATSJetStream, config.NATSStreaming
+		v.validateSecurity("nats_jet_stream", jetStreamConfig.CredsFile, jetStreamConfig.NKeySeedFile, jetStreamConfig.TLS)
+		v.validateSecurity("nats_streaming", streamingConfig.CredsFile, streamingConfig.NKeySeedFile, streamingConfig.TLS)
+	}
+}
+
+// validateMonitoring 檢查監控端點的位置 (啟用 embedded 時會自動設定)
+func (v *validator) validateMonitoring(config *Config) {
+	if config.Monitoring.Timeout < 0 {
+		v.addf("monitoring.timeout 不可小於 0 (目前為 %v)", config.Monitoring.Timeout)
+	}
+	if config.Embedded.Enabled {
+		return
+	}
+
+	if len(config.Monitoring.NATSURLs) == 0 {
+		v.addf("monitoring.nats_urls 沒有設定 (啟用 monitoring 且沒有啟用 embedded 時需要設定)")
+	}
+	for idx, monitorURL := range config.Monitoring.NATSURLs {
+		v.validateHTTPURL(fmt.Sprintf("monitoring.nats_urls[%d]", idx), monitorURL)
+	}
+	if config.Monitoring.StreamingURL != "" {
+		v.validateHTTPURL("monitoring.streaming_url", config.Monitoring.StreamingURL)
+	}
+}
+
+// validateHTTPURL 檢查是否為 http 或 https 的位置
+func (v *validator) validateHTTPURL(path, rawURL string) {
+	parsedURL, err := url.Parse(rawURL)
+	if err != nil {
+		v.addf("%s %q 不是合法的位置: %v", path, rawURL, err)
+		return
+	}
+	if (parsedURL.Scheme != "http" && parsedURL.Scheme != "https") || parsedURL.Host == "" {
+		v.addf("%s %q 需要是 http 或 https 的位置 (例如 http://localhost:8222)", path, rawURL)
+	}
+}
+
+// testerConfigsByKey 以 Tester 的 Key (即 mapstructure 的名稱) 取得每個 Tester 的設定
+func testerConfigsByKey(testers *Testers) map[string]reflect.Value {
+	testerConfigs := map[string]reflect.Value{}
+	testersValue := reflect.ValueOf(testers).Elem()
+	for i := 0; i < testersValue.NumField(); i++ {
+		key := testersValue.Type().Field(i).Tag.Get("mapstructure")
+		testerConfigs[key] = testersValue.Field(i)
+	}
+	return testerConfigs
+}
+
+// validator 收集設定的錯誤
+type validator struct {
+	problems []string
+	verify   bool // 是否開啟訊息驗證 (訊息大小需要放得下驗證用的標頭)
+}
+
+func (v *validator) addf(format string, args ...interface{}) {
+	v.problems = append(v.problems, fmt.Sprintf(format, args...))
+}
+
+// err 將所有錯誤合併為一個 (沒有錯誤時回傳 nil)
+func (v *validator) err() error {
+	if len(v.problems) > 0 {
+		return xerrors.Errorf("設定有 %d 個錯誤:\n  - %s", len(v.problems), strings.Join(v.problems, "\n  - "))
+	}
+	return nil
+}
+
+// validateTesterConfig 依欄位名稱檢查 Tester 的設定 (所有 Tester 共用相同名稱的欄位)
+func (v *validator) validateTesterConfig(path string, testerConfig reflect.Value) {
+	for i := 0; i < testerConfig.NumField(); i++ {
+		field := testerConfig.Type().Field(i)
+		fieldPath := fmt.Sprintf("%s.%s", path, field.Tag.Get("mapstructure"))
+		value := testerConfig.Field(i).Interface()
+
+		switch field.Name {
+		case "Times", "Publishers", "Subscribers", "Responders":
+			v.validatePositive(fieldPath, value.(int))
+		case "MessageSizes":
+			v.validateMessageSizes(fieldPath, value.([]int))
+		case "FetchCounts", "Replicas", "Counts":
+			v.validatePositiveList(fieldPath, value.([]int))
+		case "MaxAckPendings":
+			for _, maxAckPending := range value.([]int) {
+				if maxAckPending < 0 {
+					v.addf("%s 不可小於 0 (目前為 %d)", fieldPath, maxAckPending)
+				}
+			}
+		case "Rates":
+			for _, rate := range value.([]float64) {
+				if rate < 0 {
+					v.addf("%s 不可小於 0 (目前為 %v)", fieldPath, rate)
+				}
+			}
+		case "DuplicateRatios":
+			if len(value.([]float64)) == 0 {
+				v.addf("%s 沒有設定", fieldPath)
+			}
+			for _, ratio := range value.([]float64) {
+				if ratio < 0 || ratio > 1 {
+					v.addf("%s 必須介於 0 到 1 (目前為 %v)", fieldPath, ratio)
+				}
+			}
+		case "Percentiles":
+			v.validatePercentiles(fieldPath, value.([]float64))
+		case "AckPolicies":
+			v.validateChoiceList(fieldPath, value.([]string), "none", "all", "explicit")
+		case "DeliverPolicies":
+			v.validateChoiceList(fieldPath, value.([]string), "all", "last", "new", "by_start_sequence", "by_start_time")
+		case "ReplayPolicies":
+			v.validateChoiceList(fieldPath, value.([]string), "instant", "original")
+		case "Retentions":
+			v.validateChoiceList(fieldPath, value.([]string), "limits", "interest", "workqueue")
+		case "Discards":
+			v.validateChoiceList(fieldPath, value.([]string), "old", "new")
+		case "Transports":
+			v.validateChoiceList(fieldPath, value.([]string), "jetstream", "streaming")
+		case "Subject", "Channel":
+			v.validateSubject(fieldPath, value.(string))
+		case "Stream":
+			v.validateStreamName(fieldPath, value.(string))
+		}
+	}
+}
+
+// validateScenario 檢查情境的設定
+func (v *validator) validateScenario(path string, scenario *ScenarioConfig) {
+	operations, ok := scenarioOperations[scenario.Transport]
+	if !ok {
+		v.addf("%s.transport 不支援 %q (可使用 jetstream, streaming, nats)", path, scenario.Transport)
+	} else if !containsString(operations, scenario.Operation) {
+		v.addf("%s.operation %s 不支援 %q (可使用 %s)", path, scenario.Transport, scenario.Operation, strings.Join(operations, ", "))
+	}
+
+	v.validateSubject(path+".subject", scenario.Subject)
+	v.validatePositive(path+".times", scenario.Times)
+	v.validateMessageSizes(path+".message_sizes", scenario.MessageSizes)
+	v.validatePercentiles(path+".percentiles", scenario.Percentiles)
+	if scenario.Publishers != nil {
+		v.validatePositive(path+".publishers", *scenario.Publishers)
+	}
+	if scenario.Subscribers != nil {
+		// load 可以沒有訂閱者 (只測發布)，request_reply 至少需要一個 Responder
+		if scenario.Operation == "request_reply" {
+			v.validatePositive(path+".subscribers", *scenario.Subscribers)
+		} else if *scenario.Subscribers < 0 {
+			v.addf("%s.subscribers 不可小於 0 (目前為 %d)", path, *scenario.Subscribers)
+		}
+	}
+
+	if scenario.Transport != "jetstream" {
+		return
+	}
+
+	// 沒有設定 Stream 名稱時會使用情境的名稱
+	streamName := scenario.Name
+	if stream := scenario.Stream; stream != nil {
+		if stream.Name != "" {
+			streamName = stream.Name
+		}
+		v.validateChoice(path+".stream.storage", stream.Storage, "", "file", "memory")
+		v.validateChoice(path+".stream.retention", stream.Retention, "", "limits", "interest", "workqueue")
+		v.validateChoice(path+".stream.discard", stream.Discard, "", "old", "new")
+		if stream.Replicas < 0 {
+			v.addf("%s.stream.replicas 不可小於 0 (目前為 %d)", path, stream.Replicas)
+		}
+	}
+	v.validateStreamName(path+".stream.name", streamName)
+
+	if consumer := scenario.Consumer; consumer != nil {
+		if consumer.Durable != "" {
+			v.validateStreamName(path+".consumer.durable", consumer.Durable)
+		}
+		v.validateChoice(path+".consumer.ack_policy", consumer.AckPolicy, "", "none", "all", "explicit")
+		v.validateChoice(path+".consumer.deliver_policy", consumer.DeliverPolicy, "", "all", "last", "new", "by_start_sequence", "by_start_time")
+		v.validateChoice(path+".consumer.replay_policy", consumer.ReplayPolicy, "", "instant", "original")
+		if consumer.MaxAckPending < 0 {
+			v.addf("%s.consumer.max_ack_pending 不可小於 0 (目前為 %d)", path, consumer.MaxAckPending)
+		}
+
+		// 只有 consume 會套用 Policy，其他 operation 的 Consumer 固定為 explicit Ack、從頭接收且立即重播
+		if scenario.Operation != "consume" {
+			v.validateFixedPolicy(path+".consumer.ack_policy", scenario.Operation, consumer.AckPolicy, "explicit")
+			v.validateFixedPolicy(path+".consumer.deliver_policy", scenario.Operation, consumer.DeliverPolicy, "all")
+			v.validateFixedPolicy(path+".consumer.replay_policy", scenario.Operation, consumer.ReplayPolicy, "instant")
+			if consumer.MaxAckPending > 0 && scenario.Operation != "pull_subscribe" {
+				v.addf("%s.consumer.max_ack_pending 只有 consume 和 pull_subscribe 會使用 (operation 為 %s)", path, scenario.Operation)
+			}
+		}
+		if consumer.FetchCount < 0 {
+			v.addf("%s.consumer.fetch_count 不可小於 0 (目前為 %d)", path, consumer.FetchCount)
+		}
+	}
+}
+
+// validateSecurity 檢查認證和 TLS 使用的檔案是否存在
+func (v *validator) validateSecurity(path, credsFile, nkeySeedFile string, tlsConfig TLSConfig) {
+	if credsFile != "" && nkeySeedFile != "" {
+		v.addf("%s.creds_file 和 %s.nkey_seed_file 只能擇一設定", path, path)
+	}
+	if (tlsConfig.CertFile == "") != (tlsConfig.KeyFile == "") {
+		v.addf("%s.tls.cert_file 和 %s.tls.key_file 需要同時設定", path, path)
+	}
+
+	files := map[string]string{
+		"creds_file":     credsFile,
+		"nkey_seed_file": nkeySeedFile,
+		"tls.ca_file":    tlsConfig.CAFile,
+		"tls.cert_file":  tlsConfig.CertFile,
+		"tls.key_file":   tlsConfig.KeyFile,
+	}
+	for _, key := range []string{"creds_file", "nkey_seed_file", "tls.ca_file", "tls.cert_file", "tls.key_file"} {
+		if file := files[key]; file != "" {
+			if _, err := os.Stat(file); err != nil {
+				v.addf("%s.%s 無法讀取 %s", path, key, file)
+			}
+		}
+	}
+}
+
+func (v *validator) validatePositive(path string, value int) {
+	if value <= 0 {
+		v.addf("%s 必須大於 0 (目前為 %d)", path, value)
+	}
+}
+
+func (v *validator) validatePositiveList(path string, values []int) {
+	if len(values) == 0 {
+		v.addf("%s 沒有設定", path)
+	}
+	for _, value := range values {
+		v.validatePositive(path, value)
+	}
+}
+
+// validateMessageSizes 檢查訊息大小 (verify 時需要放得下驗證用的標頭)
+func (v *validator) validateMessageSizes(path string, sizes []int) {
+	v.validatePositiveList(path, sizes)
+	if v.verify {
+		v.validateVerifyMessageSizes(path, sizes)
+	}
+}
+
+// validateVerifyMessageSizes 檢查訊息大小是否放得下驗證用的標頭
+func (v *validator) validateVerifyMessageSizes(path string, sizes []int) {
+	for _, size := range sizes {
+		if size > 0 && size < minVerifyMessageSize {
+			v.addf("%s 開啟驗證時不可小於 %d (目前為 %d，需要放得下序號和 Checksum)", path, minVerifyMessageSize, size)
+		}
+	}
+}
+
+func (v *validator) validatePercentiles(path string, percentiles []float64) {
+	for _, percentile := range percentiles {
+		if percentile <= 0 || percentile > 100 {
+			v.addf("%s 必須介於 0 到 100 (目前為 %v)", path, percentile)
+		}
+	}
+}
+
+func (v *validator) validateChoice(path, value string, choices ...string) {
+	if !containsString(choices, value) {
+		v.addf("%s 不支援 %q", path, value)
+	}
+}
+
+// validateFixedPolicy 檢查不支援調整的 Policy 只能是空字串或固定使用的值
+func (v *validator) validateFixedPolicy(path, operation, value, fixed string) {
+	if value != "" && value != fixed {
+		v.addf("%s 只有 consume 可以調整 (%s 固定為 %s，目前為 %q)", path, operation, fixed, value)
+	}
+}
+
+// validateChoiceList 檢查測試組合的每個選項 (空的清單不會產生任何測試，所以也視為錯誤)
+func (v *validator) validateChoiceList(path string, values []string, choices ...string) {
+	if len(values) == 0 {
+		v.addf("%s 沒有設定", path)
+	}
+	for _, value := range values {
+		if !containsString(choices, value) {
+			v.addf("%s 不支援 %q (可使用 %s)", path, value, strings.Join(choices, ", "))
+		}
+	}
+}
+
+// validateSubject 檢查 Subject (或 Streaming 的 Channel) 是否合法，因為會用來發布所以不可包含萬用字元
+func (v *validator) validateSubject(path, subject string) {
+	if subject == "" {
+		v.addf("%s 沒有設定", path)
+		return
+	}
+	if strings.ContainsAny(subject, " \t\r\n") {
+		v.addf("%s %q 不可包含空白字元", path, subject)
+		return
+	}
+
+	for _, token := range strings.Split(subject, ".") {
+		switch token {
+		case "":
+			v.addf("%s %q 不可有空的片段 (開頭、結尾或連續的 .)", path, subject)
+			return
+		case "*", ">":
+			v.addf("%s %q 不可包含萬用字元", path, subject)
+			return
+		}
+	}
+}
+
+// validateStreamName 檢查 Stream (或 Consumer) 名稱是否合法
+func (v *validator) validateStreamName(path, name string) {
+	if name == "" {
+		v.addf("%s 沒有設定", path)
+		return
+	}
+	if strings.ContainsAny(name, " \t\r\n.*>/\\") {
+		v.addf("%s %q 不可包含空白字元或 . * > / \\", path, name)
+	}
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,456 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// newValidConfig 建立可以通過檢查的設定 (只啟用 nats_publish_tester 和 jetstream_consumer_tester)
+func newValidConfig() *Config {
+	return &Config{
+		NATSJetStream: NATSJetStreamConfig{
+			Servers: []string{"nats://localhost:4222"},
+		},
+		EnabledTesters: []string{"nats_publish_tester", "jetstream_consumer_tester"},
+		Testers: Testers{
+			NATSPublishTester: &NATSPublishTesterConfig{
+				Subject:      "nats_publish_tester",
+				Times:        10,
+				MessageSizes: []int{1, 64},
+			},
+			JetStreamConsumerTester: &JetStreamConsumerTesterConfig{
+				Stream:          "test_jetstream_consumer",
+				Subject:         "test_jetstream_consumer",
+				Times:           10,
+				MessageSizes:    []int{100},
+				AckPolicies:     []string{"none", "all", "explicit"},
+				DeliverPolicies: []string{"all", "last", "new", "by_start_sequence", "by_start_time"},
+				MaxAckPendings:  []int{0, 10},
+				ReplayPolicies:  []string{"instant", "original"},
+			},
+		},
+	}
+}
+
+// withScenario 加入並啟用 JetStream 的情境
+func withScenario(config *Config, scenario *ScenarioConfig) {
+	config.Scenarios = append(config.Scenarios, scenario)
+	config.EnabledTesters = append(config.EnabledTesters, scenario.Name)
+}
+
+func newValidScenario() *ScenarioConfig {
+	return &ScenarioConfig{
+		Name:         "my_scenario",
+		Transport:    "jetstream",
+		Operation:    "consume",
+		Subject:      "my.scenario",
+		Times:        10,
+		MessageSizes: []int{64},
+		Stream:       &ScenarioStreamConfig{Storage: "memory"},
+		Consumer:     &ScenarioConsumerConfig{AckPolicy: "all"},
+	}
+}
+
+func TestValidate(t *testing.T) {
+	dir := newTempDir(t)
+	existingFile := filepath.Join(dir, "exists.pem")
+	if err := ioutil.WriteFile(existingFile, []byte("test"), 0600); err != nil {
+		t.Fatalf("建立檔案失敗: %v", err)
+	}
+	missingFile := filepath.Join(dir, "missing.pem")
+
+	testCases := []struct {
+		name         string
+		modify       func(config *Config)
+		wantProblems []string // 錯誤訊息需要包含的內容 (空的代表應該通過檢查)
+	}{
+		{
+			name:   "valid",
+			modify: func(config *Config) {},
+		},
+		{
+			name:   "valid scenario",
+			modify: func(config *Config) { withScenario(config, newValidScenario()) },
+		},
+		{
+			name: "readable security files",
+			modify: func(config *Config) {
+				config.NATSJetStream.CredsFile = existingFile
+				config.NATSJetStream.TLS = TLSConfig{CAFile: existingFile, CertFile: existingFile, KeyFile: existingFile}
+			},
+		},
+		{
+			name:         "no enabled testers",
+			modify:       func(config *Config) { config.EnabledTesters = nil },
+			wantProblems: []string{"enabled_testers 沒有啟用任何 Tester"},
+		},
+		{
+			name:         "enabled tester without config",
+			modify:       func(config *Config) { config.Testers.NATSPublishTester = nil },
+			wantProblems: []string{"testers.nats_publish_tester 沒有設定 (已在 enabled_testers 啟用)"},
+		},
+		{
+			name:         "unknown enabled tester",
+			modify:       func(config *Config) { config.EnabledTesters = append(config.EnabledTesters, "no_such_tester") },
+			wantProblems: []string{"enabled_testers 中的 no_such_tester 不是已知的 Tester 或情境"},
+		},
+		{
+			name:         "subject with wildcard",
+			modify:       func(config *Config) { config.Testers.NATSPublishTester.Subject = "test.*" },
+			wantProblems: []string{`testers.nats_publish_tester.subject "test.*" 不可包含萬用字元`},
+		},
+		{
+			name:         "subject with full wildcard",
+			modify:       func(config *Config) { config.Testers.JetStreamConsumerTester.Subject = "test.>" },
+			wantProblems: []string{`testers.jetstream_consumer_tester.subject "test.>" 不可包含萬用字元`},
+		},
+		{
+			name:         "subject with space",
+			modify:       func(config *Config) { config.Testers.NATSPublishTester.Subject = "test subject" },
+			wantProblems: []string{`testers.nats_publish_tester.subject "test subject" 不可包含空白字元`},
+		},
+		{
+			name:         "subject with empty token",
+			modify:       func(config *Config) { config.Testers.NATSPublishTester.Subject = "test..subject" },
+			wantProblems: []string{`testers.nats_publish_tester.subject "test..subject" 不可有空的片段`},
+		},
+		{
+			name:         "empty subject",
+			modify:       func(config *Config) { config.Testers.NATSPublishTester.Subject = "" },
+			wantProblems: []string{"testers.nats_publish_tester.subject 沒有設定"},
+		},
+		{
+			name:         "stream name with dot",
+			modify:       func(config *Config) { config.Testers.JetStreamConsumerTester.Stream = "test.stream" },
+			wantProblems: []string{`testers.jetstream_consumer_tester.stream "test.stream" 不可包含空白字元或 . * > / \`},
+		},
+		{
+			name:         "stream name with space",
+			modify:       func(config *Config) { config.Testers.JetStreamConsumerTester.Stream = "test stream" },
+			wantProblems: []string{`testers.jetstream_consumer_tester.stream "test stream" 不可包含空白字元`},
+		},
+		{
+			name:         "stream name with wildcard",
+			modify:       func(config *Config) { config.Testers.JetStreamConsumerTester.Stream = "test*" },
+			wantProblems: []string{`testers.jetstream_consumer_tester.stream "test*"`},
+		},
+		{
+			name:         "zero times",
+			modify:       func(config *Config) { config.Testers.NATSPublishTester.Times = 0 },
+			wantProblems: []string{"testers.nats_publish_tester.times 必須大於 0 (目前為 0)"},
+		},
+		{
+			name:         "negative times",
+			modify:       func(config *Config) { config.Testers.NATSPublishTester.Times = -1 },
+			wantProblems: []string{"testers.nats_publish_tester.times 必須大於 0 (目前為 -1)"},
+		},
+		{
+			name:         "zero message size",
+			modify:       func(config *Config) { config.Testers.NATSPublishTester.MessageSizes = []int{64, 0} },
+			wantProblems: []string{"testers.nats_publish_tester.message_sizes 必須大於 0 (目前為 0)"},
+		},
+		{
+			name:         "negative message size",
+			modify:       func(config *Config) { config.Testers.NATSPublishTester.MessageSizes = []int{-64} },
+			wantProblems: []string{"testers.nats_publish_tester.message_sizes 必須大於 0 (目前為 -64)"},
+		},
+		{
+			name:         "empty message sizes",
+			modify:       func(config *Config) { config.Testers.NATSPublishTester.MessageSizes = nil },
+			wantProblems: []string{"testers.nats_publish_tester.message_sizes 沒有設定"},
+		},
+		{
+			name: "message size too small for verify",
+			modify: func(config *Config) {
+				config.Verify = true
+				config.Testers.JetStreamConsumerTester.MessageSizes = []int{16}
+			},
+			wantProblems: []string{"testers.nats_publish_tester.message_sizes 開啟驗證時不可小於 16 (目前為 1"},
+		},
+		{
+			name: "bad ack policy",
+			modify: func(config *Config) {
+				config.Testers.JetStreamConsumerTester.AckPolicies = []string{"explicit", "always"}
+			},
+			wantProblems: []string{`testers.jetstream_consumer_tester.ack_policies 不支援 "always"`},
+		},
+		{
+			name:         "bad deliver policy",
+			modify:       func(config *Config) { config.Testers.JetStreamConsumerTester.DeliverPolicies = []string{"first"} },
+			wantProblems: []string{`testers.jetstream_consumer_tester.deliver_policies 不支援 "first"`},
+		},
+		{
+			name:         "bad replay policy",
+			modify:       func(config *Config) { config.Testers.JetStreamConsumerTester.ReplayPolicies = []string{"fast"} },
+			wantProblems: []string{`testers.jetstream_consumer_tester.replay_policies 不支援 "fast"`},
+		},
+		{
+			name:         "empty policy list",
+			modify:       func(config *Config) { config.Testers.JetStreamConsumerTester.AckPolicies = nil },
+			wantProblems: []string{"testers.jetstream_consumer_tester.ack_policies 沒有設定"},
+		},
+		{
+			name: "bad retention",
+			modify: func(config *Config) {
+				config.EnabledTesters = append(config.EnabledTesters, "jetstream_retention_tester")
+				config.Testers.JetStreamRetentionTester = &JetStreamRetentionTesterConfig{
+					Stream:       "test_jetstream_retention",
+					Subject:      "test_jetstream_retention",
+					Times:        10,
+					MessageSizes: []int{100},
+					Retentions:   []string{"limits", "forever"},
+					Discards:     []string{"old"},
+				}
+			},
+			wantProblems: []string{`testers.jetstream_retention_tester.retentions 不支援 "forever"`},
+		},
+		{
+			name: "bad scenario storage",
+			modify: func(config *Config) {
+				scenario := newValidScenario()
+				scenario.Stream.Storage = "disk"
+				withScenario(config, scenario)
+			},
+			wantProblems: []string{`scenarios.my_scenario.stream.storage 不支援 "disk"`},
+		},
+		{
+			name: "bad scenario consumer policies",
+			modify: func(config *Config) {
+				scenario := newValidScenario()
+				scenario.Consumer = &ScenarioConsumerConfig{AckPolicy: "some", DeliverPolicy: "first", ReplayPolicy: "fast"}
+				withScenario(config, scenario)
+			},
+			wantProblems: []string{
+				`scenarios.my_scenario.consumer.ack_policy 不支援 "some"`,
+				`scenarios.my_scenario.consumer.deliver_policy 不支援 "first"`,
+				`scenarios.my_scenario.consumer.replay_policy 不支援 "fast"`,
+			},
+		},
+		{
+			name: "policy on operation that ignores it",
+			modify: func(config *Config) {
+				scenario := newValidScenario()
+				scenario.Operation = "subscribe"
+				withScenario(config, scenario)
+			},
+			wantProblems: []string{"scenarios.my_scenario.consumer.ack_policy 只有 consume 可以調整"},
+		},
+		{
+			name: "publisher only load scenario",
+			modify: func(config *Config) {
+				scenario := newValidScenario()
+				scenario.Operation = "load"
+				scenario.Consumer = nil
+				scenario.Subscribers = intPointer(0)
+				withScenario(config, scenario)
+			},
+		},
+		{
+			name: "load scenario without publishers",
+			modify: func(config *Config) {
+				scenario := newValidScenario()
+				scenario.Operation = "load"
+				scenario.Consumer = nil
+				scenario.Publishers = intPointer(0)
+				scenario.Subscribers = intPointer(-1)
+				withScenario(config, scenario)
+			},
+			wantProblems: []string{
+				"scenarios.my_scenario.publishers 必須大於 0 (目前為 0)",
+				"scenarios.my_scenario.subscribers 不可小於 0 (目前為 -1)",
+			},
+		},
+		{
+			name: "request reply scenario without responders",
+			modify: func(config *Config) {
+				withScenario(config, &ScenarioConfig{
+					Name:         "my_request_reply",
+					Transport:    "nats",
+					Operation:    "request_reply",
+					Subject:      "my.request",
+					Times:        10,
+					MessageSizes: []int{64},
+					Subscribers:  intPointer(0),
+				})
+			},
+			wantProblems: []string{"scenarios.my_request_reply.subscribers 必須大於 0 (目前為 0)"},
+		},
+		{
+			name: "unsafe scenario name",
+			modify: func(config *Config) {
+				scenario := newValidScenario()
+				scenario.Name = "../my scenario"
+				scenario.Stream.Name = "my_stream"
+				withScenario(config, scenario)
+			},
+			wantProblems: []string{`scenarios[0].name "../my scenario" 只能包含英數字、_ 和 -`},
+		},
+		{
+			name:         "unreadable creds file",
+			modify:       func(config *Config) { config.NATSJetStream.CredsFile = missingFile },
+			wantProblems: []string{"nats_jet_stream.creds_file 無法讀取 " + missingFile},
+		},
+		{
+			name:         "unreadable nkey seed file",
+			modify:       func(config *Config) { config.NATSStreaming.NKeySeedFile = missingFile },
+			wantProblems: []string{"nats_streaming.nkey_seed_file 無法讀取 " + missingFile},
+		},
+		{
+			name:         "unreadable tls ca file",
+			modify:       func(config *Config) { config.NATSJetStream.TLS.CAFile = missingFile },
+			wantProblems: []string{"nats_jet_stream.tls.ca_file 無法讀取 " + missingFile},
+		},
+		{
+			name:         "tls cert without key",
+			modify:       func(config *Config) { config.NATSStreaming.TLS.CertFile = existingFile },
+			wantProblems: []string{"nats_streaming.tls.cert_file 和 nats_streaming.tls.key_file 需要同時設定"},
+		},
+		{
+			name: "security files are not checked with embedded",
+			modify: func(config *Config) {
+				config.Embedded.Enabled = true
+				config.NATSJetStream.CredsFile = missingFile
+			},
+		},
+		{
+			name:         "negative iterations",
+			modify:       func(config *Config) { config.Iterations = -1 },
+			wantProblems: []string{"iterations 不可小於 0 (目前為 -1)"},
+		},
+		{
+			name: "all problems reported together",
+			modify: func(config *Config) {
+				config.Testers.NATSPublishTester.Times = 0
+				config.Testers.NATSPublishTester.Subject = "test.*"
+				config.Testers.JetStreamConsumerTester.Stream = "test.stream"
+				config.Testers.JetStreamConsumerTester.AckPolicies = []string{"always"}
+				config.NATSJetStream.TLS.CAFile = missingFile
+				config.EnabledTesters = append(config.EnabledTesters, "no_such_tester")
+			},
+			wantProblems: []string{
+				"設定有 6 個錯誤",
+				"testers.nats_publish_tester.times 必須大於 0",
+				`testers.nats_publish_tester.subject "test.*" 不可包含萬用字元`,
+				`testers.jetstream_consumer_tester.stream "test.stream"`,
+				`testers.jetstream_consumer_tester.ack_policies 不支援 "always"`,
+				"nats_jet_stream.tls.ca_file 無法讀取",
+				"enabled_testers 中的 no_such_tester",
+			},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			config := newValidConfig()
+			testCase.modify(config)
+
+			err := config.Validate()
+			assertProblems(t, err, testCase.wantProblems)
+		})
+	}
+}
+
+func TestValidateConnection(t *testing.T) {
+	missingFile := filepath.Join(newTempDir(t), "missing.creds")
+
+	testCases := []struct {
+		name         string
+		modify       func(config *Config)
+		wantProblems []string
+	}{
+		{
+			name:   "valid",
+			modify: func(config *Config) {},
+		},
+		{
+			name: "tester problems are ignored",
+			modify: func(config *Config) {
+				config.EnabledTesters = []string{"no_such_tester"}
+				config.Testers.NATSPublishTester.Times = 0
+			},
+		},
+		{
+			name:         "no servers",
+			modify:       func(config *Config) { config.NATSJetStream.Servers = nil },
+			wantProblems: []string{"nats_jet_stream.servers 沒有設定"},
+		},
+		{
+			name:         "bad isolation prefix",
+			modify:       func(config *Config) { config.Isolation.Prefix = "njt.test" },
+			wantProblems: []string{`isolation.prefix "njt.test" 只能包含英數字、_ 和 -`},
+		},
+		{
+			name:         "bad embedded auth",
+			modify:       func(config *Config) { config.Embedded.Auth = "token" },
+			wantProblems: []string{`embedded.auth 不支援 "token"`},
+		},
+		{
+			name:         "unreadable creds file",
+			modify:       func(config *Config) { config.NATSStreaming.CredsFile = missingFile },
+			wantProblems: []string{"nats_streaming.creds_file 無法讀取 " + missingFile},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			config := newValidConfig()
+			testCase.modify(config)
+
+			err := config.ValidateConnection()
+			assertProblems(t, err, testCase.wantProblems)
+		})
+	}
+}
+
+// assertProblems 確認錯誤包含所有預期的問題，且每個問題都有對應的預期 (wantProblems 為空時不應該有錯誤)
+func assertProblems(t *testing.T, err error, wantProblems []string) {
+	t.Helper()
+
+	if len(wantProblems) == 0 {
+		if err != nil {
+			t.Fatalf("不應該回傳錯誤: %v", err)
+		}
+		return
+	}
+	if err == nil {
+		t.Fatalf("應該回傳錯誤 (預期包含 %q)", wantProblems)
+	}
+
+	message := err.Error()
+	for _, want := range wantProblems {
+		if !strings.Contains(message, want) {
+			t.Errorf("錯誤沒有包含 %q:\n%s", want, message)
+		}
+	}
+
+	// 除了總數以外，錯誤的數量需要和預期的相同 (避免多出不相關的錯誤)
+	problemCount := strings.Count(message, "\n  - ")
+	wantCount := len(wantProblems)
+	if strings.HasPrefix(wantProblems[0], "設定有") {
+		wantCount--
+	}
+	if problemCount != wantCount {
+		t.Errorf("錯誤有 %d 個，預期為 %d 個:\n%s", problemCount, wantCount, message)
+	}
+}
+
+// newTempDir 建立測試結束後會刪除的暫存資料夾
+func newTempDir(t *testing.T) string {
+	t.Helper()
+
+	dir, err := ioutil.TempDir("", "njt-config-test")
+	if err != nil {
+		t.Fatalf("建立暫存資料夾失敗: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = os.RemoveAll(dir)
+	})
+	return dir
+}
+
+func intPointer(value int) *int {
+	return &value
+}
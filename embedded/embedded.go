@@ -0,0 +1,398 @@
+package embedded
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/nats-io/nats-server/v2/server"
+	stand "github.com/nats-io/nats-streaming-server/server"
+	"github.com/nats-io/nats-streaming-server/stores"
+	"golang.org/x/xerrors"
+
+	"github.com/marco79423/nats-jetstream-test/config"
+)
+
+// ClusterName 內嵌 JetStream Cluster 的名稱
+const ClusterName = "nats-jetstream-test"
+
+// Servers 內嵌 (In-Process) 的 NATS Server 和 NATS Streaming Server
+type Servers struct {
+	natsServers     []*server.Server  // Cluster 模式下會有多個節點，NATS Streaming 連到第一個節點
+	natsOptions     []*server.Options // 重啟時使用相同的設定 (Port 會固定為第一次啟動時的 Port)
+	stanServer      *stand.StanServer
+	stanOptions     *stand.Options // 重啟時使用相同的設定
+	stanNATSOptions server.Options // NATS Streaming 自己的 HTTP Server 設定 (監控端點)
+	security        *security      // TLS 和認證設定
+
+	monitoring           bool // 是否開啟監控端點 (HTTP)
+	streamingMonitorPort int  // NATS Streaming 監控端點的 Port (NATS Streaming 連到外部的 NATS Server 時會自己開啟 HTTP Server)
+
+	storeDir       string
+	removeStoreDir bool // 暫存資料夾需要在結束時刪除
+}
+
+// Start 啟動內嵌的 NATS Server (開啟 JetStream) 和 NATS Streaming Server，並將設定檔的連線位置改為內嵌的 Server
+//
+// cluster_size 大於 1 時會啟動多個 NATS Server 組成 JetStream Cluster
+func Start(conf *config.Config) (*Servers, error) {
+	servers := &Servers{
+		storeDir:   conf.Embedded.StoreDir,
+		monitoring: conf.Monitoring.Enabled,
+	}
+
+	// 沒有指定資料夾就使用暫存資料夾
+	if servers.storeDir == "" {
+		storeDir, err := ioutil.TempDir("", "nats-jetstream-test-")
+		if err != nil {
+			return nil, xerrors.Errorf("建立暫存資料夾失敗: %w", err)
+		}
+		servers.storeDir = storeDir
+		servers.removeStoreDir = true
+	}
+
+	sec, err := newSecurity(conf, filepath.Join(servers.storeDir, "security"))
+	if err != nil {
+		servers.Shutdown()
+		return nil, xerrors.Errorf("產生 TLS 和認證設定失敗: %w", err)
+	}
+	servers.security = sec
+
+	if conf.Embedded.ClusterSize > 1 {
+		if err := servers.startNATSCluster(conf); err != nil {
+			servers.Shutdown()
+			return nil, xerrors.Errorf("啟動內嵌的 JetStream Cluster 失敗: %w", err)
+		}
+	} else {
+		if err := servers.startNATSServer(conf); err != nil {
+			servers.Shutdown()
+			return nil, xerrors.Errorf("啟動內嵌的 NATS Server 失敗: %w", err)
+		}
+	}
+
+	if err := servers.startSTANServer(conf); err != nil {
+		servers.Shutdown()
+		return nil, xerrors.Errorf("啟動內嵌的 NATS Streaming Server 失敗: %w", err)
+	}
+
+	conf.NATSJetStream.Servers = servers.ClientURLs()
+	conf.NATSStreaming.Servers = []string{servers.ClientURL()}
+	servers.security.applyClientConfig(conf)
+	if servers.monitoring {
+		conf.Monitoring.NATSURLs = servers.MonitorURLs()
+		conf.Monitoring.StreamingURL = servers.StreamingMonitorURL()
+	}
+
+	fmt.Printf("內嵌的 NATS Server 已啟動 (位置: %s, 資料夾: %s)\n", strings.Join(servers.ClientURLs(), ","), servers.storeDir)
+	if servers.monitoring {
+		fmt.Printf("內嵌 Server 的監控端點 (NATS: %s, Streaming: %s)\n", strings.Join(servers.MonitorURLs(), ","), servers.StreamingMonitorURL())
+	}
+	return servers, nil
+}
+
+// ClientURL 內嵌 NATS Server 的連線位置 (Cluster 模式下為第一個節點)
+func (servers *Servers) ClientURL() string {
+	return servers.natsServers[0].ClientURL()
+}
+
+// ClientURLs 所有內嵌 NATS Server 節點的連線位置
+func (servers *Servers) ClientURLs() []string {
+	var clientURLs []string
+	for _, natsServer := range servers.natsServers {
+		clientURLs = append(clientURLs, natsServer.ClientURL())
+	}
+	return clientURLs
+}
+
+// MonitorURLs 所有內嵌 NATS Server 節點的監控位置 (沒有開啟監控時為空)
+func (servers *Servers) MonitorURLs() []string {
+	var monitorURLs []string
+	for _, natsServer := range servers.natsServers {
+		if monitorAddr := natsServer.MonitorAddr(); monitorAddr != nil {
+			monitorURLs = append(monitorURLs, fmt.Sprintf("http://%s", monitorAddr))
+		}
+	}
+	return monitorURLs
+}
+
+// StreamingMonitorURL 內嵌 NATS Streaming Server 的監控位置 (沒有開啟監控時為空字串)
+func (servers *Servers) StreamingMonitorURL() string {
+	if servers.streamingMonitorPort == 0 {
+		return ""
+	}
+	return fmt.Sprintf("http://127.0.0.1:%d", servers.streamingMonitorPort)
+}
+
+// Shutdown 關閉內嵌的 Server (若使用暫存資料夾也會一併刪除)
+func (servers *Servers) Shutdown() {
+	if servers.stanServer != nil {
+		servers.stanServer.Shutdown()
+		servers.stanServer = nil
+	}
+
+	for _, natsServer := range servers.natsServers {
+		if natsServer != nil {
+			natsServer.Shutdown()
+			natsServer.WaitForShutdown()
+		}
+	}
+	servers.natsServers = nil
+
+	if servers.removeStoreDir {
+		_ = os.RemoveAll(servers.storeDir)
+	}
+}
+
+func (servers *Servers) startNATSServer(conf *config.Config) error {
+	port := conf.Embedded.Port
+	if port == 0 {
+		port = server.RANDOM_PORT
+	}
+
+	if err := servers.addNATSServer(&server.Options{
+		Host:      "127.0.0.1",
+		Port:      port,
+		NoSigs:    true,
+		JetStream: true,
+		StoreDir:  servers.storeDir,
+	}); err != nil {
+		return xerrors.Errorf("啟動 NATS Server 失敗: %w", err)
+	}
+
+	return nil
+}
+
+// startNATSCluster 啟動多個 NATS Server 組成 JetStream Cluster (每個節點都設定所有節點的 Route)
+func (servers *Servers) startNATSCluster(conf *config.Config) error {
+	clusterSize := conf.Embedded.ClusterSize
+
+	// JetStream Cluster 啟動時就需要設定 Route，所以要先決定所有節點的 Cluster Port
+	clusterPorts := make([]int, clusterSize)
+	routes := make([]*url.URL, clusterSize)
+	for i := range clusterPorts {
+		port, err := getFreePort()
+		if err != nil {
+			return xerrors.Errorf("取得 Cluster Port 失敗: %w", err)
+		}
+		clusterPorts[i] = port
+		routes[i] = &url.URL{Scheme: "nats", Host: fmt.Sprintf("127.0.0.1:%d", port)}
+	}
+
+	for i := 0; i < clusterSize; i++ {
+		port := server.RANDOM_PORT
+		if i == 0 && conf.Embedded.Port != 0 {
+			port = conf.Embedded.Port
+		}
+
+		serverName := fmt.Sprintf("n%d", i+1)
+		if err := servers.addNATSServer(&server.Options{
+			ServerName: serverName,
+			Host:       "127.0.0.1",
+			Port:       port,
+			NoSigs:     true,
+			JetStream:  true,
+			StoreDir:   filepath.Join(servers.storeDir, serverName),
+			Cluster: server.ClusterOpts{
+				Name: ClusterName,
+				Host: "127.0.0.1",
+				Port: clusterPorts[i],
+			},
+			Routes: routes,
+		}); err != nil {
+			return xerrors.Errorf("啟動 NATS Server %s 失敗: %w", serverName, err)
+		}
+	}
+
+	// 需要等所有節點加入且選出 Meta Leader 後才能建立 Stream
+	if err := servers.waitForClusterReady(context.Background(), clusterSize); err != nil {
+		return xerrors.Errorf("等待 JetStream Cluster 就緒失敗: %w", err)
+	}
+
+	return nil
+}
+
+// waitForClusterReady 等待 JetStream Cluster 選出 Meta Leader 且所有節點都已加入 (ctx 取消時回傳錯誤)
+func (servers *Servers) waitForClusterReady(ctx context.Context, clusterSize int) error {
+	deadline := time.Now().Add(30 * time.Second)
+	for !servers.isClusterReady(clusterSize) {
+		if time.Now().After(deadline) {
+			return xerrors.New("等待 JetStream Cluster 選出 Leader 逾時")
+		}
+
+		select {
+		case <-time.After(100 * time.Millisecond):
+		case <-ctx.Done():
+			return xerrors.Errorf("等待 JetStream Cluster 選出 Leader 被中斷: %w", ctx.Err())
+		}
+	}
+	return nil
+}
+
+// RestartNATSServer 關閉指定的 NATS Server 節點，等待 downtime 後以相同的 Port 和資料夾重新啟動 (用來模擬 Server 故障)
+//
+// ctx 取消時不再等待 downtime 而是立即重新啟動 (避免之後的測試沒有 Server 可用)，Cluster 模式下也不再等待選出 Leader
+func (servers *Servers) RestartNATSServer(ctx context.Context, index int, downtime time.Duration) error {
+	if index < 0 || index >= len(servers.natsServers) {
+		return xerrors.Errorf("不存在的 NATS Server 節點 %d", index)
+	}
+
+	natsServer := servers.natsServers[index]
+	natsServer.Shutdown()
+	natsServer.WaitForShutdown()
+	servers.natsServers[index] = nil
+
+	timer := time.NewTimer(downtime)
+	select {
+	case <-timer.C:
+	case <-ctx.Done():
+		timer.Stop()
+	}
+
+	natsServer, err := runNATSServer(servers.natsOptions[index])
+	if err != nil {
+		return xerrors.Errorf("重新啟動 NATS Server 失敗: %w", err)
+	}
+	servers.natsServers[index] = natsServer
+
+	// Cluster 模式下需要等重新選出 Leader (ctx 取消時不視為失敗，Server 已經重新啟動)
+	if len(servers.natsServers) > 1 {
+		if err := servers.waitForClusterReady(ctx, len(servers.natsServers)); err != nil && ctx.Err() == nil {
+			return xerrors.Errorf("重新啟動 NATS Server 失敗: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// RestartNATSAndStreamingServer 關閉 NATS Streaming Server 和指定的 NATS Server 節點，等待 downtime 後依序重新啟動 (用來模擬兩者一起故障)
+//
+// NATS Streaming 使用 memory store 時重啟後會遺失所有的訊息和訂閱，需要使用 file store 才能測試訊息保證
+func (servers *Servers) RestartNATSAndStreamingServer(ctx context.Context, index int, downtime time.Duration) error {
+	if servers.stanServer != nil {
+		servers.stanServer.Shutdown()
+		servers.stanServer = nil
+	}
+
+	if err := servers.RestartNATSServer(ctx, index, downtime); err != nil {
+		return xerrors.Errorf("重啟 NATS Server 失敗: %w", err)
+	}
+
+	if err := servers.runSTANServer(); err != nil {
+		return xerrors.Errorf("重新啟動 NATS Streaming Server 失敗: %w", err)
+	}
+	return nil
+}
+
+// addNATSServer 啟動 NATS Server 並記錄設定 (重啟用)
+func (servers *Servers) addNATSServer(opts *server.Options) error {
+	if err := servers.security.applyServerOptions(opts); err != nil {
+		return xerrors.Errorf("設定 TLS 和認證失敗: %w", err)
+	}
+	if servers.monitoring {
+		opts.HTTPHost = "127.0.0.1"
+		opts.HTTPPort = server.RANDOM_PORT
+	}
+
+	natsServer, err := runNATSServer(opts)
+	if err != nil {
+		return xerrors.Errorf("啟動 NATS Server 失敗: %w", err)
+	}
+
+	// 固定 Port，重啟後 Client 才能重連回來 (監控端點的位置也不會改變)
+	opts.Port = natsServer.Addr().(*net.TCPAddr).Port
+	if monitorAddr := natsServer.MonitorAddr(); monitorAddr != nil {
+		opts.HTTPPort = monitorAddr.Port
+	}
+
+	servers.natsServers = append(servers.natsServers, natsServer)
+	servers.natsOptions = append(servers.natsOptions, opts)
+	return nil
+}
+
+// isClusterReady 確認 JetStream Cluster 已選出 Meta Leader 且所有節點都已加入
+func (servers *Servers) isClusterReady(clusterSize int) bool {
+	for _, natsServer := range servers.natsServers {
+		if natsServer != nil && natsServer.JetStreamIsLeader() {
+			return len(natsServer.JetStreamClusterPeers()) == clusterSize
+		}
+	}
+	return false
+}
+
+// getFreePort 取得目前沒有被使用的 Port
+func getFreePort() (int, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return 0, xerrors.Errorf("取得可用的 Port 失敗: %w", err)
+	}
+	defer listener.Close()
+
+	return listener.Addr().(*net.TCPAddr).Port, nil
+}
+
+func runNATSServer(opts *server.Options) (*server.Server, error) {
+	natsServer, err := server.NewServer(opts)
+	if err != nil {
+		return nil, xerrors.Errorf("建立 NATS Server 失敗: %w", err)
+	}
+
+	go natsServer.Start()
+	if !natsServer.ReadyForConnections(10 * time.Second) {
+		natsServer.Shutdown()
+		return nil, xerrors.New("等待 NATS Server 啟動逾時")
+	}
+
+	return natsServer, nil
+}
+
+func (servers *Servers) startSTANServer(conf *config.Config) error {
+	stanOpts := stand.GetDefaultOptions()
+	stanOpts.ID = conf.NATSStreaming.ClusterID
+	stanOpts.NATSServerURL = servers.ClientURL()
+
+	servers.security.applySTANOptions(stanOpts)
+
+	switch strings.ToLower(conf.Embedded.StoreType) {
+	case "", "memory":
+		stanOpts.StoreType = stores.TypeMemory
+	case "file":
+		stanOpts.StoreType = stores.TypeFile
+		stanOpts.FilestoreDir = fmt.Sprintf("%s/streaming", servers.storeDir)
+	default:
+		return xerrors.Errorf("不支援的 store_type: %s", conf.Embedded.StoreType)
+	}
+
+	// NATS Streaming 連到外部的 NATS Server 時，監控端點會由 NATS Streaming 自己開啟的 HTTP Server 提供
+	natsOpts := stand.DefaultNatsServerOptions
+	if servers.monitoring {
+		port, err := getFreePort()
+		if err != nil {
+			return xerrors.Errorf("取得監控端點的 Port 失敗: %w", err)
+		}
+		natsOpts.HTTPHost = "127.0.0.1"
+		natsOpts.HTTPPort = port
+		servers.streamingMonitorPort = port
+	}
+
+	servers.stanOptions = stanOpts
+	servers.stanNATSOptions = natsOpts
+	return servers.runSTANServer()
+}
+
+// runSTANServer 以記錄的設定啟動 NATS Streaming Server
+func (servers *Servers) runSTANServer() error {
+	stanOpts, natsOpts := *servers.stanOptions, servers.stanNATSOptions
+	stanServer, err := stand.RunServerWithOpts(&stanOpts, &natsOpts)
+	if err != nil {
+		return xerrors.Errorf("建立 NATS Streaming Server 失敗: %w", err)
+	}
+
+	servers.stanServer = stanServer
+	return nil
+}
@@ -0,0 +1,77 @@
+package embedded_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/marco79423/nats-jetstream-test/config"
+	"github.com/marco79423/nats-jetstream-test/embedded"
+	"github.com/marco79423/nats-jetstream-test/tester/utils"
+)
+
+func TestRestartStopsWaitingWhenContextCanceled(t *testing.T) {
+	testCases := []struct {
+		name             string
+		clusterSize      int
+		restartStreaming bool
+	}{
+		{name: "nats", clusterSize: 0},
+		{name: "nats and streaming", clusterSize: 0, restartStreaming: true},
+		{name: "cluster", clusterSize: 3},
+	}
+
+	for _, testCase := range testCases {
+		testCase := testCase
+		t.Run(testCase.name, func(t *testing.T) {
+			conf := &config.Config{
+				Embedded: config.EmbeddedConfig{
+					Enabled:     true,
+					ClusterSize: testCase.clusterSize,
+					StoreType:   "file",
+				},
+				NATSStreaming: config.NATSStreamingConfig{
+					ClusterID: "test-cluster",
+					ClientID:  "restart-test",
+				},
+			}
+
+			servers, err := embedded.Start(conf)
+			if err != nil {
+				t.Fatalf("啟動內嵌 Server 失敗: %+v", err)
+			}
+			defer servers.Shutdown()
+
+			ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+			defer cancel()
+
+			startTime := time.Now()
+			if testCase.restartStreaming {
+				err = servers.RestartNATSAndStreamingServer(ctx, 0, time.Minute)
+			} else {
+				err = servers.RestartNATSServer(ctx, 0, time.Minute)
+			}
+			if err != nil {
+				t.Fatalf("重啟 Server 失敗: %+v", err)
+			}
+			if elapsedTime := time.Since(startTime); elapsedTime > 10*time.Second {
+				t.Errorf("重啟花費 %v，ctx 取消後不應該繼續等待 downtime", elapsedTime)
+			}
+
+			// 中斷後 Server 仍需要重新啟動
+			natsConn, err := utils.ConnectNATS(conf, "restart-test")
+			if err != nil {
+				t.Fatalf("重啟後連線 NATS 失敗: %+v", err)
+			}
+			natsConn.Close()
+
+			if testCase.restartStreaming {
+				stanConn, err := utils.ConnectSTAN(context.Background(), conf, "restart-test")
+				if err != nil {
+					t.Fatalf("重啟後連線 STAN 失敗: %+v", err)
+				}
+				_ = stanConn.Close()
+			}
+		})
+	}
+}
@@ -0,0 +1,391 @@
+package embedded
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/nats-io/jwt/v2"
+	"github.com/nats-io/nats-server/v2/server"
+	stand "github.com/nats-io/nats-streaming-server/server"
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nkeys"
+	"golang.org/x/xerrors"
+
+	"github.com/marco79423/nats-jetstream-test/config"
+)
+
+// AuthUser 內嵌 Server 使用帳號密碼認證時的帳號 (密碼為隨機產生)
+const AuthUser = "nats-jetstream-test"
+
+// security 內嵌 Server 的 TLS 和認證設定 (憑證和認證資料都是在啟動時產生)
+type security struct {
+	dir string
+
+	tlsFiles     *serverTLSFiles // 沒有開啟 TLS 時為 nil
+	password     string
+	nkeyUser     string // NKey 的 Public Key
+	operator     *jwt.OperatorClaims
+	systemPubKey string
+	accountJWTs  map[string]string // Account 的 Public Key 對應的 JWT
+
+	// 給 Client 使用的設定
+	caFile       string
+	certFile     string
+	keyFile      string
+	credsFile    string
+	nkeySeedFile string
+}
+
+type serverTLSFiles struct {
+	serverCertFile string
+	serverKeyFile  string
+}
+
+// newSecurity 依設定產生 CA、憑證和認證資料 (檔案會放在 dir)
+func newSecurity(conf *config.Config, dir string) (*security, error) {
+	sec := &security{dir: dir}
+	if !conf.Embedded.TLS && conf.Embedded.Auth == "" {
+		return sec, nil
+	}
+
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, xerrors.Errorf("建立資料夾 %s 失敗: %w", dir, err)
+	}
+
+	if conf.Embedded.TLS {
+		if err := sec.generateTLS(); err != nil {
+			return nil, xerrors.Errorf("產生 TLS 憑證失敗: %w", err)
+		}
+	}
+
+	switch conf.Embedded.Auth {
+	case "":
+	case "user":
+		password, err := randomHex(16)
+		if err != nil {
+			return nil, xerrors.Errorf("產生密碼失敗: %w", err)
+		}
+		sec.password = password
+	case "nkey":
+		if err := sec.generateNKey(); err != nil {
+			return nil, xerrors.Errorf("產生 NKey 失敗: %w", err)
+		}
+	case "jwt":
+		if err := sec.generateJWT(); err != nil {
+			return nil, xerrors.Errorf("產生 JWT 失敗: %w", err)
+		}
+	default:
+		return nil, xerrors.Errorf("不支援的 auth: %s", conf.Embedded.Auth)
+	}
+
+	return sec, nil
+}
+
+// applyServerOptions 將 TLS 和認證設定加到 NATS Server 的設定
+func (sec *security) applyServerOptions(opts *server.Options) error {
+	if sec.tlsFiles != nil {
+		tlsConfig, err := server.GenTLSConfig(&server.TLSConfigOpts{
+			CertFile: sec.tlsFiles.serverCertFile,
+			KeyFile:  sec.tlsFiles.serverKeyFile,
+			CaFile:   sec.caFile,
+			Verify:   true,
+		})
+		if err != nil {
+			return xerrors.Errorf("建立 TLS 設定失敗: %w", err)
+		}
+		opts.TLS = true
+		opts.TLSVerify = true
+		opts.TLSConfig = tlsConfig
+		opts.TLSTimeout = 2
+	}
+
+	switch {
+	case sec.password != "":
+		opts.Users = []*server.User{{Username: AuthUser, Password: sec.password}}
+	case sec.nkeyUser != "":
+		opts.Nkeys = []*server.NkeyUser{{Nkey: sec.nkeyUser}}
+	case sec.operator != nil:
+		resolver := &server.MemAccResolver{}
+		for accountPubKey, accountJWT := range sec.accountJWTs {
+			if err := resolver.Store(accountPubKey, accountJWT); err != nil {
+				return xerrors.Errorf("儲存 Account 的 JWT 失敗: %w", err)
+			}
+		}
+		opts.TrustedOperators = []*jwt.OperatorClaims{sec.operator}
+		opts.AccountResolver = resolver
+		opts.SystemAccount = sec.systemPubKey
+	}
+
+	return nil
+}
+
+// applyClientConfig 將連線到內嵌 Server 需要的 TLS 和認證設定寫入設定檔 (NATS 和 NATS Streaming 都會使用)
+func (sec *security) applyClientConfig(conf *config.Config) {
+	tlsConfig := config.TLSConfig{
+		CAFile:   sec.caFile,
+		CertFile: sec.certFile,
+		KeyFile:  sec.keyFile,
+	}
+
+	conf.NATSJetStream.TLS = tlsConfig
+	conf.NATSJetStream.CredsFile = sec.credsFile
+	conf.NATSJetStream.NKeySeedFile = sec.nkeySeedFile
+	conf.NATSStreaming.TLS = tlsConfig
+	conf.NATSStreaming.CredsFile = sec.credsFile
+	conf.NATSStreaming.NKeySeedFile = sec.nkeySeedFile
+	if sec.password != "" {
+		conf.NATSJetStream.Username, conf.NATSJetStream.Password = AuthUser, sec.password
+		conf.NATSStreaming.Username, conf.NATSStreaming.Password = AuthUser, sec.password
+	}
+}
+
+// applySTANOptions 設定內嵌的 NATS Streaming Server 連到 NATS Server 時使用的 TLS 和認證
+//
+// 帳號密碼需要透過 Streaming 的設定指定 (NATSClientOpts 中的帳號密碼會被覆蓋)
+func (sec *security) applySTANOptions(stanOpts *stand.Options) {
+	if sec.password != "" {
+		stanOpts.Username = AuthUser
+		stanOpts.Password = sec.password
+	}
+	stanOpts.NATSCredentials = sec.credsFile
+	stanOpts.NKeySeedFile = sec.nkeySeedFile
+	if sec.tlsFiles != nil {
+		stanOpts.NATSClientOpts = append(stanOpts.NATSClientOpts, nats.RootCAs(sec.caFile), nats.ClientCert(sec.certFile, sec.keyFile))
+	}
+}
+
+// generateTLS 產生本機使用的 CA 以及由 CA 簽發的 Server 憑證 (127.0.0.1 和 localhost) 和 Client 憑證
+func (sec *security) generateTLS() error {
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return xerrors.Errorf("產生 CA 的私鑰失敗: %w", err)
+	}
+
+	caTemplate := &x509.Certificate{
+		Subject:               pkix.Name{CommonName: "nats-jetstream-test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(24 * time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	caCert, err := sec.writeCertificate("ca", caTemplate, nil, caKey, caKey)
+	if err != nil {
+		return xerrors.Errorf("產生 CA 失敗: %w", err)
+	}
+	sec.caFile = filepath.Join(sec.dir, "ca.pem")
+
+	serverKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return xerrors.Errorf("產生 Server 的私鑰失敗: %w", err)
+	}
+	if _, err := sec.writeCertificate("server", &x509.Certificate{
+		Subject:     pkix.Name{CommonName: "nats-jetstream-test server"},
+		NotBefore:   time.Now().Add(-time.Hour),
+		NotAfter:    time.Now().Add(24 * time.Hour),
+		KeyUsage:    x509.KeyUsageDigitalSignature,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		DNSNames:    []string{"localhost"},
+		IPAddresses: []net.IP{net.ParseIP("127.0.0.1")},
+	}, caCert, serverKey, caKey); err != nil {
+		return xerrors.Errorf("產生 Server 憑證失敗: %w", err)
+	}
+	sec.tlsFiles = &serverTLSFiles{
+		serverCertFile: filepath.Join(sec.dir, "server.pem"),
+		serverKeyFile:  filepath.Join(sec.dir, "server-key.pem"),
+	}
+
+	clientKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return xerrors.Errorf("產生 Client 的私鑰失敗: %w", err)
+	}
+	if _, err := sec.writeCertificate("client", &x509.Certificate{
+		Subject:     pkix.Name{CommonName: "nats-jetstream-test client"},
+		NotBefore:   time.Now().Add(-time.Hour),
+		NotAfter:    time.Now().Add(24 * time.Hour),
+		KeyUsage:    x509.KeyUsageDigitalSignature,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}, caCert, clientKey, caKey); err != nil {
+		return xerrors.Errorf("產生 Client 憑證失敗: %w", err)
+	}
+	sec.certFile = filepath.Join(sec.dir, "client.pem")
+	sec.keyFile = filepath.Join(sec.dir, "client-key.pem")
+
+	return nil
+}
+
+// writeCertificate 以 signerKey 簽發憑證並寫入 <name>.pem 和 <name>-key.pem (parent 為 nil 代表自簽)
+func (sec *security) writeCertificate(name string, template, parent *x509.Certificate, key, signerKey *ecdsa.PrivateKey) (*x509.Certificate, error) {
+	serialNumber, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, xerrors.Errorf("產生序號失敗: %w", err)
+	}
+	template.SerialNumber = serialNumber
+	if parent == nil {
+		parent = template
+	}
+
+	certDER, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, signerKey)
+	if err != nil {
+		return nil, xerrors.Errorf("簽發憑證失敗: %w", err)
+	}
+	if err := writePEM(filepath.Join(sec.dir, name+".pem"), "CERTIFICATE", certDER); err != nil {
+		return nil, xerrors.Errorf("寫入憑證失敗: %w", err)
+	}
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, xerrors.Errorf("轉換私鑰失敗: %w", err)
+	}
+	if err := writePEM(filepath.Join(sec.dir, name+"-key.pem"), "EC PRIVATE KEY", keyDER); err != nil {
+		return nil, xerrors.Errorf("寫入私鑰失敗: %w", err)
+	}
+
+	return x509.ParseCertificate(certDER)
+}
+
+// generateNKey 產生 NKey 使用者並將 Seed 寫入檔案
+func (sec *security) generateNKey() error {
+	userKey, err := nkeys.CreateUser()
+	if err != nil {
+		return xerrors.Errorf("產生 NKey 失敗: %w", err)
+	}
+
+	userPubKey, err := userKey.PublicKey()
+	if err != nil {
+		return xerrors.Errorf("取得 NKey 的 Public Key 失敗: %w", err)
+	}
+
+	seed, err := userKey.Seed()
+	if err != nil {
+		return xerrors.Errorf("取得 NKey 的 Seed 失敗: %w", err)
+	}
+
+	sec.nkeyUser = userPubKey
+	sec.nkeySeedFile = filepath.Join(sec.dir, "user.nk")
+	if err := ioutil.WriteFile(sec.nkeySeedFile, seed, 0600); err != nil {
+		return xerrors.Errorf("寫入 NKey Seed 檔案失敗: %w", err)
+	}
+	return nil
+}
+
+// generateJWT 產生 Operator、System Account、可使用 JetStream 的 Account 和使用者，並將使用者的 JWT 和 Seed 寫入 .creds 檔案
+func (sec *security) generateJWT() error {
+	operatorKey, err := nkeys.CreateOperator()
+	if err != nil {
+		return xerrors.Errorf("產生 Operator 的 NKey 失敗: %w", err)
+	}
+	operatorPubKey, err := operatorKey.PublicKey()
+	if err != nil {
+		return xerrors.Errorf("取得 Operator 的 Public Key 失敗: %w", err)
+	}
+
+	sec.accountJWTs = map[string]string{}
+	systemPubKey, _, err := sec.generateAccount(operatorKey, "SYS", false)
+	if err != nil {
+		return xerrors.Errorf("產生 System Account 失敗: %w", err)
+	}
+	sec.systemPubKey = systemPubKey
+
+	accountPubKey, accountKey, err := sec.generateAccount(operatorKey, "TEST", true)
+	if err != nil {
+		return xerrors.Errorf("產生 Account 失敗: %w", err)
+	}
+
+	operatorClaims := jwt.NewOperatorClaims(operatorPubKey)
+	operatorClaims.Name = "nats-jetstream-test"
+	operatorClaims.SystemAccount = systemPubKey
+	operatorJWT, err := operatorClaims.Encode(operatorKey)
+	if err != nil {
+		return xerrors.Errorf("產生 Operator 的 JWT 失敗: %w", err)
+	}
+	sec.operator, err = jwt.DecodeOperatorClaims(operatorJWT)
+	if err != nil {
+		return xerrors.Errorf("解析 Operator 的 JWT 失敗: %w", err)
+	}
+
+	userKey, err := nkeys.CreateUser()
+	if err != nil {
+		return xerrors.Errorf("產生使用者的 NKey 失敗: %w", err)
+	}
+	userPubKey, err := userKey.PublicKey()
+	if err != nil {
+		return xerrors.Errorf("取得使用者的 Public Key 失敗: %w", err)
+	}
+	userClaims := jwt.NewUserClaims(userPubKey)
+	userClaims.Name = AuthUser
+	userClaims.IssuerAccount = accountPubKey
+	userJWT, err := userClaims.Encode(accountKey)
+	if err != nil {
+		return xerrors.Errorf("產生使用者的 JWT 失敗: %w", err)
+	}
+
+	userSeed, err := userKey.Seed()
+	if err != nil {
+		return xerrors.Errorf("取得使用者的 Seed 失敗: %w", err)
+	}
+	creds, err := jwt.FormatUserConfig(userJWT, userSeed)
+	if err != nil {
+		return xerrors.Errorf("產生 .creds 檔案失敗: %w", err)
+	}
+
+	sec.credsFile = filepath.Join(sec.dir, "user.creds")
+	if err := ioutil.WriteFile(sec.credsFile, creds, 0600); err != nil {
+		return xerrors.Errorf("寫入 .creds 檔案失敗: %w", err)
+	}
+	return nil
+}
+
+// generateAccount 產生由 Operator 簽發的 Account (jetStream 代表是否開啟 JetStream 且不限制用量)
+func (sec *security) generateAccount(operatorKey nkeys.KeyPair, name string, jetStream bool) (string, nkeys.KeyPair, error) {
+	accountKey, err := nkeys.CreateAccount()
+	if err != nil {
+		return "", nil, xerrors.Errorf("產生 Account 的 NKey 失敗: %w", err)
+	}
+	accountPubKey, err := accountKey.PublicKey()
+	if err != nil {
+		return "", nil, xerrors.Errorf("取得 Account 的 Public Key 失敗: %w", err)
+	}
+
+	accountClaims := jwt.NewAccountClaims(accountPubKey)
+	accountClaims.Name = name
+	if jetStream {
+		accountClaims.Limits.JetStreamLimits = jwt.JetStreamLimits{
+			MemoryStorage: jwt.NoLimit,
+			DiskStorage:   jwt.NoLimit,
+			Streams:       jwt.NoLimit,
+			Consumer:      jwt.NoLimit,
+		}
+	}
+
+	accountJWT, err := accountClaims.Encode(operatorKey)
+	if err != nil {
+		return "", nil, xerrors.Errorf("產生 Account 的 JWT 失敗: %w", err)
+	}
+	sec.accountJWTs[accountPubKey] = accountJWT
+	return accountPubKey, accountKey, nil
+}
+
+func writePEM(path, blockType string, der []byte) error {
+	data := pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der})
+	return ioutil.WriteFile(path, data, 0600)
+}
+
+func randomHex(size int) (string, error) {
+	data := make([]byte, size)
+	if _, err := rand.Read(data); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(data), nil
+}
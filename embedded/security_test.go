@@ -0,0 +1,100 @@
+package embedded_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go"
+
+	"github.com/marco79423/nats-jetstream-test/config"
+	"github.com/marco79423/nats-jetstream-test/embedded"
+	"github.com/marco79423/nats-jetstream-test/tester/utils"
+)
+
+func TestStartWithTLSAndAuth(t *testing.T) {
+	for _, auth := range []string{"user", "nkey", "jwt"} {
+		auth := auth
+		t.Run(auth, func(t *testing.T) {
+			conf := &config.Config{
+				Embedded: config.EmbeddedConfig{
+					Enabled: true,
+					TLS:     true,
+					Auth:    auth,
+				},
+				NATSStreaming: config.NATSStreamingConfig{
+					ClusterID: "test-cluster",
+					ClientID:  "security-test",
+				},
+			}
+
+			servers, err := embedded.Start(conf)
+			if err != nil {
+				t.Fatalf("啟動內嵌 Server 失敗: %+v", err)
+			}
+			defer servers.Shutdown()
+
+			natsConn, err := utils.ConnectNATS(conf, "security-test")
+			if err != nil {
+				t.Fatalf("使用產生的認證資料連線 NATS 失敗: %+v", err)
+			}
+			defer natsConn.Close()
+
+			if !natsConn.TLSRequired() {
+				t.Errorf("NATS 連線沒有使用 TLS")
+			}
+
+			js, err := natsConn.JetStream()
+			if err != nil {
+				t.Fatalf("取得 JetStream Context 失敗: %+v", err)
+			}
+			if _, err := js.AccountInfo(); err != nil {
+				t.Fatalf("取得 JetStream 帳號資訊失敗: %+v", err)
+			}
+
+			stanConn, err := utils.ConnectSTAN(context.Background(), conf, "security-test")
+			if err != nil {
+				t.Fatalf("使用產生的認證資料連線 STAN 失敗: %+v", err)
+			}
+			defer stanConn.Close()
+
+			if err := stanConn.Publish("security-test", []byte("hello")); err != nil {
+				t.Fatalf("STAN 發布訊息失敗: %+v", err)
+			}
+
+			// 只有 TLS 憑證而沒有認證資料時應該被拒絕
+			anonymous := *conf
+			anonymous.NATSJetStream.Username, anonymous.NATSJetStream.Password = "", ""
+			anonymous.NATSJetStream.CredsFile, anonymous.NATSJetStream.NKeySeedFile = "", ""
+			if anonymousConn, err := utils.ConnectNATS(&anonymous, "security-test-anonymous"); err == nil {
+				anonymousConn.Close()
+				t.Errorf("沒有認證資料的連線應該被拒絕")
+			}
+		})
+	}
+}
+
+func TestStartWithTLSRejectsClientWithoutCertificate(t *testing.T) {
+	conf := &config.Config{
+		Embedded: config.EmbeddedConfig{
+			Enabled: true,
+			TLS:     true,
+		},
+		NATSStreaming: config.NATSStreamingConfig{
+			ClusterID: "test-cluster",
+		},
+	}
+
+	servers, err := embedded.Start(conf)
+	if err != nil {
+		t.Fatalf("啟動內嵌 Server 失敗: %+v", err)
+	}
+	defer servers.Shutdown()
+
+	noClientCert := *conf
+	noClientCert.NATSJetStream.TLS.CertFile, noClientCert.NATSJetStream.TLS.KeyFile = "", ""
+	if natsConn, err := utils.ConnectNATS(&noClientCert, "security-test", nats.Timeout(2*time.Second)); err == nil {
+		natsConn.Close()
+		t.Errorf("沒有 Client 憑證的連線應該被拒絕")
+	}
+}
@@ -3,9 +3,11 @@ module github.com/marco79423/nats-jetstream-test
 go 1.14
 
 require (
-	github.com/nats-io/nats-server/v2 v2.5.0 // indirect
-	github.com/nats-io/nats-streaming-server v0.22.1 // indirect
+	github.com/nats-io/jwt/v2 v2.0.3
+	github.com/nats-io/nats-server/v2 v2.5.0
+	github.com/nats-io/nats-streaming-server v0.22.1
 	github.com/nats-io/nats.go v1.12.3
+	github.com/nats-io/nkeys v0.3.0
 	github.com/nats-io/stan.go v0.10.0
 	github.com/spf13/viper v1.8.1
 	golang.org/x/xerrors v0.0.0-20200804184101-5ec99f83aff1
@@ -2,13 +2,13 @@ package main
 
 import (
 	"log"
+	"os"
 
-	"github.com/marco79423/nats-jetstream-test/tester"
+	"github.com/marco79423/nats-jetstream-test/cmd"
 )
 
-
 func main() {
-	if err := tester.RunTesters(); err != nil {
+	if err := cmd.Execute(os.Args[1:]); err != nil {
 		log.Fatalf("%+v", err)
 	}
 }
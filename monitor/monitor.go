@@ -0,0 +1,167 @@
+package monitor
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/nats-io/nats-server/v2/server"
+	stand "github.com/nats-io/nats-streaming-server/server"
+	"golang.org/x/xerrors"
+
+	"github.com/marco79423/nats-jetstream-test/config"
+	"github.com/marco79423/nats-jetstream-test/report"
+)
+
+// defaultTimeout 沒有設定 monitoring.timeout 時每個端點的請求時間限制
+const defaultTimeout = 5 * time.Second
+
+// Monitor 擷取 NATS Server 和 NATS Streaming Server 監控端點的數據
+type Monitor struct {
+	natsURLs     []string
+	streamingURL string
+	client       *http.Client
+}
+
+// New 依照設定建立 Monitor (沒有啟用 monitoring 時回傳 nil，內嵌的 Server 需要先啟動才會有監控位置)
+func New(conf *config.Config) *Monitor {
+	if !conf.Monitoring.Enabled {
+		return nil
+	}
+
+	timeout := conf.Monitoring.Timeout
+	if timeout == 0 {
+		timeout = defaultTimeout
+	}
+
+	return &Monitor{
+		natsURLs:     conf.Monitoring.NATSURLs,
+		streamingURL: conf.Monitoring.StreamingURL,
+		client: &http.Client{
+			Timeout: timeout,
+		},
+	}
+}
+
+// Snapshot 擷取所有監控端點目前的數據 (無法取得的端點會記錄在 Errors 中，不會回傳錯誤)
+func (monitor *Monitor) Snapshot() *report.ServerSnapshot {
+	snapshot := &report.ServerSnapshot{
+		Time: time.Now(),
+	}
+
+	for _, natsURL := range monitor.natsURLs {
+		stats, err := monitor.natsServerStats(natsURL)
+		if err != nil {
+			snapshot.Errors = append(snapshot.Errors, fmt.Sprintf("%s: %v", natsURL, err))
+			continue
+		}
+		snapshot.NATS = append(snapshot.NATS, stats)
+	}
+
+	if monitor.streamingURL != "" {
+		stats, err := monitor.streamingServerStats(monitor.streamingURL)
+		if err != nil {
+			snapshot.Errors = append(snapshot.Errors, fmt.Sprintf("%s: %v", monitor.streamingURL, err))
+		} else {
+			snapshot.Streaming = stats
+		}
+	}
+
+	return snapshot
+}
+
+// natsServerStats 取得 NATS Server 的 /varz, /jsz 和 /connz
+func (monitor *Monitor) natsServerStats(natsURL string) (*report.NATSServerStats, error) {
+	var varz server.Varz
+	if err := monitor.getJSON(natsURL, "/varz", &varz); err != nil {
+		return nil, err
+	}
+
+	var connz server.Connz
+	if err := monitor.getJSON(natsURL, "/connz", &connz); err != nil {
+		return nil, err
+	}
+	var pendingBytes int64
+	for _, conn := range connz.Conns {
+		pendingBytes += int64(conn.Pending)
+	}
+
+	stats := &report.NATSServerStats{
+		URL:           natsURL,
+		ServerID:      varz.ID,
+		CPU:           varz.CPU,
+		Memory:        varz.Mem,
+		Connections:   varz.Connections,
+		InMsgs:        varz.InMsgs,
+		OutMsgs:       varz.OutMsgs,
+		InBytes:       varz.InBytes,
+		OutBytes:      varz.OutBytes,
+		SlowConsumers: varz.SlowConsumers,
+		PendingBytes:  pendingBytes,
+	}
+
+	// 沒有開啟 JetStream 的 Server 不需要 /jsz
+	if varz.JetStream.Config == nil {
+		return stats, nil
+	}
+
+	var jsz server.JSInfo
+	if err := monitor.getJSON(natsURL, "/jsz", &jsz); err != nil {
+		return nil, err
+	}
+	if !jsz.Disabled {
+		stats.JetStream = &report.JetStreamUsage{
+			Memory:    jsz.Memory,
+			Storage:   jsz.Store,
+			Streams:   jsz.Streams,
+			Consumers: jsz.Consumers,
+			Messages:  jsz.Messages,
+			Bytes:     jsz.Bytes,
+			APITotal:  jsz.API.Total,
+			APIErrors: jsz.API.Errors,
+		}
+	}
+	return stats, nil
+}
+
+// streamingServerStats 取得 NATS Streaming Server 的 /streaming/serverz
+func (monitor *Monitor) streamingServerStats(streamingURL string) (*report.StreamingServerStats, error) {
+	var serverz stand.Serverz
+	if err := monitor.getJSON(streamingURL, stand.ServerPath, &serverz); err != nil {
+		return nil, err
+	}
+
+	return &report.StreamingServerStats{
+		URL:           streamingURL,
+		Clients:       serverz.Clients,
+		Subscriptions: serverz.Subscriptions,
+		Channels:      serverz.Channels,
+		TotalMsgs:     serverz.TotalMsgs,
+		TotalBytes:    serverz.TotalBytes,
+		InMsgs:        serverz.InMsgs,
+		InBytes:       serverz.InBytes,
+		OutMsgs:       serverz.OutMsgs,
+		OutBytes:      serverz.OutBytes,
+	}, nil
+}
+
+// getJSON 取得監控端點的 JSON 並解析到 v
+func (monitor *Monitor) getJSON(baseURL, path string, v interface{}) error {
+	endpoint := strings.TrimSuffix(baseURL, "/") + path
+	resp, err := monitor.client.Get(endpoint)
+	if err != nil {
+		return xerrors.Errorf("取得 %s 失敗: %w", endpoint, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return xerrors.Errorf("取得 %s 失敗: %s", endpoint, resp.Status)
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return xerrors.Errorf("解析 %s 失敗: %w", endpoint, err)
+	}
+	return nil
+}
@@ -0,0 +1,114 @@
+package monitor_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/marco79423/nats-jetstream-test/config"
+	"github.com/marco79423/nats-jetstream-test/embedded"
+	"github.com/marco79423/nats-jetstream-test/monitor"
+	"github.com/marco79423/nats-jetstream-test/report"
+	"github.com/marco79423/nats-jetstream-test/tester/utils"
+)
+
+func TestSnapshotWithEmbeddedServers(t *testing.T) {
+	testCases := []struct {
+		name        string
+		clusterSize int
+		wantNodes   int
+	}{
+		{name: "single", clusterSize: 0, wantNodes: 1},
+		{name: "cluster", clusterSize: 3, wantNodes: 3},
+	}
+
+	for _, testCase := range testCases {
+		testCase := testCase
+		t.Run(testCase.name, func(t *testing.T) {
+			conf := &config.Config{
+				Embedded: config.EmbeddedConfig{
+					Enabled:     true,
+					ClusterSize: testCase.clusterSize,
+				},
+				NATSStreaming: config.NATSStreamingConfig{
+					ClusterID: "test-cluster",
+					ClientID:  "monitor-test",
+				},
+				Monitoring: config.MonitoringConfig{
+					Enabled: true,
+				},
+			}
+
+			servers, err := embedded.Start(conf)
+			if err != nil {
+				t.Fatalf("啟動內嵌 Server 失敗: %+v", err)
+			}
+			defer servers.Shutdown()
+
+			serverMonitor := monitor.New(conf)
+			if serverMonitor == nil {
+				t.Fatalf("啟用 monitoring 時應該建立 Monitor")
+			}
+
+			before := serverMonitor.Snapshot()
+			if len(before.Errors) > 0 {
+				t.Fatalf("擷取監控數據失敗: %v", before.Errors)
+			}
+			if len(before.NATS) != testCase.wantNodes {
+				t.Fatalf("NATS 節點數量為 %d，預期為 %d", len(before.NATS), testCase.wantNodes)
+			}
+			for _, stats := range before.NATS {
+				if stats.ServerID == "" {
+					t.Errorf("%s 沒有 ServerID", stats.URL)
+				}
+				if stats.JetStream == nil {
+					t.Errorf("%s 沒有 JetStream 的數據", stats.URL)
+				}
+			}
+			if before.Streaming == nil {
+				t.Fatalf("沒有 NATS Streaming 的數據")
+			}
+
+			natsConn, err := utils.ConnectNATS(conf, "monitor-test")
+			if err != nil {
+				t.Fatalf("連線 NATS 失敗: %+v", err)
+			}
+			defer natsConn.Close()
+			if err := utils.PublishNATSMessagesWithSize(context.Background(), natsConn, "monitor.test", 10, 16); err != nil {
+				t.Fatalf("發布訊息失敗: %+v", err)
+			}
+			if err := natsConn.Flush(); err != nil {
+				t.Fatalf("Flush 失敗: %+v", err)
+			}
+
+			stanConn, err := utils.ConnectSTAN(context.Background(), conf, "monitor-test")
+			if err != nil {
+				t.Fatalf("連線 STAN 失敗: %+v", err)
+			}
+			defer stanConn.Close()
+			if err := stanConn.Publish("monitor-test", []byte("hello")); err != nil {
+				t.Fatalf("STAN 發布訊息失敗: %+v", err)
+			}
+
+			after := serverMonitor.Snapshot()
+			if len(after.Errors) > 0 {
+				t.Fatalf("擷取監控數據失敗: %v", after.Errors)
+			}
+
+			// Cluster 模式下 Client 可能連到任一個節點，所以比較所有節點的合計
+			if beforeInMsgs, afterInMsgs := totalInMsgs(before), totalInMsgs(after); afterInMsgs < beforeInMsgs+10 {
+				t.Errorf("流入訊息數 %d → %d，預期至少增加 10 筆", beforeInMsgs, afterInMsgs)
+			}
+			if after.Streaming.TotalMsgs != before.Streaming.TotalMsgs+1 {
+				t.Errorf("NATS Streaming 保存訊息數 %d → %d，預期增加 1 筆", before.Streaming.TotalMsgs, after.Streaming.TotalMsgs)
+			}
+		})
+	}
+}
+
+func totalInMsgs(snapshot *report.ServerSnapshot) int64 {
+	var inMsgs int64
+	for _, stats := range snapshot.NATS {
+		inMsgs += stats.InMsgs
+	}
+	return inMsgs
+}
@@ -0,0 +1,218 @@
+package profiling
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"runtime/pprof"
+	"time"
+
+	"golang.org/x/xerrors"
+
+	"github.com/marco79423/nats-jetstream-test/config"
+	"github.com/marco79423/nats-jetstream-test/report"
+)
+
+// Profiler 記錄每個 Tester 執行期間 (以 Tester 為單位，包含所有情境、暖身和重複測量) Client 的 Go Runtime 統計，並依設定輸出 pprof 的 CPU 和 Heap Profile
+type Profiler struct {
+	dir         string
+	cpuProfile  bool
+	heapProfile bool
+}
+
+// New 依照設定建立 Profiler (沒有啟用 profiling 時回傳 nil)
+//
+// 沒有設定 profiling.dir 時 Profile 會輸出到 JSON 報告 (或 CSV 報告) 所在的資料夾，都沒有設定時輸出到目前的資料夾
+func New(conf *config.Config) *Profiler {
+	if !conf.Profiling.Enabled {
+		return nil
+	}
+
+	dir := conf.Profiling.Dir
+	if dir == "" {
+		switch {
+		case conf.Report.JSONPath != "":
+			dir = filepath.Dir(conf.Report.JSONPath)
+		case conf.Report.CSVPath != "":
+			dir = filepath.Dir(conf.Report.CSVPath)
+		default:
+			dir = "."
+		}
+	}
+
+	return &Profiler{
+		dir:         dir,
+		cpuProfile:  conf.Profiling.CPUProfile,
+		heapProfile: conf.Profiling.HeapProfile,
+	}
+}
+
+// Session 單一 Tester 的記錄
+type Session struct {
+	profiler *Profiler
+	key      string
+
+	startedAt        time.Time
+	memStats         runtime.MemStats // 開始時的記憶體統計
+	goroutines       int
+	cpuProfileFile   *os.File
+	cpuProfilePath   string
+	heapBaseFilePath string
+	errors           []string
+}
+
+// Start 開始記錄 (key 為 Tester 的 Key，用來命名 Profile)，無法輸出 Profile 時只記錄錯誤不會中斷測試
+func (profiler *Profiler) Start(key string) *Session {
+	session := &Session{
+		profiler:  profiler,
+		key:       key,
+		startedAt: time.Now(),
+	}
+
+	if profiler.cpuProfile || profiler.heapProfile {
+		if err := os.MkdirAll(profiler.dir, 0755); err != nil {
+			session.addError(xerrors.Errorf("建立 Profile 的資料夾 %s 失敗: %w", profiler.dir, err))
+		}
+	}
+
+	// Heap Profile 的 alloc_* 是程式啟動後的累計值，開始時先輸出一份作為比較的基準 (go tool pprof -base)
+	if profiler.heapProfile {
+		path := profiler.profilePath(key, "heap_base")
+		if err := writeHeapProfile(path); err != nil {
+			session.addError(err)
+		} else {
+			session.heapBaseFilePath = path
+		}
+	}
+
+	if profiler.cpuProfile {
+		path := profiler.profilePath(key, "cpu")
+		if file, err := startCPUProfile(path); err != nil {
+			session.addError(err)
+		} else {
+			session.cpuProfileFile = file
+			session.cpuProfilePath = path
+		}
+	}
+
+	// 最後才讀取統計，避免把輸出 Profile 的配置算進去
+	session.goroutines = runtime.NumGoroutine()
+	runtime.ReadMemStats(&session.memStats)
+	return session
+}
+
+// Stop 結束記錄，回傳執行期間的 Runtime 統計和 Profile 的位置
+func (session *Session) Stop() *report.ClientMetrics {
+	var memStats runtime.MemStats
+	runtime.ReadMemStats(&memStats)
+	goroutines := runtime.NumGoroutine()
+	elapsedTime := time.Since(session.startedAt)
+
+	pauseTotal, pauseMax := gcPauses(&session.memStats, &memStats)
+	metrics := &report.ClientMetrics{
+		ElapsedTime:      elapsedTime,
+		TotalAlloc:       memStats.TotalAlloc - session.memStats.TotalAlloc,
+		Mallocs:          memStats.Mallocs - session.memStats.Mallocs,
+		HeapAllocBefore:  session.memStats.HeapAlloc,
+		HeapAllocAfter:   memStats.HeapAlloc,
+		NumGC:            memStats.NumGC - session.memStats.NumGC,
+		GCPauseTotal:     pauseTotal,
+		GCPauseMax:       pauseMax,
+		GCCPUFraction:    memStats.GCCPUFraction,
+		GoroutinesBefore: session.goroutines,
+		GoroutinesAfter:  goroutines,
+	}
+
+	if session.cpuProfileFile != nil {
+		pprof.StopCPUProfile()
+		if err := session.cpuProfileFile.Close(); err != nil {
+			session.addError(xerrors.Errorf("關閉 CPU Profile %s 失敗: %w", session.cpuProfilePath, err))
+		} else {
+			metrics.CPUProfile = session.cpuProfilePath
+		}
+	}
+
+	if session.profiler.heapProfile {
+		path := session.profiler.profilePath(session.key, "heap")
+		if err := writeHeapProfile(path); err != nil {
+			session.addError(err)
+		} else {
+			metrics.HeapProfile = path
+			metrics.HeapBaseProfile = session.heapBaseFilePath
+		}
+	}
+
+	metrics.Errors = session.errors
+	return metrics
+}
+
+func (session *Session) addError(err error) {
+	session.errors = append(session.errors, err.Error())
+}
+
+// profilePath Profile 的位置 (<dir>/<key>.<kind>.pprof，key 會先轉為安全的檔名，避免寫到 dir 以外的位置)
+func (profiler *Profiler) profilePath(key, kind string) string {
+	return filepath.Join(profiler.dir, fmt.Sprintf("%s.%s.pprof", sanitizeFileName(key), kind))
+}
+
+// unsafeFileNameChars 檔名中英數字、_ 和 - 以外的字元
+var unsafeFileNameChars = regexp.MustCompile(`[^A-Za-z0-9_-]`)
+
+// sanitizeFileName 只保留最後一段路徑，並將英數字、_ 和 - 以外的字元 (包含 .) 換成 _
+func sanitizeFileName(name string) string {
+	name = unsafeFileNameChars.ReplaceAllString(filepath.Base(filepath.Clean("/"+name)), "_")
+	if name == "" || name == "_" {
+		return "tester"
+	}
+	return name
+}
+
+// startCPUProfile 開始輸出 CPU Profile (同時只能有一個 CPU Profile)
+func startCPUProfile(path string) (*os.File, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, xerrors.Errorf("建立 CPU Profile %s 失敗: %w", path, err)
+	}
+
+	if err := pprof.StartCPUProfile(file); err != nil {
+		_ = file.Close()
+		return nil, xerrors.Errorf("開始 CPU Profile 失敗: %w", err)
+	}
+	return file, nil
+}
+
+// writeHeapProfile 輸出 Heap Profile (先執行 GC 讓 inuse_* 反映目前存活的物件)
+func writeHeapProfile(path string) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return xerrors.Errorf("建立 Heap Profile %s 失敗: %w", path, err)
+	}
+	defer file.Close()
+
+	runtime.GC()
+	if err := pprof.WriteHeapProfile(file); err != nil {
+		return xerrors.Errorf("輸出 Heap Profile %s 失敗: %w", path, err)
+	}
+	return nil
+}
+
+// gcPauses 計算兩次統計之間 GC 暫停的總時間和最長時間
+//
+// PauseTotalNs 是累計值，最長時間則只能從最近 256 次的紀錄中取得 (GC 次數超過時只計算最近的 256 次)
+func gcPauses(before, after *runtime.MemStats) (total, max time.Duration) {
+	total = time.Duration(after.PauseTotalNs - before.PauseTotalNs)
+
+	count := after.NumGC - before.NumGC
+	if count > uint32(len(after.PauseNs)) {
+		count = uint32(len(after.PauseNs))
+	}
+	for i := uint32(0); i < count; i++ {
+		pause := time.Duration(after.PauseNs[(after.NumGC-i+255)%256])
+		if pause > max {
+			max = pause
+		}
+	}
+	return total, max
+}
@@ -0,0 +1,29 @@
+package profiling
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestProfilePathStaysInDir(t *testing.T) {
+	profiler := &Profiler{dir: "profiles"}
+	testCases := []struct {
+		key  string
+		want string
+	}{
+		{key: "jetstream_publish_tester", want: "jetstream_publish_tester.cpu.pprof"},
+		{key: "my-scenario", want: "my-scenario.cpu.pprof"},
+		{key: "../../etc/passwd", want: "passwd.cpu.pprof"},
+		{key: "a/b", want: "b.cpu.pprof"},
+		{key: "..", want: "tester.cpu.pprof"},
+		{key: "", want: "tester.cpu.pprof"},
+		{key: "name with spaces.v2", want: "name_with_spaces_v2.cpu.pprof"},
+	}
+
+	for _, testCase := range testCases {
+		got := profiler.profilePath(testCase.key, "cpu")
+		if want := filepath.Join("profiles", testCase.want); got != want {
+			t.Errorf("profilePath(%q) = %q，預期為 %q", testCase.key, got, want)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package report
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// ClientMetrics Tester 執行期間 Client 的 Go Runtime 統計 (包含暖身和重複測量)
+//
+// 使用內嵌的 Server 時 Server 和測試程式在同一個 Process，統計會包含 Server 的用量
+type ClientMetrics struct {
+	ElapsedTime      time.Duration `json:"elapsed_time"`
+	TotalAlloc       uint64        `json:"total_alloc"`       // 執行期間配置的 bytes
+	Mallocs          uint64        `json:"mallocs"`           // 執行期間配置的次數
+	HeapAllocBefore  uint64        `json:"heap_alloc_before"` // 開始時 Heap 上的物件 bytes
+	HeapAllocAfter   uint64        `json:"heap_alloc_after"`
+	NumGC            uint32        `json:"num_gc"`
+	GCPauseTotal     time.Duration `json:"gc_pause_total"`
+	GCPauseMax       time.Duration `json:"gc_pause_max"`
+	GCCPUFraction    float64       `json:"gc_cpu_fraction"` // 程式啟動後 GC 使用的 CPU 比例
+	GoroutinesBefore int           `json:"goroutines_before"`
+	GoroutinesAfter  int           `json:"goroutines_after"`
+	CPUProfile       string        `json:"cpu_profile,omitempty"`       // CPU Profile 的位置
+	HeapProfile      string        `json:"heap_profile,omitempty"`      // 結束時的 Heap Profile 的位置
+	HeapBaseProfile  string        `json:"heap_base_profile,omitempty"` // 開始時的 Heap Profile 的位置 (go tool pprof -base 使用)
+	Errors           []string      `json:"errors,omitempty"`            // 無法輸出的 Profile (不會讓 Tester 失敗)
+}
+
+// WriteClientMetrics 以文字的方式輸出 Client 在 Tester 執行期間的 Runtime 統計和 Profile 的位置
+func WriteClientMetrics(w io.Writer, metrics *ClientMetrics) error {
+	if metrics == nil {
+		return nil
+	}
+
+	var allocPerSec float64
+	if seconds := metrics.ElapsedTime.Seconds(); seconds > 0 {
+		allocPerSec = float64(metrics.TotalAlloc) / seconds
+	}
+
+	lines := []string{
+		fmt.Sprintf("[Client] 配置： %s (%d 次, 每秒 %s), Heap： %s → %s, GC： %d 次 (暫停總計 %v, 最長 %v), Goroutine： %d → %d",
+			formatBytes(int64(metrics.TotalAlloc)),
+			metrics.Mallocs,
+			formatBytes(int64(allocPerSec)),
+			formatBytes(int64(metrics.HeapAllocBefore)), formatBytes(int64(metrics.HeapAllocAfter)),
+			metrics.NumGC,
+			metrics.GCPauseTotal,
+			metrics.GCPauseMax,
+			metrics.GoroutinesBefore, metrics.GoroutinesAfter,
+		),
+	}
+
+	if metrics.CPUProfile != "" {
+		lines = append(lines, fmt.Sprintf("    CPU Profile： %s", metrics.CPUProfile))
+	}
+	if metrics.HeapProfile != "" {
+		if metrics.HeapBaseProfile != "" {
+			lines = append(lines, fmt.Sprintf("    Heap Profile： %s (基準： %s)", metrics.HeapProfile, metrics.HeapBaseProfile))
+		} else {
+			lines = append(lines, fmt.Sprintf("    Heap Profile： %s", metrics.HeapProfile))
+		}
+	}
+	for _, profileErr := range metrics.Errors {
+		lines = append(lines, fmt.Sprintf("[Client] 無法輸出 Profile: %s", profileErr))
+	}
+
+	_, err := fmt.Fprintln(w, strings.Join(lines, "\n"))
+	return err
+}
@@ -0,0 +1,248 @@
+package report
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// Comparison 兩份報告的比較結果
+type Comparison struct {
+	Threshold    float64          // 判斷為退步的變化幅度 (百分比)
+	Scenarios    []*ScenarioDelta // 兩份報告都有的情境
+	OnlyBaseline []*Result        // 只在基準報告中的情境 (目前報告缺少或 Tester 失敗，視為退步)
+	OnlyCurrent  []*Result        // 只在目前報告中的情境
+	Failed       []*Result        // 目前報告中正確性檢查失敗的情境 (視為退步)
+}
+
+// ScenarioDelta 單一情境在兩份報告間的差異
+type ScenarioDelta struct {
+	Label   string // 情境的名稱 (Tester Key、Operation、訊息大小和參數)
+	Metrics []*MetricDelta
+}
+
+// MetricDelta 單一指標在兩份報告間的差異
+type MetricDelta struct {
+	Name           string
+	Baseline       float64
+	Current        float64
+	DeltaPercent   float64 // (目前 - 基準) / 基準 * 100，基準為 0 時無法計算
+	HigherIsBetter bool    // 吞吐量越高越好，延遲越低越好
+	Regressed      bool    // 是否退步超過門檻
+}
+
+// HasRegression 是否有任何指標退步超過門檻
+func (comparison *Comparison) HasRegression() bool {
+	return len(comparison.Regressions()) > 0
+}
+
+// Regressions 取得所有退步超過門檻的指標 (以 "情境: 指標" 表示)，目前報告缺少或失敗的情境也視為退步
+func (comparison *Comparison) Regressions() []string {
+	var regressions []string
+	for _, scenario := range comparison.Scenarios {
+		for _, metric := range scenario.Metrics {
+			if metric.Regressed {
+				regressions = append(regressions, fmt.Sprintf("%s: %s", scenario.Label, metric.Name))
+			}
+		}
+	}
+	for _, result := range comparison.OnlyBaseline {
+		regressions = append(regressions, fmt.Sprintf("%s: 目前報告缺少此情境", scenarioLabel(result)))
+	}
+	for _, result := range comparison.Failed {
+		regressions = append(regressions, fmt.Sprintf("%s: 驗證失敗 (%s)", scenarioLabel(result), strings.Join(result.Failures, "; ")))
+	}
+	return regressions
+}
+
+// Compare 以 Tester Key、Operation、訊息大小和參數配對兩份報告的情境，並比較吞吐量和延遲
+//
+// threshold 為百分比，吞吐量下降或延遲上升超過 threshold 時視為退步，測量值 (Metrics) 不參與配對
+func Compare(baseline, current *Report, threshold float64) *Comparison {
+	comparison := &Comparison{
+		Threshold: threshold,
+	}
+
+	// 同一個 Key 出現多次時依出現順序配對
+	currentResults := map[string][]*Result{}
+	for _, result := range current.Results() {
+		key := scenarioKey(result)
+		currentResults[key] = append(currentResults[key], result)
+	}
+
+	matched := map[*Result]bool{}
+	for _, baselineResult := range baseline.Results() {
+		key := scenarioKey(baselineResult)
+		candidates := currentResults[key]
+		if len(candidates) == 0 {
+			comparison.OnlyBaseline = append(comparison.OnlyBaseline, baselineResult)
+			continue
+		}
+
+		currentResult := candidates[0]
+		currentResults[key] = candidates[1:]
+		matched[currentResult] = true
+		comparison.Scenarios = append(comparison.Scenarios, &ScenarioDelta{
+			Label:   scenarioLabel(baselineResult),
+			Metrics: compareMetrics(baselineResult, currentResult, threshold),
+		})
+	}
+
+	for _, result := range current.Results() {
+		if !matched[result] {
+			comparison.OnlyCurrent = append(comparison.OnlyCurrent, result)
+		}
+		if result.Failed() {
+			comparison.Failed = append(comparison.Failed, result)
+		}
+	}
+	return comparison
+}
+
+// compareMetrics 比較吞吐量、平均延遲和兩邊都有的延遲百分位數
+func compareMetrics(baseline, current *Result, threshold float64) []*MetricDelta {
+	metrics := []*MetricDelta{
+		newMetricDelta("msgs_per_sec", baseline.MsgsPerSec, current.MsgsPerSec, true, threshold),
+	}
+	if baseline.Latency == nil || current.Latency == nil {
+		return metrics
+	}
+
+	metrics = append(metrics, newMetricDelta("latency_avg_ms",
+		durationToMilliseconds(baseline.Latency.Average),
+		durationToMilliseconds(current.Latency.Average),
+		false,
+		threshold,
+	))
+
+	currentPercentiles := map[float64]time.Duration{}
+	for _, percentile := range current.Latency.Percentiles {
+		currentPercentiles[percentile.Percentile] = percentile.Value
+	}
+	for _, percentile := range baseline.Latency.Percentiles {
+		currentValue, ok := currentPercentiles[percentile.Percentile]
+		if !ok {
+			continue
+		}
+		metrics = append(metrics, newMetricDelta(
+			fmt.Sprintf("latency_%s_ms", PercentileLabel(percentile.Percentile)),
+			durationToMilliseconds(percentile.Value),
+			durationToMilliseconds(currentValue),
+			false,
+			threshold,
+		))
+	}
+	return metrics
+}
+
+func newMetricDelta(name string, baseline, current float64, higherIsBetter bool, threshold float64) *MetricDelta {
+	metric := &MetricDelta{
+		Name:           name,
+		Baseline:       baseline,
+		Current:        current,
+		HigherIsBetter: higherIsBetter,
+	}
+	if baseline == 0 {
+		return metric
+	}
+
+	metric.DeltaPercent = (current - baseline) / baseline * 100
+	if higherIsBetter {
+		metric.Regressed = metric.DeltaPercent < -threshold
+	} else {
+		metric.Regressed = metric.DeltaPercent > threshold
+	}
+	return metric
+}
+
+// scenarioKey 用來配對情境的 Key
+func scenarioKey(result *Result) string {
+	return fmt.Sprintf("%s|%s|%d|%s", result.TesterKey, result.Operation, result.MessageSize, sortedParams(result.Params))
+}
+
+// scenarioLabel 顯示用的情境名稱
+func scenarioLabel(result *Result) string {
+	label := fmt.Sprintf("%s [%s] size=%d", result.TesterKey, result.Operation, result.MessageSize)
+	if params := sortedParams(result.Params); params != "" {
+		label += " " + params
+	}
+	return label
+}
+
+func sortedParams(params map[string]string) string {
+	keys := make([]string, 0, len(params))
+	for key := range params {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	pairs := make([]string, 0, len(keys))
+	for _, key := range keys {
+		pairs = append(pairs, fmt.Sprintf("%s=%s", key, params[key]))
+	}
+	return strings.Join(pairs, ",")
+}
+
+// WriteComparison 以表格的方式輸出比較結果
+func WriteComparison(w io.Writer, comparison *Comparison) error {
+	writer := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(writer, "SCENARIO\tMETRIC\tBASELINE\tCURRENT\tDELTA\tSTATUS")
+	for _, scenario := range comparison.Scenarios {
+		for _, metric := range scenario.Metrics {
+			fmt.Fprintf(writer, "%s\t%s\t%s\t%s\t%s\t%s\n",
+				scenario.Label,
+				metric.Name,
+				formatFloat(metric.Baseline),
+				formatFloat(metric.Current),
+				formatDeltaPercent(metric),
+				formatDeltaStatus(metric, comparison.Threshold),
+			)
+		}
+	}
+	if err := writer.Flush(); err != nil {
+		return err
+	}
+
+	for _, result := range comparison.OnlyBaseline {
+		fmt.Fprintf(w, "只在基準報告中 (退步): %s\n", scenarioLabel(result))
+	}
+	for _, result := range comparison.OnlyCurrent {
+		fmt.Fprintf(w, "只在目前報告中: %s\n", scenarioLabel(result))
+	}
+	for _, result := range comparison.Failed {
+		fmt.Fprintf(w, "目前報告驗證失敗 (退步): %s (%s)\n", scenarioLabel(result), strings.Join(result.Failures, "; "))
+	}
+
+	regressions := comparison.Regressions()
+	_, err := fmt.Fprintf(w, "\n共比較 %d 個情境，%d 項退步 (指標退步超過 %.2f%%、缺少或驗證失敗的情境)\n", len(comparison.Scenarios), len(regressions), comparison.Threshold)
+	return err
+}
+
+func formatDeltaPercent(metric *MetricDelta) string {
+	if metric.Baseline == 0 {
+		return "N/A"
+	}
+	return fmt.Sprintf("%+.2f%%", metric.DeltaPercent)
+}
+
+// formatDeltaStatus 變化幅度沒有超過門檻時視為持平
+func formatDeltaStatus(metric *MetricDelta, threshold float64) string {
+	improvement := metric.DeltaPercent
+	if !metric.HigherIsBetter {
+		improvement = -improvement
+	}
+
+	switch {
+	case metric.Baseline == 0:
+		return "-"
+	case metric.Regressed:
+		return "退步"
+	case improvement > threshold:
+		return "進步"
+	default:
+		return "持平"
+	}
+}
@@ -0,0 +1,248 @@
+package report
+
+import (
+	"testing"
+	"time"
+)
+
+func TestScenarioKey(t *testing.T) {
+	base := func() *Result {
+		result := NewThroughputResult("publish", 1000, 64, time.Second)
+		result.TesterKey = "jetstream_publish_tester"
+		return result.SetParam("storage", "file").SetParam("replicas", 1)
+	}
+
+	testCases := []struct {
+		name      string
+		result    *Result
+		wantMatch bool
+	}{
+		{name: "same scenario", result: base(), wantMatch: true},
+		{name: "params in different order", result: func() *Result {
+			result := NewThroughputResult("publish", 1000, 64, time.Second)
+			result.TesterKey = "jetstream_publish_tester"
+			return result.SetParam("replicas", 1).SetParam("storage", "file")
+		}(), wantMatch: true},
+		{name: "metrics are ignored", result: base().SetMetric("stored_msgs", 10), wantMatch: true},
+		{name: "measurements are ignored", result: func() *Result {
+			result := base()
+			result.ElapsedTime = 5 * time.Second
+			result.MsgsPerSec = 1
+			result.MessageCount = 1
+			return result.AddFailure("failed")
+		}(), wantMatch: true},
+		{name: "different tester", result: func() *Result {
+			result := base()
+			result.TesterKey = "jetstream_async_publish_tester"
+			return result
+		}(), wantMatch: false},
+		{name: "different operation", result: func() *Result {
+			result := base()
+			result.Operation = "subscribe"
+			return result
+		}(), wantMatch: false},
+		{name: "different message size", result: func() *Result {
+			result := base()
+			result.MessageSize = 128
+			return result
+		}(), wantMatch: false},
+		{name: "different param value", result: base().SetParam("storage", "memory"), wantMatch: false},
+		{name: "extra param", result: base().SetParam("batch", 10), wantMatch: false},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			if got := scenarioKey(testCase.result) == scenarioKey(base()); got != testCase.wantMatch {
+				t.Errorf("scenarioKey(%q) 與基準相同為 %v，預期為 %v", scenarioKey(testCase.result), got, testCase.wantMatch)
+			}
+		})
+	}
+}
+
+func TestCompare(t *testing.T) {
+	newResult := func(testerKey string, size int, msgsPerSec float64, latencyAverage time.Duration) *Result {
+		result := &Result{
+			TesterKey:   testerKey,
+			Operation:   "publish",
+			MessageSize: size,
+			MsgsPerSec:  msgsPerSec,
+		}
+		if latencyAverage > 0 {
+			result.Latency = &LatencyStats{
+				Average:     latencyAverage,
+				Percentiles: []*LatencyPercentile{{Percentile: 99, Value: 2 * latencyAverage}},
+			}
+		}
+		return result
+	}
+	newReport := func(results ...*Result) *Report {
+		return &Report{Testers: []*TesterReport{{Results: results}}}
+	}
+
+	testCases := []struct {
+		name             string
+		baseline         *Report
+		current          *Report
+		threshold        float64
+		wantScenarios    int
+		wantOnlyBaseline int
+		wantOnlyCurrent  int
+		wantFailed       int
+		wantRegressions  []string
+	}{
+		{
+			name:          "no change",
+			baseline:      newReport(newResult("a", 64, 1000, time.Millisecond)),
+			current:       newReport(newResult("a", 64, 1000, time.Millisecond)),
+			threshold:     10,
+			wantScenarios: 1,
+		},
+		{
+			name:          "throughput drop within threshold",
+			baseline:      newReport(newResult("a", 64, 1000, 0)),
+			current:       newReport(newResult("a", 64, 900, 0)),
+			threshold:     10,
+			wantScenarios: 1,
+		},
+		{
+			name:            "throughput drop over threshold",
+			baseline:        newReport(newResult("a", 64, 1000, 0)),
+			current:         newReport(newResult("a", 64, 899, 0)),
+			threshold:       10,
+			wantScenarios:   1,
+			wantRegressions: []string{"a [publish] size=64: msgs_per_sec"},
+		},
+		{
+			name:          "throughput improvement",
+			baseline:      newReport(newResult("a", 64, 1000, 0)),
+			current:       newReport(newResult("a", 64, 2000, 0)),
+			threshold:     10,
+			wantScenarios: 1,
+		},
+		{
+			name:            "latency increase over threshold",
+			baseline:        newReport(newResult("a", 64, 1000, time.Millisecond)),
+			current:         newReport(newResult("a", 64, 1000, 2*time.Millisecond)),
+			threshold:       50,
+			wantScenarios:   1,
+			wantRegressions: []string{"a [publish] size=64: latency_avg_ms", "a [publish] size=64: latency_p99_ms"},
+		},
+		{
+			name:          "latency decrease",
+			baseline:      newReport(newResult("a", 64, 1000, 2*time.Millisecond)),
+			current:       newReport(newResult("a", 64, 1000, time.Millisecond)),
+			threshold:     10,
+			wantScenarios: 1,
+		},
+		{
+			name:          "zero baseline is not compared",
+			baseline:      newReport(newResult("a", 64, 0, 0)),
+			current:       newReport(newResult("a", 64, 1, 0)),
+			threshold:     0,
+			wantScenarios: 1,
+		},
+		{
+			name:          "metrics do not affect matching",
+			baseline:      newReport(newResult("a", 64, 1000, 0).SetMetric("stored_msgs", 100)),
+			current:       newReport(newResult("a", 64, 1000, 0).SetMetric("stored_msgs", 50)),
+			threshold:     10,
+			wantScenarios: 1,
+		},
+		{
+			name:             "missing scenario is a regression",
+			baseline:         newReport(newResult("a", 64, 1000, 0), newResult("a", 128, 1000, 0)),
+			current:          newReport(newResult("a", 64, 1000, 0)),
+			threshold:        10,
+			wantScenarios:    1,
+			wantOnlyBaseline: 1,
+			wantRegressions:  []string{"a [publish] size=128: 目前報告缺少此情境"},
+		},
+		{
+			name:            "new scenario is not a regression",
+			baseline:        newReport(newResult("a", 64, 1000, 0)),
+			current:         newReport(newResult("a", 64, 1000, 0), newResult("b", 64, 1000, 0)),
+			threshold:       10,
+			wantScenarios:   1,
+			wantOnlyCurrent: 1,
+		},
+		{
+			name:            "failed scenario is a regression",
+			baseline:        newReport(newResult("a", 64, 1000, 0)),
+			current:         newReport(newResult("a", 64, 1000, 0).AddFailure("遺失 1 筆")),
+			threshold:       10,
+			wantScenarios:   1,
+			wantFailed:      1,
+			wantRegressions: []string{"a [publish] size=64: 驗證失敗 (遺失 1 筆)"},
+		},
+		{
+			name:          "duplicated keys are matched in order",
+			baseline:      newReport(newResult("a", 64, 1000, 0), newResult("a", 64, 2000, 0)),
+			current:       newReport(newResult("a", 64, 1000, 0), newResult("a", 64, 2000, 0)),
+			threshold:     10,
+			wantScenarios: 2,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			comparison := Compare(testCase.baseline, testCase.current, testCase.threshold)
+			if len(comparison.Scenarios) != testCase.wantScenarios {
+				t.Errorf("配對的情境有 %d 個，預期為 %d 個", len(comparison.Scenarios), testCase.wantScenarios)
+			}
+			if len(comparison.OnlyBaseline) != testCase.wantOnlyBaseline {
+				t.Errorf("只在基準報告中的情境有 %d 個，預期為 %d 個", len(comparison.OnlyBaseline), testCase.wantOnlyBaseline)
+			}
+			if len(comparison.OnlyCurrent) != testCase.wantOnlyCurrent {
+				t.Errorf("只在目前報告中的情境有 %d 個，預期為 %d 個", len(comparison.OnlyCurrent), testCase.wantOnlyCurrent)
+			}
+			if len(comparison.Failed) != testCase.wantFailed {
+				t.Errorf("驗證失敗的情境有 %d 個，預期為 %d 個", len(comparison.Failed), testCase.wantFailed)
+			}
+
+			regressions := comparison.Regressions()
+			if comparison.HasRegression() != (len(testCase.wantRegressions) > 0) {
+				t.Errorf("HasRegression() = %v，退步為 %v", comparison.HasRegression(), regressions)
+			}
+			if len(regressions) != len(testCase.wantRegressions) {
+				t.Fatalf("退步為 %q，預期為 %q", regressions, testCase.wantRegressions)
+			}
+			for idx, want := range testCase.wantRegressions {
+				if regressions[idx] != want {
+					t.Errorf("第 %d 個退步為 %q，預期為 %q", idx+1, regressions[idx], want)
+				}
+			}
+		})
+	}
+}
+
+func TestNewMetricDelta(t *testing.T) {
+	testCases := []struct {
+		name           string
+		baseline       float64
+		current        float64
+		higherIsBetter bool
+		threshold      float64
+		wantDelta      float64
+		wantRegressed  bool
+	}{
+		{name: "zero baseline is not compared", baseline: 0, current: 100, higherIsBetter: true, threshold: 0},
+		{name: "throughput exactly at threshold", baseline: 100, current: 90, higherIsBetter: true, threshold: 10, wantDelta: -10},
+		{name: "throughput over threshold", baseline: 100, current: 80, higherIsBetter: true, threshold: 10, wantDelta: -20, wantRegressed: true},
+		{name: "throughput up", baseline: 100, current: 150, higherIsBetter: true, threshold: 10, wantDelta: 50},
+		{name: "latency exactly at threshold", baseline: 100, current: 110, higherIsBetter: false, threshold: 10, wantDelta: 10},
+		{name: "latency over threshold", baseline: 100, current: 120, higherIsBetter: false, threshold: 10, wantDelta: 20, wantRegressed: true},
+		{name: "latency down", baseline: 100, current: 50, higherIsBetter: false, threshold: 10, wantDelta: -50},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			metric := newMetricDelta("metric", testCase.baseline, testCase.current, testCase.higherIsBetter, testCase.threshold)
+			if metric.DeltaPercent != testCase.wantDelta {
+				t.Errorf("DeltaPercent 為 %v，預期為 %v", metric.DeltaPercent, testCase.wantDelta)
+			}
+			if metric.Regressed != testCase.wantRegressed {
+				t.Errorf("Regressed 為 %v，預期為 %v", metric.Regressed, testCase.wantRegressed)
+			}
+		})
+	}
+}
@@ -0,0 +1,319 @@
+package report
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/xerrors"
+)
+
+var csvHeader = []string{
+	"tester_key",
+	"operation",
+	"message_count",
+	"message_size",
+	"elapsed_time_ms",
+	"msgs_per_sec",
+	"mb_per_sec",
+	"latency_avg_ms",
+	"latency_min_ms",
+	"latency_max_ms",
+	"latency_std_dev_ms",
+}
+
+// integrityCSVHeader 訊息驗證的欄位 (有任何結果開啟驗證時才會輸出)
+var integrityCSVHeader = []string{
+	"integrity_received",
+	"integrity_lost",
+	"integrity_duplicated",
+	"integrity_out_of_order",
+	"integrity_corrupted",
+}
+
+// statsCSVSuffixes 每個重複測量指標輸出的欄位 (有任何結果重複測量時才會輸出，平均值即為原本的欄位)
+var statsCSVSuffixes = []string{"std_dev", "min", "max", "ci_low", "ci_high"}
+
+// WriteCSV 以 CSV 的格式輸出測試結果 (一個情境一列)
+func WriteCSV(w io.Writer, results []*Result) error {
+	percentiles := collectPercentiles(results)
+	hasIntegrity := hasIntegrityStats(results)
+	statsMetrics := collectStatsMetrics(results)
+	paramKeys := collectParamKeys(results)
+	metricKeys := collectMetricKeys(results)
+	hasFailures := hasResultFailures(results)
+
+	header := append([]string{}, csvHeader...)
+	for _, percentile := range percentiles {
+		header = append(header, fmt.Sprintf("latency_%s_ms", PercentileLabel(percentile)))
+	}
+	if hasIntegrity {
+		header = append(header, integrityCSVHeader...)
+	}
+	if len(statsMetrics) > 0 {
+		header = append(header, "iterations")
+		for _, metric := range statsMetrics {
+			for _, suffix := range statsCSVSuffixes {
+				header = append(header, fmt.Sprintf("%s_%s", metric, suffix))
+			}
+		}
+	}
+	header = append(header, paramKeys...)
+	header = append(header, metricKeys...)
+	if hasFailures {
+		header = append(header, "failures")
+	}
+
+	writer := csv.NewWriter(w)
+	if err := writer.Write(header); err != nil {
+		return xerrors.Errorf("輸出 CSV 報告失敗: %w", err)
+	}
+
+	for _, result := range results {
+		record := []string{
+			result.TesterKey,
+			result.Operation,
+			strconv.Itoa(result.MessageCount),
+			strconv.Itoa(result.MessageSize),
+			formatMilliseconds(result.ElapsedTime),
+			formatFloat(result.MsgsPerSec),
+			formatFloat(result.MBPerSec),
+		}
+
+		if result.Latency != nil {
+			record = append(record,
+				formatMilliseconds(result.Latency.Average),
+				formatMilliseconds(result.Latency.Min),
+				formatMilliseconds(result.Latency.Max),
+				formatMilliseconds(result.Latency.StdDev),
+			)
+		} else {
+			record = append(record, "", "", "", "")
+		}
+
+		for _, percentile := range percentiles {
+			record = append(record, formatPercentile(result.Latency, percentile))
+		}
+
+		if hasIntegrity {
+			record = append(record, formatIntegrity(result.Integrity)...)
+		}
+
+		if len(statsMetrics) > 0 {
+			record = append(record, formatStats(result.Stats, statsMetrics)...)
+		}
+
+		for _, key := range paramKeys {
+			record = append(record, result.Params[key])
+		}
+
+		for _, key := range metricKeys {
+			if value, ok := result.Metrics[key]; ok {
+				record = append(record, strconv.FormatFloat(value, 'f', -1, 64))
+			} else {
+				record = append(record, "")
+			}
+		}
+
+		if hasFailures {
+			record = append(record, strings.Join(result.Failures, "; "))
+		}
+
+		if err := writer.Write(record); err != nil {
+			return xerrors.Errorf("輸出 CSV 報告失敗: %w", err)
+		}
+	}
+
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return xerrors.Errorf("輸出 CSV 報告失敗: %w", err)
+	}
+	return nil
+}
+
+// SaveCSVFile 將測試結果存成 CSV 檔
+func SaveCSVFile(path string, results []*Result) error {
+	file, err := createFile(path)
+	if err != nil {
+		return xerrors.Errorf("儲存 CSV 報告失敗: %w", err)
+	}
+	defer file.Close()
+
+	if err := WriteCSV(file, results); err != nil {
+		return xerrors.Errorf("儲存 CSV 報告失敗: %w", err)
+	}
+	return nil
+}
+
+// collectPercentiles 取得所有結果用到的百分位數 (排序後)
+func collectPercentiles(results []*Result) []float64 {
+	percentileSet := map[float64]bool{}
+	for _, result := range results {
+		if result.Latency == nil {
+			continue
+		}
+		for _, percentile := range result.Latency.Percentiles {
+			percentileSet[percentile.Percentile] = true
+		}
+	}
+
+	percentiles := make([]float64, 0, len(percentileSet))
+	for percentile := range percentileSet {
+		percentiles = append(percentiles, percentile)
+	}
+	sort.Float64s(percentiles)
+	return percentiles
+}
+
+func formatPercentile(latency *LatencyStats, percentile float64) string {
+	if latency == nil {
+		return ""
+	}
+
+	for _, latencyPercentile := range latency.Percentiles {
+		if latencyPercentile.Percentile == percentile {
+			return formatMilliseconds(latencyPercentile.Value)
+		}
+	}
+	return ""
+}
+
+// hasIntegrityStats 是否有任何結果開啟訊息驗證
+func hasIntegrityStats(results []*Result) bool {
+	for _, result := range results {
+		if result.Integrity != nil {
+			return true
+		}
+	}
+	return false
+}
+
+func formatIntegrity(integrity *IntegrityStats) []string {
+	if integrity == nil {
+		return make([]string, len(integrityCSVHeader))
+	}
+
+	return []string{
+		strconv.Itoa(integrity.Received),
+		strconv.Itoa(integrity.Lost),
+		strconv.Itoa(integrity.Duplicated),
+		strconv.Itoa(integrity.OutOfOrder),
+		strconv.Itoa(integrity.Corrupted),
+	}
+}
+
+// collectStatsMetrics 取得所有結果重複測量的指標名稱 (依出現順序)
+func collectStatsMetrics(results []*Result) []string {
+	var metrics []string
+	seen := map[string]bool{}
+	for _, result := range results {
+		if result.Stats == nil {
+			continue
+		}
+		for _, metric := range result.Stats.Metrics {
+			if !seen[metric.Name] {
+				seen[metric.Name] = true
+				metrics = append(metrics, metric.Name)
+			}
+		}
+	}
+	return metrics
+}
+
+func formatStats(stats *IterationStats, metrics []string) []string {
+	record := make([]string, 0, 1+len(metrics)*len(statsCSVSuffixes))
+	if stats == nil {
+		for i := 0; i < cap(record); i++ {
+			record = append(record, "")
+		}
+		return record
+	}
+
+	record = append(record, strconv.Itoa(stats.Iterations))
+	for _, name := range metrics {
+		metric := stats.metric(name)
+		if metric == nil {
+			record = append(record, "", "", "", "", "")
+			continue
+		}
+		record = append(record,
+			formatFloat(metric.StdDev),
+			formatFloat(metric.Min),
+			formatFloat(metric.Max),
+			formatFloat(metric.CILow),
+			formatFloat(metric.CIHigh),
+		)
+	}
+	return record
+}
+
+// collectParamKeys 取得所有結果用到的情境參數 (排序後)
+func collectParamKeys(results []*Result) []string {
+	keySet := map[string]bool{}
+	for _, result := range results {
+		for key := range result.Params {
+			keySet[key] = true
+		}
+	}
+
+	keys := make([]string, 0, len(keySet))
+	for key := range keySet {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// collectMetricKeys 取得所有結果用到的測量值 (排序後)
+func collectMetricKeys(results []*Result) []string {
+	keySet := map[string]bool{}
+	for _, result := range results {
+		for key := range result.Metrics {
+			keySet[key] = true
+		}
+	}
+
+	keys := make([]string, 0, len(keySet))
+	for key := range keySet {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// hasResultFailures 是否有任何結果正確性檢查失敗
+func hasResultFailures(results []*Result) bool {
+	for _, result := range results {
+		if result.Failed() {
+			return true
+		}
+	}
+	return false
+}
+
+func formatMilliseconds(duration time.Duration) string {
+	return formatFloat(float64(duration) / float64(time.Millisecond))
+}
+
+func formatFloat(value float64) string {
+	return strconv.FormatFloat(value, 'f', 3, 64)
+}
+
+// createFile 建立檔案 (會一併建立所需的資料夾)
+func createFile(path string) (*os.File, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, xerrors.Errorf("建立資料夾失敗: %w", err)
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, xerrors.Errorf("建立檔案 %s 失敗: %w", path, err)
+	}
+	return file, nil
+}
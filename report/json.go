@@ -0,0 +1,48 @@
+package report
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+
+	"golang.org/x/xerrors"
+)
+
+// WriteJSON 以 JSON 的格式輸出報告
+func WriteJSON(w io.Writer, report *Report) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(report); err != nil {
+		return xerrors.Errorf("輸出 JSON 報告失敗: %w", err)
+	}
+	return nil
+}
+
+// SaveJSONFile 將報告存成 JSON 檔
+func SaveJSONFile(path string, report *Report) error {
+	file, err := createFile(path)
+	if err != nil {
+		return xerrors.Errorf("儲存 JSON 報告失敗: %w", err)
+	}
+	defer file.Close()
+
+	if err := WriteJSON(file, report); err != nil {
+		return xerrors.Errorf("儲存 JSON 報告失敗: %w", err)
+	}
+	return nil
+}
+
+// LoadJSONFile 讀取之前儲存的 JSON 報告
+func LoadJSONFile(path string) (*Report, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, xerrors.Errorf("讀取 JSON 報告失敗: %w", err)
+	}
+	defer file.Close()
+
+	var report Report
+	if err := json.NewDecoder(file).Decode(&report); err != nil {
+		return nil, xerrors.Errorf("解析 JSON 報告 %s 失敗: %w", path, err)
+	}
+	return &report, nil
+}
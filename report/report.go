@@ -0,0 +1,71 @@
+package report
+
+import (
+	"time"
+)
+
+// Report 整個測試流程的報告
+type Report struct {
+	StartedAt   time.Time       `json:"started_at"`
+	FinishedAt  time.Time       `json:"finished_at"`
+	Testers     []*TesterReport `json:"testers"`
+	Interrupted bool            `json:"interrupted,omitempty"` // 是否被中斷 (只包含中斷前執行的 Tester)
+	RunID       string          `json:"run_id,omitempty"`      // 啟用資源隔離時這次執行的 ID (包含在 Stream, Subject 和 Channel 的名稱中)
+}
+
+// TesterReport 單一 Tester 的報告
+type TesterReport struct {
+	Key         string         `json:"key"`
+	Name        string         `json:"name"`
+	ElapsedTime time.Duration  `json:"elapsed_time"`
+	Results     []*Result      `json:"results"`
+	Error       string         `json:"error,omitempty"`  // 失敗時的錯誤 (包含完整的錯誤鏈)
+	Server      *ServerMetrics `json:"server,omitempty"` // 啟用 monitoring 時 Server 在整個 Tester 執行前後的監控數據 (不是個別的測試結果)
+	Client      *ClientMetrics `json:"client,omitempty"` // 啟用 profiling 時 Client 在整個 Tester 執行期間的 Runtime 統計
+}
+
+// Failed Tester 是否失敗
+func (testerReport *TesterReport) Failed() bool {
+	return testerReport.Error != ""
+}
+
+func NewReport() *Report {
+	return &Report{
+		StartedAt: time.Now(),
+	}
+}
+
+// AddTesterReport 加入 Tester 的報告 (會補上結果的 TesterKey)
+func (report *Report) AddTesterReport(testerReport *TesterReport) {
+	for _, result := range testerReport.Results {
+		if result.TesterKey == "" {
+			result.TesterKey = testerReport.Key
+		}
+	}
+	report.Testers = append(report.Testers, testerReport)
+}
+
+// Finish 結束報告
+func (report *Report) Finish() {
+	report.FinishedAt = time.Now()
+}
+
+// FailedTesters 取得所有失敗的 Tester 的 Key
+func (report *Report) FailedTesters() []string {
+	var keys []string
+	for _, testerReport := range report.Testers {
+		if testerReport.Failed() {
+			keys = append(keys, testerReport.Key)
+		}
+	}
+	return keys
+}
+
+// Results 取得所有 Tester 的測試結果
+func (report *Report) Results() []*Result {
+	var results []*Result
+	for _, testerReport := range report.Testers {
+		results = append(results, testerReport.Results...)
+	}
+	return results
+}
@@ -0,0 +1,146 @@
+package report
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// Result 單一測試情境的結果
+type Result struct {
+	TesterKey    string             `json:"tester_key"`
+	Operation    string             `json:"operation"`
+	Params       map[string]string  `json:"params,omitempty"`   // 情境參數 (例如 Storage, 一次抓取筆數)，只能放設定，用來比對不同報告的情境
+	Metrics      map[string]float64 `json:"metrics,omitempty"`  // 情境特有的測量值 (例如發布錯誤次數、保存的訊息數量)，不參與情境比對
+	Failures     []string           `json:"failures,omitempty"` // 正確性檢查失敗的原因 (例如去除重複訊息的結果不符合預期)
+	MessageCount int                `json:"message_count"`
+	MessageSize  int                `json:"message_size"`
+	ElapsedTime  time.Duration      `json:"elapsed_time"`
+	MsgsPerSec   float64            `json:"msgs_per_sec"`
+	MBPerSec     float64            `json:"mb_per_sec"`
+	Latency      *LatencyStats      `json:"latency,omitempty"`
+	Integrity    *IntegrityStats    `json:"integrity,omitempty"` // 訊息驗證的結果 (有開啟驗證時才會有)
+	Stats        *IterationStats    `json:"stats,omitempty"`     // 重複測量的統計 (重複次數大於 1 時才會有，其他欄位為平均值)
+}
+
+// IntegrityStats 訊息驗證的統計
+type IntegrityStats struct {
+	Received   int `json:"received"`     // 收到的訊息總數 (包含重複和損毀的訊息)
+	Lost       int `json:"lost"`         // 沒有收到的訊息
+	Duplicated int `json:"duplicated"`   // 重複收到的訊息
+	OutOfOrder int `json:"out_of_order"` // 序號比之前收到的還小的訊息
+	Corrupted  int `json:"corrupted"`    // Checksum 不符的訊息
+}
+
+// OK 是否沒有任何遺失、重複、亂序或損毀的訊息
+func (stats *IntegrityStats) OK() bool {
+	return stats.Lost == 0 && stats.Duplicated == 0 && stats.OutOfOrder == 0 && stats.Corrupted == 0
+}
+
+// SetIntegrity 設定訊息驗證的結果 (stats 為 nil 代表沒有開啟驗證)，有遺失、重複、亂序或損毀的訊息時會記為驗證失敗
+func (result *Result) SetIntegrity(stats *IntegrityStats) *Result {
+	result.Integrity = stats
+	if stats != nil && !stats.OK() {
+		result.AddFailure("訊息驗證異常 (遺失 %d 筆, 重複 %d 筆, 亂序 %d 筆, 損毀 %d 筆)", stats.Lost, stats.Duplicated, stats.OutOfOrder, stats.Corrupted)
+	}
+	return result
+}
+
+// SetAtLeastOnceIntegrity 設定 At-Least-Once 情境 (例如重試發布) 的訊息驗證結果，重複和亂序是預期的行為，只有遺失或損毀的訊息會記為驗證失敗
+func (result *Result) SetAtLeastOnceIntegrity(stats *IntegrityStats) *Result {
+	result.Integrity = stats
+	if stats != nil && (stats.Lost > 0 || stats.Corrupted > 0) {
+		result.AddFailure("訊息驗證異常 (遺失 %d 筆, 損毀 %d 筆)", stats.Lost, stats.Corrupted)
+	}
+	return result
+}
+
+// LatencyStats 延遲統計
+type LatencyStats struct {
+	Average      time.Duration        `json:"average"`
+	Min          time.Duration        `json:"min"`
+	Max          time.Duration        `json:"max"`
+	StdDev       time.Duration        `json:"std_dev"`
+	Percentiles  []*LatencyPercentile `json:"percentiles,omitempty"`
+	Distribution []*LatencyBucket     `json:"distribution,omitempty"` // 延遲分布 (有開啟分布圖時才會有)
+}
+
+// LatencyPercentile 延遲的百分位數
+type LatencyPercentile struct {
+	Percentile float64       `json:"percentile"`
+	Value      time.Duration `json:"value"`
+}
+
+// LatencyBucket 延遲分布的區間
+type LatencyBucket struct {
+	From  time.Duration `json:"from"`
+	To    time.Duration `json:"to"`
+	Count int64         `json:"count"`
+}
+
+// NewThroughputResult 建立吞吐量的測試結果
+func NewThroughputResult(operation string, messageCount, messageSize int, elapsedTime time.Duration) *Result {
+	result := &Result{
+		Operation:    operation,
+		MessageCount: messageCount,
+		MessageSize:  messageSize,
+		ElapsedTime:  elapsedTime,
+	}
+
+	if elapsedTime > 0 {
+		seconds := elapsedTime.Seconds()
+		result.MsgsPerSec = float64(messageCount) / seconds
+		result.MBPerSec = float64(messageCount*messageSize) / seconds / 1024 / 1024
+	}
+
+	return result
+}
+
+// NewLatencyResult 建立延遲的測試結果
+func NewLatencyResult(operation string, messageCount, messageSize int, elapsedTime time.Duration, latency *LatencyStats) *Result {
+	result := NewThroughputResult(operation, messageCount, messageSize, elapsedTime)
+	result.Latency = latency
+	return result
+}
+
+// PercentileLabel 百分位數的標籤 (例如 p99.9)
+func PercentileLabel(percentile float64) string {
+	return "p" + strconv.FormatFloat(percentile, 'f', -1, 64)
+}
+
+// SetParam 設定情境參數
+func (result *Result) SetParam(key string, value interface{}) *Result {
+	if result.Params == nil {
+		result.Params = map[string]string{}
+	}
+	result.Params[key] = fmt.Sprint(value)
+	return result
+}
+
+// SetMetric 設定測量值 (時間以毫秒表示，名稱加上 _ms)
+func (result *Result) SetMetric(key string, value float64) *Result {
+	if result.Metrics == nil {
+		result.Metrics = map[string]float64{}
+	}
+	result.Metrics[key] = value
+	return result
+}
+
+// AddFailure 記錄正確性檢查失敗的原因
+func (result *Result) AddFailure(format string, args ...interface{}) *Result {
+	result.Failures = append(result.Failures, fmt.Sprintf(format, args...))
+	return result
+}
+
+// Failed 是否有正確性檢查失敗
+func (result *Result) Failed() bool {
+	return len(result.Failures) > 0
+}
+
+// AverageTime 每筆平均花費時間
+func (result *Result) AverageTime() time.Duration {
+	if result.MessageCount == 0 {
+		return 0
+	}
+	return result.ElapsedTime / time.Duration(result.MessageCount)
+}
@@ -0,0 +1,42 @@
+package report
+
+import (
+	"testing"
+)
+
+func TestSetIntegrity(t *testing.T) {
+	testCases := []struct {
+		name                  string
+		stats                 *IntegrityStats
+		wantFailed            bool
+		wantAtLeastOnceFailed bool
+	}{
+		{name: "not verified", stats: nil},
+		{name: "all received", stats: &IntegrityStats{Received: 100}},
+		{name: "duplicated only", stats: &IntegrityStats{Received: 110, Duplicated: 10}, wantFailed: true},
+		{name: "out of order only", stats: &IntegrityStats{Received: 100, OutOfOrder: 3}, wantFailed: true},
+		{name: "duplicated and out of order", stats: &IntegrityStats{Received: 110, Duplicated: 10, OutOfOrder: 3}, wantFailed: true},
+		{name: "lost", stats: &IntegrityStats{Received: 90, Lost: 10}, wantFailed: true, wantAtLeastOnceFailed: true},
+		{name: "corrupted", stats: &IntegrityStats{Received: 100, Lost: 1, Corrupted: 1}, wantFailed: true, wantAtLeastOnceFailed: true},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			result := NewThroughputResult("publish", 100, 64, 0).SetIntegrity(testCase.stats)
+			if result.Integrity != testCase.stats {
+				t.Errorf("SetIntegrity 沒有保留驗證結果")
+			}
+			if result.Failed() != testCase.wantFailed {
+				t.Errorf("SetIntegrity 的失敗原因為 %v，預期失敗為 %v", result.Failures, testCase.wantFailed)
+			}
+
+			result = NewThroughputResult("publish", 100, 64, 0).SetAtLeastOnceIntegrity(testCase.stats)
+			if result.Integrity != testCase.stats {
+				t.Errorf("SetAtLeastOnceIntegrity 沒有保留驗證結果")
+			}
+			if result.Failed() != testCase.wantAtLeastOnceFailed {
+				t.Errorf("SetAtLeastOnceIntegrity 的失敗原因為 %v，預期失敗為 %v", result.Failures, testCase.wantAtLeastOnceFailed)
+			}
+		})
+	}
+}
@@ -0,0 +1,156 @@
+package report
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// ServerMetrics Tester 執行前後 Server 監控端點的快照 (包含暖身和重複測量)
+//
+// 快照只在整個 Tester 的前後擷取，數值為 Tester 所有情境的合計，無法區分個別的測試結果
+type ServerMetrics struct {
+	Before *ServerSnapshot `json:"before"`
+	After  *ServerSnapshot `json:"after"`
+}
+
+// ServerSnapshot 某個時間點所有 Server 監控端點的數據
+type ServerSnapshot struct {
+	Time      time.Time             `json:"time"`
+	NATS      []*NATSServerStats    `json:"nats,omitempty"` // 每個 NATS Server 節點 (/varz, /jsz, /connz)
+	Streaming *StreamingServerStats `json:"streaming,omitempty"`
+	Errors    []string              `json:"errors,omitempty"` // 無法取得的端點 (不會讓 Tester 失敗)
+}
+
+// NATSServerStats NATS Server 監控端點的數據
+type NATSServerStats struct {
+	URL           string          `json:"url"`
+	ServerID      string          `json:"server_id"`
+	CPU           float64         `json:"cpu"`    // CPU 使用率 (百分比)
+	Memory        int64           `json:"memory"` // 記憶體用量 (bytes)
+	Connections   int             `json:"connections"`
+	InMsgs        int64           `json:"in_msgs"`
+	OutMsgs       int64           `json:"out_msgs"`
+	InBytes       int64           `json:"in_bytes"`
+	OutBytes      int64           `json:"out_bytes"`
+	SlowConsumers int64           `json:"slow_consumers"`
+	PendingBytes  int64           `json:"pending_bytes"` // 所有連線等待送出的資料量 (/connz)
+	JetStream     *JetStreamUsage `json:"jetstream,omitempty"`
+}
+
+// JetStreamUsage JetStream 的儲存用量 (/jsz)
+type JetStreamUsage struct {
+	Memory    uint64 `json:"memory"`  // Memory Storage 使用的 bytes
+	Storage   uint64 `json:"storage"` // File Storage 使用的 bytes
+	Streams   int    `json:"streams"`
+	Consumers int    `json:"consumers"`
+	Messages  uint64 `json:"messages"`
+	Bytes     uint64 `json:"bytes"`
+	APITotal  uint64 `json:"api_total"`
+	APIErrors uint64 `json:"api_errors"`
+}
+
+// StreamingServerStats NATS Streaming Server 監控端點的數據 (/streaming/serverz)
+type StreamingServerStats struct {
+	URL           string `json:"url"`
+	Clients       int    `json:"clients"`
+	Subscriptions int    `json:"subscriptions"`
+	Channels      int    `json:"channels"`
+	TotalMsgs     int    `json:"total_msgs"`
+	TotalBytes    uint64 `json:"total_bytes"`
+	InMsgs        int64  `json:"in_msgs"`
+	InBytes       int64  `json:"in_bytes"`
+	OutMsgs       int64  `json:"out_msgs"`
+	OutBytes      int64  `json:"out_bytes"`
+}
+
+// natsServerStats 依監控位置取得節點的數據
+func (snapshot *ServerSnapshot) natsServerStats(url string) *NATSServerStats {
+	for _, stats := range snapshot.NATS {
+		if stats.URL == url {
+			return stats
+		}
+	}
+	return nil
+}
+
+// WriteServerMetrics 以文字的方式輸出 Server 在 Tester 執行前後的變化 (累計的數值以差異表示)
+func WriteServerMetrics(w io.Writer, metrics *ServerMetrics) error {
+	if metrics == nil || metrics.Before == nil || metrics.After == nil {
+		return nil
+	}
+
+	lines := []string{"Server 監控數據 (整個 Tester 執行前後，包含所有情境、暖身和重複測量)："}
+	for _, after := range metrics.After.NATS {
+		before := metrics.Before.natsServerStats(after.URL)
+		if before == nil {
+			continue
+		}
+
+		// Server 重啟後累計的數值會歸零，差異改為重啟後的數值
+		restarted := before.ServerID != after.ServerID
+		line := fmt.Sprintf("[Server %s] CPU： %.1f%% → %.1f%%, 記憶體： %s → %s, 連線： %d → %d, 流入： +%d 筆 (+%s), 流出： +%d 筆 (+%s), Slow Consumers： +%d, 等待送出： %s",
+			after.URL,
+			before.CPU, after.CPU,
+			formatBytes(before.Memory), formatBytes(after.Memory),
+			before.Connections, after.Connections,
+			counterDelta(before.InMsgs, after.InMsgs, restarted), formatBytes(counterDelta(before.InBytes, after.InBytes, restarted)),
+			counterDelta(before.OutMsgs, after.OutMsgs, restarted), formatBytes(counterDelta(before.OutBytes, after.OutBytes, restarted)),
+			counterDelta(before.SlowConsumers, after.SlowConsumers, restarted),
+			formatBytes(after.PendingBytes),
+		)
+		if restarted {
+			line += " (Server 已重啟，只計算重啟後的數值)"
+		}
+		lines = append(lines, line)
+
+		if before.JetStream != nil && after.JetStream != nil {
+			lines = append(lines, fmt.Sprintf("    JetStream 記憶體： %s → %s, 儲存： %s → %s, Stream： %d → %d, Consumer： %d → %d, API 呼叫： +%d (錯誤 +%d)",
+				formatBytes(int64(before.JetStream.Memory)), formatBytes(int64(after.JetStream.Memory)),
+				formatBytes(int64(before.JetStream.Storage)), formatBytes(int64(after.JetStream.Storage)),
+				before.JetStream.Streams, after.JetStream.Streams,
+				before.JetStream.Consumers, after.JetStream.Consumers,
+				counterDelta(int64(before.JetStream.APITotal), int64(after.JetStream.APITotal), restarted),
+				counterDelta(int64(before.JetStream.APIErrors), int64(after.JetStream.APIErrors), restarted),
+			))
+		}
+	}
+
+	if before, after := metrics.Before.Streaming, metrics.After.Streaming; before != nil && after != nil {
+		lines = append(lines, fmt.Sprintf("[Streaming %s] Channel： %d → %d, Client： %d → %d, 保存訊息： %d → %d (%s → %s), 流入： +%d 筆 (+%s), 流出： +%d 筆 (+%s)",
+			after.URL,
+			before.Channels, after.Channels,
+			before.Clients, after.Clients,
+			before.TotalMsgs, after.TotalMsgs,
+			formatBytes(int64(before.TotalBytes)), formatBytes(int64(after.TotalBytes)),
+			counterDelta(before.InMsgs, after.InMsgs, false), formatBytes(counterDelta(before.InBytes, after.InBytes, false)),
+			counterDelta(before.OutMsgs, after.OutMsgs, false), formatBytes(counterDelta(before.OutBytes, after.OutBytes, false)),
+		))
+	}
+
+	for _, snapshot := range []*ServerSnapshot{metrics.Before, metrics.After} {
+		for _, snapshotErr := range snapshot.Errors {
+			lines = append(lines, fmt.Sprintf("[Server] 無法取得監控數據: %s", snapshotErr))
+		}
+	}
+
+	if len(lines) == 1 {
+		return nil
+	}
+	_, err := fmt.Fprintln(w, strings.Join(lines, "\n"))
+	return err
+}
+
+// counterDelta 累計數值的差異 (Server 重啟或數值變小時代表計數器已歸零，改用目前的數值)
+func counterDelta(before, after int64, restarted bool) int64 {
+	if restarted || after < before {
+		return after
+	}
+	return after - before
+}
+
+// formatBytes 以 MB 顯示資料量 (和吞吐量的 MB/s 使用相同單位)
+func formatBytes(bytes int64) string {
+	return fmt.Sprintf("%.2f MB", float64(bytes)/1024/1024)
+}
@@ -0,0 +1,270 @@
+package report
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"golang.org/x/xerrors"
+)
+
+// ConfidenceLevel 信賴區間的信賴水準
+const ConfidenceLevel = 0.95
+
+// sortedMetricKeys 排序後的測量值名稱 (讓統計的順序固定)
+func sortedMetricKeys(metrics map[string]float64) []string {
+	keys := make([]string, 0, len(metrics))
+	for key := range metrics {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// tCriticalValues 95% 信賴水準 (雙尾) 的 t 分配臨界值，索引為自由度 (超過表格範圍時使用常態分配的 1.96)
+var tCriticalValues = []float64{
+	0, 12.706, 4.303, 3.182, 2.776, 2.571, 2.447, 2.365, 2.306, 2.262,
+	2.228, 2.201, 2.179, 2.160, 2.145, 2.131, 2.120, 2.110, 2.101, 2.093,
+	2.086, 2.080, 2.074, 2.069, 2.064, 2.060, 2.056, 2.052, 2.048, 2.045,
+	2.042,
+}
+
+// IterationStats 重複測量的統計 (重複次數大於 1 時才會有)
+type IterationStats struct {
+	Iterations int            `json:"iterations"`
+	Confidence float64        `json:"confidence"` // 信賴區間的信賴水準
+	Metrics    []*MetricStats `json:"metrics"`
+}
+
+// MetricStats 單一指標在多次測量間的統計
+type MetricStats struct {
+	Name   string  `json:"name"` // 例如 msgs_per_sec, latency_avg_ms, latency_p99_ms
+	Mean   float64 `json:"mean"`
+	StdDev float64 `json:"std_dev"` // 樣本標準差
+	Min    float64 `json:"min"`
+	Max    float64 `json:"max"`
+	CILow  float64 `json:"ci_low"` // 平均值的信賴區間
+	CIHigh float64 `json:"ci_high"`
+}
+
+// AggregateIterations 合併多次測量的結果 (每次測量的結果需要有相同的數量和順序)
+//
+// 合併後的結果以平均值為主 (測量值同樣取平均，延遲的最小和最大值取所有測量中的極值，訊息驗證則是加總，
+// 任何一次測量的驗證失敗都會保留)，並附上每個指標的統計，情境參數不同時回傳錯誤
+func AggregateIterations(iterations [][]*Result) ([]*Result, error) {
+	if len(iterations) == 0 {
+		return nil, nil
+	}
+	if len(iterations) == 1 {
+		return iterations[0], nil
+	}
+
+	resultCount := len(iterations[0])
+	for idx, results := range iterations {
+		if len(results) != resultCount {
+			return nil, xerrors.Errorf("第 %d 次測量的結果數量 (%d) 和第 1 次 (%d) 不同", idx+1, len(results), resultCount)
+		}
+	}
+
+	aggregatedResults := make([]*Result, 0, resultCount)
+	for i := 0; i < resultCount; i++ {
+		samples := make([]*Result, 0, len(iterations))
+		for idx, results := range iterations {
+			result := results[i]
+			if scenarioKey(result) != scenarioKey(iterations[0][i]) {
+				return nil, xerrors.Errorf("第 %d 次測量的第 %d 個結果 (%s) 和第 1 次 (%s) 不同", idx+1, i+1, scenarioLabel(result), scenarioLabel(iterations[0][i]))
+			}
+			samples = append(samples, result)
+		}
+		aggregatedResults = append(aggregatedResults, aggregateResult(samples))
+	}
+	return aggregatedResults, nil
+}
+
+// aggregateResult 合併同一個情境多次測量的結果
+func aggregateResult(samples []*Result) *Result {
+	first := samples[0]
+	aggregated := *first
+	aggregated.Params = map[string]string{}
+	for key, value := range first.Params {
+		aggregated.Params[key] = value
+	}
+	aggregated.Failures = nil
+
+	// 依序收集每個指標的數值
+	var metricNames []string
+	metricValues := map[string][]float64{}
+	addMetric := func(name string, value float64) {
+		if _, ok := metricValues[name]; !ok {
+			metricNames = append(metricNames, name)
+		}
+		metricValues[name] = append(metricValues[name], value)
+	}
+	for idx, sample := range samples {
+		for _, failure := range sample.Failures {
+			aggregated.AddFailure("第 %d 次測量: %s", idx+1, failure)
+		}
+
+		addMetric("elapsed_time_ms", durationToMilliseconds(sample.ElapsedTime))
+		addMetric("msgs_per_sec", sample.MsgsPerSec)
+		addMetric("mb_per_sec", sample.MBPerSec)
+		if sample.Latency != nil {
+			addMetric("latency_avg_ms", durationToMilliseconds(sample.Latency.Average))
+			addMetric("latency_max_ms", durationToMilliseconds(sample.Latency.Max))
+			for _, percentile := range sample.Latency.Percentiles {
+				addMetric("latency_"+PercentileLabel(percentile.Percentile)+"_ms", durationToMilliseconds(percentile.Value))
+			}
+		}
+		for _, key := range sortedMetricKeys(sample.Metrics) {
+			addMetric(key, sample.Metrics[key])
+		}
+	}
+
+	stats := &IterationStats{
+		Iterations: len(samples),
+		Confidence: ConfidenceLevel,
+	}
+	for _, name := range metricNames {
+		stats.Metrics = append(stats.Metrics, newMetricStats(name, metricValues[name]))
+	}
+	aggregated.Stats = stats
+
+	aggregated.ElapsedTime = millisecondsToDuration(stats.metric("elapsed_time_ms").Mean)
+	aggregated.MsgsPerSec = stats.metric("msgs_per_sec").Mean
+	aggregated.MBPerSec = stats.metric("mb_per_sec").Mean
+	if first.Latency != nil {
+		aggregated.Latency = aggregateLatency(samples)
+	}
+	aggregated.Integrity = aggregateIntegrity(samples)
+
+	// 測量值取平均 (每次測量都會重建 Stream，例如保存的訊息數量和發布錯誤次數都是單次測量的數值)，最小和最大值可從統計中查看
+	aggregated.Metrics = nil
+	for _, key := range sortedMetricKeys(first.Metrics) {
+		if metric := stats.metric(key); metric != nil {
+			aggregated.SetMetric(key, metric.Mean)
+		}
+	}
+	return &aggregated
+}
+
+// aggregateLatency 合併延遲 (平均值、標準差和百分位數取平均，最小和最大值取極值，分布圖使用最後一次測量)
+func aggregateLatency(samples []*Result) *LatencyStats {
+	var latencies []*LatencyStats
+	for _, sample := range samples {
+		if sample.Latency != nil {
+			latencies = append(latencies, sample.Latency)
+		}
+	}
+
+	last := latencies[len(latencies)-1]
+	aggregated := &LatencyStats{
+		Min:          latencies[0].Min,
+		Max:          latencies[0].Max,
+		Distribution: last.Distribution,
+	}
+
+	var average, stdDev time.Duration
+	percentileSums := map[float64]time.Duration{}
+	for _, latency := range latencies {
+		average += latency.Average
+		stdDev += latency.StdDev
+		if latency.Min < aggregated.Min {
+			aggregated.Min = latency.Min
+		}
+		if latency.Max > aggregated.Max {
+			aggregated.Max = latency.Max
+		}
+		for _, percentile := range latency.Percentiles {
+			percentileSums[percentile.Percentile] += percentile.Value
+		}
+	}
+
+	count := time.Duration(len(latencies))
+	aggregated.Average = average / count
+	aggregated.StdDev = stdDev / count
+	for _, percentile := range last.Percentiles {
+		aggregated.Percentiles = append(aggregated.Percentiles, &LatencyPercentile{
+			Percentile: percentile.Percentile,
+			Value:      percentileSums[percentile.Percentile] / count,
+		})
+	}
+	return aggregated
+}
+
+// aggregateIntegrity 加總每次測量的訊息驗證 (都沒有開啟驗證時為 nil)
+func aggregateIntegrity(samples []*Result) *IntegrityStats {
+	var total *IntegrityStats
+	for _, sample := range samples {
+		if sample.Integrity == nil {
+			continue
+		}
+		if total == nil {
+			total = &IntegrityStats{}
+		}
+		total.Received += sample.Integrity.Received
+		total.Lost += sample.Integrity.Lost
+		total.Duplicated += sample.Integrity.Duplicated
+		total.OutOfOrder += sample.Integrity.OutOfOrder
+		total.Corrupted += sample.Integrity.Corrupted
+	}
+	return total
+}
+
+// newMetricStats 計算平均值、樣本標準差、最小和最大值以及平均值的信賴區間 (t 分配)
+func newMetricStats(name string, values []float64) *MetricStats {
+	stats := &MetricStats{
+		Name: name,
+		Min:  values[0],
+		Max:  values[0],
+	}
+
+	var sum float64
+	for _, value := range values {
+		sum += value
+		stats.Min = math.Min(stats.Min, value)
+		stats.Max = math.Max(stats.Max, value)
+	}
+	stats.Mean = sum / float64(len(values))
+
+	if len(values) > 1 {
+		var squaredSum float64
+		for _, value := range values {
+			squaredSum += (value - stats.Mean) * (value - stats.Mean)
+		}
+		stats.StdDev = math.Sqrt(squaredSum / float64(len(values)-1))
+	}
+
+	margin := tCriticalValue(len(values)-1) * stats.StdDev / math.Sqrt(float64(len(values)))
+	stats.CILow = stats.Mean - margin
+	stats.CIHigh = stats.Mean + margin
+	return stats
+}
+
+// tCriticalValue 取得指定自由度的 t 分配臨界值
+func tCriticalValue(degreesOfFreedom int) float64 {
+	if degreesOfFreedom <= 0 {
+		return 0
+	}
+	if degreesOfFreedom < len(tCriticalValues) {
+		return tCriticalValues[degreesOfFreedom]
+	}
+	return 1.96
+}
+
+// metric 取得指定名稱的指標統計 (不存在時為 nil)
+func (stats *IterationStats) metric(name string) *MetricStats {
+	for _, metric := range stats.Metrics {
+		if metric.Name == name {
+			return metric
+		}
+	}
+	return nil
+}
+
+func durationToMilliseconds(duration time.Duration) float64 {
+	return float64(duration) / float64(time.Millisecond)
+}
+
+func millisecondsToDuration(milliseconds float64) time.Duration {
+	return time.Duration(milliseconds * float64(time.Millisecond))
+}
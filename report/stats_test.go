@@ -0,0 +1,292 @@
+package report
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+const floatTolerance = 1e-9
+
+func TestTCriticalValue(t *testing.T) {
+	testCases := []struct {
+		degreesOfFreedom int
+		want             float64
+	}{
+		{degreesOfFreedom: -1, want: 0},
+		{degreesOfFreedom: 0, want: 0},
+		{degreesOfFreedom: 1, want: 12.706},
+		{degreesOfFreedom: 2, want: 4.303},
+		{degreesOfFreedom: 9, want: 2.262},
+		{degreesOfFreedom: 30, want: 2.042},
+		{degreesOfFreedom: 31, want: 1.96},
+		{degreesOfFreedom: 1000, want: 1.96},
+	}
+
+	for _, testCase := range testCases {
+		if got := tCriticalValue(testCase.degreesOfFreedom); got != testCase.want {
+			t.Errorf("tCriticalValue(%d) = %v，預期為 %v", testCase.degreesOfFreedom, got, testCase.want)
+		}
+	}
+}
+
+func TestNewMetricStats(t *testing.T) {
+	testCases := []struct {
+		name   string
+		values []float64
+		want   MetricStats
+	}{
+		{
+			name:   "single value has no interval",
+			values: []float64{10},
+			want:   MetricStats{Mean: 10, Min: 10, Max: 10, CILow: 10, CIHigh: 10},
+		},
+		{
+			name:   "same values",
+			values: []float64{3, 3, 3},
+			want:   MetricStats{Mean: 3, Min: 3, Max: 3, CILow: 3, CIHigh: 3},
+		},
+		{
+			name:   "two values",
+			values: []float64{1, 3},
+			// 標準差 sqrt(2)，信賴區間 2 ± 12.706 * sqrt(2) / sqrt(2)
+			want: MetricStats{Mean: 2, StdDev: math.Sqrt2, Min: 1, Max: 3, CILow: 2 - 12.706, CIHigh: 2 + 12.706},
+		},
+		{
+			name:   "three values",
+			values: []float64{3, 1, 2},
+			want:   MetricStats{Mean: 2, StdDev: 1, Min: 1, Max: 3, CILow: 2 - 4.303/math.Sqrt(3), CIHigh: 2 + 4.303/math.Sqrt(3)},
+		},
+		{
+			name:   "sample standard deviation",
+			values: []float64{2, 4, 4, 4, 5, 5, 7, 9},
+			want: MetricStats{
+				Mean:   5,
+				StdDev: math.Sqrt(32.0 / 7),
+				Min:    2,
+				Max:    9,
+				CILow:  5 - 2.365*math.Sqrt(32.0/7)/math.Sqrt(8),
+				CIHigh: 5 + 2.365*math.Sqrt(32.0/7)/math.Sqrt(8),
+			},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			got := newMetricStats("metric", testCase.values)
+			if got.Name != "metric" {
+				t.Errorf("名稱為 %q，預期為 %q", got.Name, "metric")
+			}
+			assertMetricStats(t, got, &testCase.want)
+		})
+	}
+}
+
+func TestAggregateIterationsErrors(t *testing.T) {
+	result := func(operation string, size int) *Result {
+		result := NewThroughputResult(operation, 100, size, time.Second)
+		result.TesterKey = "tester"
+		return result
+	}
+
+	testCases := []struct {
+		name       string
+		iterations [][]*Result
+	}{
+		{
+			name: "different result count",
+			iterations: [][]*Result{
+				{result("publish", 64), result("publish", 128)},
+				{result("publish", 64)},
+			},
+		},
+		{
+			name: "different operation",
+			iterations: [][]*Result{
+				{result("publish", 64)},
+				{result("subscribe", 64)},
+			},
+		},
+		{
+			name: "different message size",
+			iterations: [][]*Result{
+				{result("publish", 64)},
+				{result("publish", 128)},
+			},
+		},
+		{
+			name: "different params",
+			iterations: [][]*Result{
+				{result("publish", 64).SetParam("storage", "file")},
+				{result("publish", 64).SetParam("storage", "memory")},
+			},
+		},
+		{
+			name: "different order",
+			iterations: [][]*Result{
+				{result("publish", 64), result("subscribe", 64)},
+				{result("subscribe", 64), result("publish", 64)},
+			},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			if _, err := AggregateIterations(testCase.iterations); err == nil {
+				t.Errorf("應該回傳錯誤")
+			}
+		})
+	}
+}
+
+func TestAggregateIterations(t *testing.T) {
+	latency := func(average, min, max, p99 time.Duration) *LatencyStats {
+		return &LatencyStats{
+			Average:     average,
+			Min:         min,
+			Max:         max,
+			Percentiles: []*LatencyPercentile{{Percentile: 99, Value: p99}},
+		}
+	}
+	newResult := func(elapsedTime time.Duration, storedMsgs float64, latencyStats *LatencyStats) *Result {
+		result := NewLatencyResult("publish", 1000, 64, elapsedTime, latencyStats)
+		result.TesterKey = "tester"
+		result.SetParam("storage", "file")
+		result.SetMetric("stored_msgs", storedMsgs)
+		return result
+	}
+
+	// Metrics 不參與情境比對，數值不同也可以合併
+	first := newResult(1*time.Second, 1000, latency(2*time.Millisecond, 1*time.Millisecond, 5*time.Millisecond, 4*time.Millisecond))
+	second := newResult(2*time.Second, 900, latency(4*time.Millisecond, 500*time.Microsecond, 8*time.Millisecond, 6*time.Millisecond))
+	second.SetIntegrity(&IntegrityStats{Received: 900, Lost: 100})
+	third := newResult(3*time.Second, 800, latency(6*time.Millisecond, 2*time.Millisecond, 7*time.Millisecond, 8*time.Millisecond))
+	third.SetIntegrity(&IntegrityStats{Received: 1000, Duplicated: 2})
+
+	t.Run("empty", func(t *testing.T) {
+		results, err := AggregateIterations(nil)
+		if err != nil || results != nil {
+			t.Errorf("結果為 (%v, %v)，預期為 (nil, nil)", results, err)
+		}
+	})
+
+	t.Run("single iteration is returned as is", func(t *testing.T) {
+		results, err := AggregateIterations([][]*Result{{first}})
+		if err != nil {
+			t.Fatalf("合併失敗: %+v", err)
+		}
+		if len(results) != 1 || results[0] != first || results[0].Stats != nil {
+			t.Errorf("只有一次測量時應該直接回傳原本的結果")
+		}
+	})
+
+	results, err := AggregateIterations([][]*Result{{first}, {second}, {third}})
+	if err != nil {
+		t.Fatalf("合併失敗: %+v", err)
+	}
+	if len(results) != 1 {
+		t.Fatalf("結果有 %d 筆，預期為 1 筆", len(results))
+	}
+	aggregated := results[0]
+
+	if aggregated.Stats == nil || aggregated.Stats.Iterations != 3 || aggregated.Stats.Confidence != ConfidenceLevel {
+		t.Fatalf("統計為 %+v，預期為 3 次測量、信賴水準 %v", aggregated.Stats, ConfidenceLevel)
+	}
+
+	// 1s, 2s, 3s：平均 2s，樣本標準差 1s，信賴區間 2 ± 4.303 / sqrt(3) 秒
+	margin := 4.303 / math.Sqrt(3)
+	msgsPerSec := []float64{1000, 500, 1000.0 / 3}
+	msgsPerSecStats := newMetricStats("msgs_per_sec", msgsPerSec)
+	testCases := []struct {
+		name string
+		want MetricStats
+	}{
+		{
+			name: "elapsed_time_ms",
+			want: MetricStats{Mean: 2000, StdDev: 1000, Min: 1000, Max: 3000, CILow: 2000 - 1000*margin, CIHigh: 2000 + 1000*margin},
+		},
+		{name: "msgs_per_sec", want: *msgsPerSecStats},
+		{
+			name: "latency_avg_ms",
+			want: MetricStats{Mean: 4, StdDev: 2, Min: 2, Max: 6, CILow: 4 - 2*margin, CIHigh: 4 + 2*margin},
+		},
+		{
+			name: "latency_max_ms",
+			want: MetricStats{Mean: 20.0 / 3, StdDev: math.Sqrt(7.0 / 3), Min: 5, Max: 8, CILow: 20.0/3 - math.Sqrt(7.0/3)*margin, CIHigh: 20.0/3 + math.Sqrt(7.0/3)*margin},
+		},
+		{
+			name: "latency_p99_ms",
+			want: MetricStats{Mean: 6, StdDev: 2, Min: 4, Max: 8, CILow: 6 - 2*margin, CIHigh: 6 + 2*margin},
+		},
+		{
+			name: "stored_msgs",
+			want: MetricStats{Mean: 900, StdDev: 100, Min: 800, Max: 1000, CILow: 900 - 100*margin, CIHigh: 900 + 100*margin},
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			got := aggregated.Stats.metric(testCase.name)
+			if got == nil {
+				t.Fatalf("缺少 %s 的統計", testCase.name)
+			}
+			assertMetricStats(t, got, &testCase.want)
+		})
+	}
+
+	if aggregated.ElapsedTime != 2*time.Second {
+		t.Errorf("ElapsedTime 為 %v，預期為平均的 2s", aggregated.ElapsedTime)
+	}
+	if math.Abs(aggregated.MsgsPerSec-msgsPerSecStats.Mean) > floatTolerance {
+		t.Errorf("MsgsPerSec 為 %v，預期為平均的 %v", aggregated.MsgsPerSec, msgsPerSecStats.Mean)
+	}
+	if got := aggregated.Metrics["stored_msgs"]; got != 900 {
+		t.Errorf("stored_msgs 為 %v，預期為平均的 900", got)
+	}
+	if aggregated.Params["storage"] != "file" {
+		t.Errorf("情境參數為 %v，預期保留 storage=file", aggregated.Params)
+	}
+
+	if aggregated.Latency.Min != 500*time.Microsecond || aggregated.Latency.Max != 8*time.Millisecond {
+		t.Errorf("延遲的最小和最大值為 %v ~ %v，預期為所有測量中的極值 500µs ~ 8ms", aggregated.Latency.Min, aggregated.Latency.Max)
+	}
+	if aggregated.Latency.Average != 4*time.Millisecond || aggregated.Latency.Percentiles[0].Value != 6*time.Millisecond {
+		t.Errorf("平均延遲為 %v，p99 為 %v，預期為平均的 4ms 和 6ms", aggregated.Latency.Average, aggregated.Latency.Percentiles[0].Value)
+	}
+
+	wantIntegrity := IntegrityStats{Received: 1900, Lost: 100, Duplicated: 2}
+	if aggregated.Integrity == nil || *aggregated.Integrity != wantIntegrity {
+		t.Errorf("訊息驗證為 %+v，預期為加總的 %+v", aggregated.Integrity, wantIntegrity)
+	}
+	if len(aggregated.Failures) != 2 {
+		t.Fatalf("失敗原因為 %v，預期保留第 2 次和第 3 次測量的失敗", aggregated.Failures)
+	}
+	for idx, prefix := range []string{"第 2 次測量: ", "第 3 次測量: "} {
+		if failure := aggregated.Failures[idx]; len(failure) < len(prefix) || failure[:len(prefix)] != prefix {
+			t.Errorf("第 %d 個失敗原因為 %q，預期以 %q 開頭", idx+1, failure, prefix)
+		}
+	}
+	if len(first.Failures) != 0 || first.Params["storage"] != "file" {
+		t.Errorf("合併時不應該修改原本的結果")
+	}
+}
+
+func assertMetricStats(t *testing.T, got, want *MetricStats) {
+	t.Helper()
+
+	fields := []struct {
+		name      string
+		got, want float64
+	}{
+		{name: "Mean", got: got.Mean, want: want.Mean},
+		{name: "StdDev", got: got.StdDev, want: want.StdDev},
+		{name: "Min", got: got.Min, want: want.Min},
+		{name: "Max", got: got.Max, want: want.Max},
+		{name: "CILow", got: got.CILow, want: want.CILow},
+		{name: "CIHigh", got: got.CIHigh, want: want.CIHigh},
+	}
+	for _, field := range fields {
+		if math.Abs(field.got-field.want) > floatTolerance*math.Max(1, math.Abs(field.want)) {
+			t.Errorf("%s 為 %v，預期為 %v", field.name, field.got, field.want)
+		}
+	}
+}
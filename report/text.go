@@ -0,0 +1,175 @@
+package report
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// WriteText 以文字的方式輸出測試結果
+func WriteText(w io.Writer, results []*Result) error {
+	for _, result := range results {
+		if _, err := fmt.Fprintln(w, FormatText(result)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// FormatText 將單一測試結果轉為文字
+func FormatText(result *Result) string {
+	builder := strings.Builder{}
+	builder.WriteString(fmt.Sprintf("[%s] ", result.Operation))
+	if params := formatParams(result.Params); params != "" {
+		builder.WriteString(fmt.Sprintf("(%s) ", params))
+	}
+
+	if result.Latency != nil {
+		builder.WriteString(fmt.Sprintf("全部 %d 筆訊息平均延遲 %v (最大延遲： %v, 最小延遲： %v, 標準差： %v)",
+			result.MessageCount,
+			result.Latency.Average,
+			result.Latency.Max,
+			result.Latency.Min,
+			result.Latency.StdDev,
+		))
+
+		if len(result.Latency.Percentiles) > 0 {
+			percentiles := make([]string, 0, len(result.Latency.Percentiles))
+			for _, percentile := range result.Latency.Percentiles {
+				percentiles = append(percentiles, fmt.Sprintf("%s: %v", PercentileLabel(percentile.Percentile), percentile.Value))
+			}
+			builder.WriteString(fmt.Sprintf("\n    百分位數： %s", strings.Join(percentiles, ", ")))
+		}
+
+		if len(result.Latency.Distribution) > 0 {
+			builder.WriteString("\n")
+			builder.WriteString(FormatDistributionChart(result.Latency.Distribution, 50))
+		}
+	} else {
+		builder.WriteString(fmt.Sprintf("全部 %d 筆花費時間 %v (訊息大小： %v, 每筆平均花費 %v, %.2f msgs/s, %.2f MB/s)",
+			result.MessageCount,
+			result.ElapsedTime,
+			result.MessageSize,
+			result.AverageTime(),
+			result.MsgsPerSec,
+			result.MBPerSec,
+		))
+	}
+
+	if result.Integrity != nil {
+		status := "正常"
+		if !result.Integrity.OK() {
+			status = "異常"
+		}
+		builder.WriteString(fmt.Sprintf("\n    訊息驗證 (%s)： 收到 %d 筆, 遺失 %d 筆, 重複 %d 筆, 亂序 %d 筆, 損毀 %d 筆",
+			status,
+			result.Integrity.Received,
+			result.Integrity.Lost,
+			result.Integrity.Duplicated,
+			result.Integrity.OutOfOrder,
+			result.Integrity.Corrupted,
+		))
+	}
+
+	if len(result.Metrics) > 0 {
+		builder.WriteString(fmt.Sprintf("\n    測量值： %s", formatMetrics(result.Metrics)))
+	}
+
+	for _, failure := range result.Failures {
+		builder.WriteString(fmt.Sprintf("\n    驗證失敗： %s", failure))
+	}
+
+	if result.Stats != nil {
+		builder.WriteString(fmt.Sprintf("\n    重複測量 %d 次 (以上為平均值, %.0f%% 信賴區間)：", result.Stats.Iterations, result.Stats.Confidence*100))
+		for _, metric := range result.Stats.Metrics {
+			builder.WriteString(fmt.Sprintf("\n      %-18s 平均 %.3f, 標準差 %.3f, 最小 %.3f, 最大 %.3f, 信賴區間 [%.3f, %.3f]",
+				metric.Name,
+				metric.Mean,
+				metric.StdDev,
+				metric.Min,
+				metric.Max,
+				metric.CILow,
+				metric.CIHigh,
+			))
+		}
+	}
+	return builder.String()
+}
+
+// WriteSummary 輸出每個 Tester 的成功或失敗
+func WriteSummary(w io.Writer, report *Report) error {
+	builder := strings.Builder{}
+	builder.WriteString("======== 測試摘要 ========\n")
+	for _, testerReport := range report.Testers {
+		status := "成功"
+		if testerReport.Failed() {
+			status = "失敗"
+		}
+		builder.WriteString(fmt.Sprintf("[%s] %s (%s) 花費 %v\n", status, testerReport.Name, testerReport.Key, testerReport.ElapsedTime))
+	}
+
+	failedCount := len(report.FailedTesters())
+	if report.Interrupted {
+		builder.WriteString("測試被中斷，沒有執行剩下的 Tester\n")
+	}
+	builder.WriteString(fmt.Sprintf("共 %d 個 Tester，成功 %d 個，失敗 %d 個\n\n", len(report.Testers), len(report.Testers)-failedCount, failedCount))
+
+	_, err := io.WriteString(w, builder.String())
+	return err
+}
+
+func formatParams(params map[string]string) string {
+	keys := make([]string, 0, len(params))
+	for key := range params {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	pairs := make([]string, 0, len(keys))
+	for _, key := range keys {
+		pairs = append(pairs, fmt.Sprintf("%s: %s", key, params[key]))
+	}
+	return strings.Join(pairs, ", ")
+}
+
+func formatMetrics(metrics map[string]float64) string {
+	keys := make([]string, 0, len(metrics))
+	for key := range metrics {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	pairs := make([]string, 0, len(keys))
+	for _, key := range keys {
+		pairs = append(pairs, fmt.Sprintf("%s: %s", key, strconv.FormatFloat(metrics[key], 'f', -1, 64)))
+	}
+	return strings.Join(pairs, ", ")
+}
+
+// FormatDistributionChart 將延遲分布畫成 ASCII 長條圖
+func FormatDistributionChart(buckets []*LatencyBucket, width int) string {
+	var maxCount int64
+	for _, bucket := range buckets {
+		if bucket.Count > maxCount {
+			maxCount = bucket.Count
+		}
+	}
+
+	lines := make([]string, 0, len(buckets))
+	for _, bucket := range buckets {
+		barLength := 0
+		if maxCount > 0 {
+			barLength = int(bucket.Count * int64(width) / maxCount)
+		}
+		lines = append(lines, fmt.Sprintf("    %12v ~ %-12v | %-*s %d",
+			bucket.From,
+			bucket.To,
+			width,
+			strings.Repeat("#", barLength),
+			bucket.Count,
+		))
+	}
+	return strings.Join(lines, "\n")
+}
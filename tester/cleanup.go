@@ -0,0 +1,84 @@
+package tester
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/nats-io/nats.go"
+	"golang.org/x/xerrors"
+
+	"github.com/marco79423/nats-jetstream-test/config"
+	"github.com/marco79423/nats-jetstream-test/tester/utils"
+)
+
+// cleanupResources 以新的連線刪除測試建立的 Stream (Tester 自己的連線可能已經關閉，Consumer 會隨 Stream 一併刪除)
+func cleanupResources(conf *config.Config, resources *utils.Resources) {
+	streamNames := resources.JetStreamStreams()
+	if len(streamNames) == 0 {
+		return
+	}
+
+	natsConn, err := utils.ConnectNATS(conf, "cleanup")
+	if err != nil {
+		fmt.Printf("清除測試資源失敗: %v\n", err)
+		return
+	}
+	defer natsConn.Close()
+
+	js, err := natsConn.JetStream()
+	if err != nil {
+		fmt.Printf("清除測試資源失敗: %v\n", err)
+		return
+	}
+
+	for _, streamName := range streamNames {
+		if err := js.DeleteStream(streamName); err != nil && err != nats.ErrStreamNotFound {
+			fmt.Printf("刪除 Stream %s 失敗: %v\n", streamName, err)
+			continue
+		}
+		fmt.Printf("已刪除 Stream %s\n", streamName)
+	}
+}
+
+// CleanupLeftovers 刪除名稱以 <prefix>_ 開頭的 Stream (之前的執行被強制結束時留下的資源)，回傳符合的 Stream
+//
+// dryRun 為 true 時只列出不刪除，NATS Streaming 的 Channel 無法由 Client 刪除，需要依賴 Server 的 max_inactivity 設定
+func CleanupLeftovers(conf *config.Config, prefix string, dryRun bool) ([]string, error) {
+	natsConn, err := utils.ConnectNATS(conf, "cleanup")
+	if err != nil {
+		return nil, xerrors.Errorf("取得 NATS 連線失敗: %w", err)
+	}
+	defer natsConn.Close()
+
+	js, err := natsConn.JetStream()
+	if err != nil {
+		return nil, xerrors.Errorf("取得 JetStream 的 Context 失敗: %w", err)
+	}
+
+	var streamNames []string
+	for streamName := range js.StreamNames() {
+		if strings.HasPrefix(streamName, prefix+"_") {
+			streamNames = append(streamNames, streamName)
+		}
+	}
+
+	var failedStreams []string
+	for _, streamName := range streamNames {
+		if dryRun {
+			fmt.Printf("將刪除 Stream %s\n", streamName)
+			continue
+		}
+
+		if err := js.DeleteStream(streamName); err != nil && err != nats.ErrStreamNotFound {
+			fmt.Printf("刪除 Stream %s 失敗: %v\n", streamName, err)
+			failedStreams = append(failedStreams, streamName)
+			continue
+		}
+		fmt.Printf("已刪除 Stream %s\n", streamName)
+	}
+
+	if len(failedStreams) > 0 {
+		return streamNames, xerrors.Errorf("%d 個 Stream 刪除失敗: %s", len(failedStreams), strings.Join(failedStreams, ", "))
+	}
+	return streamNames, nil
+}
@@ -0,0 +1,390 @@
+package tester
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"os"
+	"os/exec"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/stan.go"
+	"golang.org/x/xerrors"
+
+	"github.com/marco79423/nats-jetstream-test/config"
+	"github.com/marco79423/nats-jetstream-test/embedded"
+	"github.com/marco79423/nats-jetstream-test/report"
+	"github.com/marco79423/nats-jetstream-test/tester/utils"
+)
+
+// publishRetryTimeout 發布失敗時持續重試的時間上限 (NATS Streaming 預設等待 Ack 的時間就有 30 秒)
+const publishRetryTimeout = time.Minute
+
+func NewFaultInjectionTester(conf *config.Config, servers *embedded.Servers) ITester {
+	return &faultInjectionTester{
+		conf:    conf,
+		servers: servers,
+	}
+}
+
+type faultInjectionTester struct {
+	conf    *config.Config
+	servers *embedded.Servers // 沒有啟用內嵌 Server 時為 nil
+}
+
+// faultClient 故障測試使用的發布和訂閱連線
+type faultClient struct {
+	Publish func(data []byte) error
+	Close   func()
+}
+
+// faultClientFactory 建立故障測試的連線 (publisher 的斷線和重連需要通知 stats，收到訊息時呼叫 onMessage)
+type faultClientFactory func(ctx context.Context, stats *reconnectStats, onMessage func(data []byte)) (*faultClient, error)
+
+func (tester *faultInjectionTester) Name() string {
+	return "測試 Server 重啟時 JetStream 和 Streaming 的訊息保證"
+}
+
+func (tester *faultInjectionTester) Key() string {
+	return "fault_injection_tester"
+}
+
+func (tester *faultInjectionTester) Test(ctx context.Context) ([]*report.Result, error) {
+	rand.Seed(time.Now().UnixNano())
+
+	testerConfig := tester.conf.Testers.FaultInjectionTester
+	fmt.Printf("Transports: %v, Times: %d, MessageSizes: %v, PublishInterval: %v, Downtime: %v\n",
+		testerConfig.Transports,
+		testerConfig.Times,
+		testerConfig.MessageSizes,
+		testerConfig.PublishInterval,
+		testerConfig.Downtime,
+	)
+
+	var results []*report.Result
+	for _, transport := range testerConfig.Transports {
+		for _, messageSize := range testerConfig.MessageSizes {
+			var transportName string
+			var factory faultClientFactory
+			switch transport {
+			case "jetstream":
+				transportName, factory = "JetStream", tester.newJetStreamClient
+			case "streaming":
+				transportName, factory = "Streaming", tester.newStreamingClient
+			default:
+				return nil, xerrors.Errorf("不支援的 transport %s", transport)
+			}
+
+			// 測試 Streaming 時 NATS Streaming Server 也要一起重啟，否則只會測到 NATS 連線的重連
+			result, err := tester.MeasureFaultInjection(ctx, transportName, messageSize, factory, transport == "streaming")
+			if err != nil {
+				return nil, xerrors.Errorf("測試 %s 在 Server 重啟時的表現失敗: %w", transport, err)
+			}
+			results = append(results, result)
+		}
+	}
+
+	return results, nil
+}
+
+// MeasureFaultInjection 以固定間隔發布訊息，發布到三分之一時重啟 Server，統計發布錯誤、重連時間以及遺失、重複和亂序的訊息
+//
+// 發布失敗時會重試同一筆訊息 (At-Least-Once)，所以 Server 實際收到但 Ack 遺失的訊息會變成重複的訊息
+func (tester *faultInjectionTester) MeasureFaultInjection(ctx context.Context, transport string, messageSize int, factory faultClientFactory, restartStreaming bool) (*report.Result, error) {
+	testerConfig := tester.conf.Testers.FaultInjectionTester
+	messageCount := testerConfig.Times
+	fmt.Printf("\n開始測量 %s 在 Server 重啟時的表現 (次數： %d, 訊息大小：%d)\n", transport, messageCount, messageSize)
+
+	// 一律驗證訊息，才能統計重複和遺失的訊息
+	stats := &reconnectStats{}
+	checker := utils.NewIntegrityChecker(1, 1, messageCount)
+	client, err := factory(ctx, stats, func(data []byte) {
+		checker.Check(data)
+	})
+	if err != nil {
+		return nil, xerrors.Errorf("建立連線失敗: %w", err)
+	}
+	defer client.Close()
+
+	generate := utils.NewMessageGenerator(messageSize, true)
+	restartAt := messageCount / 3
+	restartErr := make(chan error, 1)
+	publishErrors := 0
+
+	// 中斷時也要等 Server 重啟完成，避免之後的測試連不上 Server
+	restarting := false
+	defer func() {
+		if restarting {
+			<-restartErr
+		}
+	}()
+	var maxPublishTime time.Duration
+
+	now := time.Now()
+	for seq := 1; seq <= messageCount; seq++ {
+		if err := ctx.Err(); err != nil {
+			return nil, xerrors.Errorf("發布第 %d 筆訊息失敗: %w", seq, err)
+		}
+
+		if seq == restartAt+1 {
+			restarting = true
+			go func() {
+				restartErr <- tester.restartServer(ctx, restartStreaming)
+			}()
+		}
+
+		publishStartTime := time.Now()
+		errorCount, err := publishWithRetry(ctx, client.Publish, generate(seq))
+		publishErrors += errorCount
+		if err != nil {
+			return nil, xerrors.Errorf("發布第 %d 筆訊息失敗: %w", seq, err)
+		}
+
+		// 記錄單筆訊息發布 (包含重試) 最久卡住的時間
+		if publishTime := time.Since(publishStartTime); publishTime > maxPublishTime {
+			maxPublishTime = publishTime
+		}
+
+		if err := utils.SleepWithContext(ctx, testerConfig.PublishInterval); err != nil {
+			return nil, xerrors.Errorf("發布第 %d 筆訊息失敗: %w", seq, err)
+		}
+	}
+
+	restarting = false
+	if err := <-restartErr; err != nil {
+		return nil, xerrors.Errorf("重啟 Server 失敗: %w", err)
+	}
+
+	// 等待接收剩餘的訊息
+	deadline := time.Now().Add(testerConfig.ReceiveTimeout)
+	for checker.UniqueCount() < messageCount && time.Now().Before(deadline) {
+		if err := utils.SleepWithContext(ctx, 10*time.Millisecond); err != nil {
+			return nil, xerrors.Errorf("等待接收訊息失敗: %w", err)
+		}
+	}
+	elapsedTime := time.Since(now)
+
+	result := report.NewThroughputResult(fmt.Sprintf("%s Fault Injection", transport), messageCount, messageSize, elapsedTime)
+	result.SetParam("downtime", testerConfig.Downtime)
+	result.SetMetric("publish_errors", float64(publishErrors))
+	result.SetMetric("max_publish_time_ms", utils.DurationMetric(maxPublishTime))
+	result.SetMetric("reconnect_time_ms", utils.DurationMetric(stats.ReconnectTime()))
+	// 重試發布會造成重複的訊息，只有遺失或損毀才視為失敗
+	result.SetAtLeastOnceIntegrity(checker.Stats())
+	return result, nil
+}
+
+// restartServer 重啟內嵌的 Server (restartStreaming 時 NATS Streaming Server 也會一起重啟)，沒有啟用內嵌 Server 時改用設定的指令停止和啟動 Server
+//
+// ctx 取消時不再等待 downtime，但仍會啟動 Server，避免之後的測試連不上 Server
+func (tester *faultInjectionTester) restartServer(ctx context.Context, restartStreaming bool) error {
+	testerConfig := tester.conf.Testers.FaultInjectionTester
+
+	if tester.servers != nil && restartStreaming {
+		fmt.Printf("重啟內嵌的 NATS Streaming Server 和 NATS Server (節點: %d, 停止時間: %v)\n", testerConfig.ServerIndex, testerConfig.Downtime)
+		if err := tester.servers.RestartNATSAndStreamingServer(ctx, testerConfig.ServerIndex, testerConfig.Downtime); err != nil {
+			return xerrors.Errorf("重啟內嵌的 NATS Streaming Server 和 NATS Server 失敗: %w", err)
+		}
+		return nil
+	}
+
+	if tester.servers != nil {
+		fmt.Printf("重啟內嵌的 NATS Server (節點: %d, 停止時間: %v)\n", testerConfig.ServerIndex, testerConfig.Downtime)
+		if err := tester.servers.RestartNATSServer(ctx, testerConfig.ServerIndex, testerConfig.Downtime); err != nil {
+			return xerrors.Errorf("重啟內嵌的 NATS Server 失敗: %w", err)
+		}
+		return nil
+	}
+
+	if testerConfig.StopCommand == "" || testerConfig.StartCommand == "" {
+		return xerrors.New("沒有啟用內嵌 Server 時需要設定 stop_command 和 start_command")
+	}
+
+	fmt.Printf("停止 Server (%s)\n", testerConfig.StopCommand)
+	if err := runShellCommand(testerConfig.StopCommand); err != nil {
+		return xerrors.Errorf("停止 Server 失敗: %w", err)
+	}
+
+	// 中斷時直接啟動 Server
+	_ = utils.SleepWithContext(ctx, testerConfig.Downtime)
+
+	fmt.Printf("啟動 Server (%s)\n", testerConfig.StartCommand)
+	if err := runShellCommand(testerConfig.StartCommand); err != nil {
+		return xerrors.Errorf("啟動 Server 失敗: %w", err)
+	}
+	return nil
+}
+
+func (tester *faultInjectionTester) newJetStreamClient(ctx context.Context, stats *reconnectStats, onMessage func(data []byte)) (*faultClient, error) {
+	testerConfig := tester.conf.Testers.FaultInjectionTester
+
+	publisherConn, err := utils.ConnectNATS(tester.conf, tester.Key()+"-publisher", stats.NATSOptions()...)
+	if err != nil {
+		return nil, xerrors.Errorf("取得 NATS 連線失敗: %w", err)
+	}
+
+	subscriberConn, err := utils.ConnectNATS(tester.conf, tester.Key()+"-subscriber")
+	if err != nil {
+		publisherConn.Close()
+		return nil, xerrors.Errorf("取得 NATS 連線失敗: %w", err)
+	}
+
+	closeConns := func() {
+		subscriberConn.Close()
+		publisherConn.Close()
+	}
+
+	publisherJS, err := publisherConn.JetStream()
+	if err != nil {
+		closeConns()
+		return nil, xerrors.Errorf("取得 JetStream 的 Context 失敗: %w", err)
+	}
+
+	subscriberJS, err := subscriberConn.JetStream()
+	if err != nil {
+		closeConns()
+		return nil, xerrors.Errorf("取得 JetStream 的 Context 失敗: %w", err)
+	}
+
+	// 使用 File Storage，Server 重啟後訊息才會保留
+	if _, err := utils.RecreateJetStreamStreamIfExists(ctx, publisherJS, &nats.StreamConfig{
+		Name: testerConfig.Stream,
+		Subjects: []string{
+			testerConfig.Subject,
+		},
+		Storage: nats.FileStorage,
+	}); err != nil {
+		closeConns()
+		return nil, xerrors.Errorf("重建 Stream %s 失敗: %w", testerConfig.Stream, err)
+	}
+
+	// 使用 Durable Consumer，Server 重啟後才能從上次 Ack 的位置繼續
+	if _, err := subscriberJS.Subscribe(testerConfig.Subject, func(msg *nats.Msg) {
+		onMessage(msg.Data)
+		_ = msg.Ack()
+	}, nats.Durable(tester.Key()), nats.ManualAck(), nats.AckExplicit()); err != nil {
+		closeConns()
+		return nil, xerrors.Errorf("訂閱 %s 失敗: %w", testerConfig.Subject, err)
+	}
+
+	return &faultClient{
+		Publish: func(data []byte) error {
+			_, err := publisherJS.Publish(testerConfig.Subject, data)
+			return err
+		},
+		Close: closeConns,
+	}, nil
+}
+
+func (tester *faultInjectionTester) newStreamingClient(ctx context.Context, stats *reconnectStats, onMessage func(data []byte)) (*faultClient, error) {
+	testerConfig := tester.conf.Testers.FaultInjectionTester
+	channel := fmt.Sprintf("%s.%d", testerConfig.Channel, rand.Int())
+	clientID := tester.conf.NATSStreaming.ClientID
+
+	publisherConn, err := utils.ConnectSTANWithClientID(ctx, tester.conf, tester.Key()+"-publisher", clientID+"-fault-publisher", stats.NATSOptions()...)
+	if err != nil {
+		return nil, xerrors.Errorf("取得 STAN 連線失敗: %w", err)
+	}
+
+	subscriberConn, err := utils.ConnectSTANWithClientID(ctx, tester.conf, tester.Key()+"-subscriber", clientID+"-fault-subscriber")
+	if err != nil {
+		_ = publisherConn.Close()
+		return nil, xerrors.Errorf("取得 STAN 連線失敗: %w", err)
+	}
+
+	closeConns := func() {
+		_ = subscriberConn.Close()
+		_ = publisherConn.Close()
+	}
+
+	sub, err := subscriberConn.Subscribe(channel, func(msg *stan.Msg) {
+		onMessage(msg.Data)
+		_ = msg.Ack()
+	}, stan.DurableName(tester.Key()), stan.SetManualAckMode(), stan.DeliverAllAvailable())
+	if err != nil {
+		closeConns()
+		return nil, xerrors.Errorf("訂閱 %s 失敗: %w", channel, err)
+	}
+
+	return &faultClient{
+		Publish: func(data []byte) error {
+			return publisherConn.Publish(channel, data)
+		},
+		Close: func() {
+			// 取消 Durable 訂閱，避免在 Server 上留下 Durable
+			_ = sub.Unsubscribe()
+			closeConns()
+		},
+	}, nil
+}
+
+// publishWithRetry 發布失敗時持續重試 (ctx 取消時停止)，回傳失敗的次數
+func publishWithRetry(ctx context.Context, publish func(data []byte) error, data []byte) (int, error) {
+	errorCount := 0
+	startTime := time.Now()
+	for {
+		err := publish(data)
+		if err == nil {
+			return errorCount, nil
+		}
+
+		errorCount++
+		if time.Since(startTime) > publishRetryTimeout {
+			return errorCount, xerrors.Errorf("重試逾時: %w", err)
+		}
+		if err := utils.SleepWithContext(ctx, 100*time.Millisecond); err != nil {
+			return errorCount, xerrors.Errorf("重試被中斷: %w", err)
+		}
+	}
+}
+
+// runShellCommand 執行停止或啟動 Server 的指令
+func runShellCommand(command string) error {
+	cmd := exec.Command("sh", "-c", command)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return xerrors.Errorf("執行 %s 失敗: %w", command, err)
+	}
+	return nil
+}
+
+// reconnectStats 記錄 Publisher 斷線到重連所花的時間
+type reconnectStats struct {
+	mu             sync.Mutex
+	disconnectedAt time.Time
+	reconnectTime  time.Duration
+}
+
+// NATSOptions 記錄斷線和重連時間的 NATS 設定
+func (stats *reconnectStats) NATSOptions() []nats.Option {
+	return []nats.Option{
+		nats.DisconnectErrHandler(func(conn *nats.Conn, err error) {
+			stats.mu.Lock()
+			defer stats.mu.Unlock()
+
+			fmt.Println("NATS 斷線")
+			stats.disconnectedAt = time.Now()
+		}),
+		nats.ReconnectHandler(func(conn *nats.Conn) {
+			stats.mu.Lock()
+			defer stats.mu.Unlock()
+
+			fmt.Println("NATS 重連成功")
+			if !stats.disconnectedAt.IsZero() {
+				stats.reconnectTime += time.Since(stats.disconnectedAt)
+				stats.disconnectedAt = time.Time{}
+			}
+		}),
+	}
+}
+
+// ReconnectTime 斷線到重連的總時間
+func (stats *reconnectStats) ReconnectTime() time.Duration {
+	stats.mu.Lock()
+	defer stats.mu.Unlock()
+
+	return stats.reconnectTime
+}
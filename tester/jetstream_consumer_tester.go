@@ -0,0 +1,264 @@
+package tester
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"golang.org/x/xerrors"
+
+	"github.com/marco79423/nats-jetstream-test/config"
+	"github.com/marco79423/nats-jetstream-test/report"
+	"github.com/marco79423/nats-jetstream-test/tester/utils"
+)
+
+func NewJetStreamConsumerTester(conf *config.Config) ITester {
+	return &jetStreamConsumerTester{
+		conf: conf,
+	}
+}
+
+type jetStreamConsumerTester struct {
+	conf *config.Config
+}
+
+// consumerSetting Consumer 設定的組合
+type consumerSetting struct {
+	AckPolicy     string
+	DeliverPolicy string
+	MaxAckPending int // 0 代表使用預設值
+	ReplayPolicy  string
+}
+
+func (tester *jetStreamConsumerTester) Name() string {
+	return "測試 JetStream 不同 Consumer 設定的接收效能"
+}
+
+func (tester *jetStreamConsumerTester) Key() string {
+	return "jetstream_consumer_tester"
+}
+
+func (tester *jetStreamConsumerTester) Test(ctx context.Context) ([]*report.Result, error) {
+	natsConn, err := utils.ConnectNATS(tester.conf, tester.Key())
+	if err != nil {
+		return nil, xerrors.Errorf("取得 NATS 連線失敗: %w", err)
+	}
+	defer natsConn.Close()
+
+	// 取得 JetStream 的 Context
+	js, err := natsConn.JetStream()
+	if err != nil {
+		return nil, xerrors.Errorf("取得 JetStream 的 Context 失敗: %w", err)
+	}
+
+	testerConfig := tester.conf.Testers.JetStreamConsumerTester
+	streamName := testerConfig.Stream
+	subject := testerConfig.Subject
+	times := testerConfig.Times
+	messageSizes := testerConfig.MessageSizes
+	fmt.Printf("Stream: %s, Subject: %s, Times: %d, MessageSizes: %v\n", streamName, subject, times, messageSizes)
+	fmt.Printf("AckPolicies: %v, DeliverPolicies: %v, MaxAckPendings: %v, ReplayPolicies: %v\n",
+		testerConfig.AckPolicies,
+		testerConfig.DeliverPolicies,
+		testerConfig.MaxAckPendings,
+		testerConfig.ReplayPolicies,
+	)
+
+	var results []*report.Result
+	for _, messageSize := range messageSizes {
+		for _, setting := range tester.consumerSettings(testerConfig) {
+			result, err := tester.MeasureConsumerTime(ctx, js, &nats.StreamConfig{
+				Name: streamName,
+				Subjects: []string{
+					subject,
+				},
+			}, subject, times, messageSize, setting)
+			if err != nil {
+				return nil, xerrors.Errorf("測試 JetStream Consumer (%+v) 的接收效能失敗: %w", setting, err)
+			}
+			results = append(results, result)
+		}
+	}
+
+	return results, nil
+}
+
+// consumerSettings 列出所有 Consumer 設定的組合
+func (tester *jetStreamConsumerTester) consumerSettings(testerConfig *config.JetStreamConsumerTesterConfig) []*consumerSetting {
+	maxAckPendings := testerConfig.MaxAckPendings
+	if len(maxAckPendings) == 0 {
+		maxAckPendings = []int{0}
+	}
+
+	var settings []*consumerSetting
+	for _, ackPolicy := range testerConfig.AckPolicies {
+		for _, deliverPolicy := range testerConfig.DeliverPolicies {
+			for idx, maxAckPending := range maxAckPendings {
+				// AckNone 不會有等待 Ack 的訊息，MaxAckPending 沒有意義
+				if ackPolicy == "none" {
+					if idx > 0 {
+						break
+					}
+					maxAckPending = 0
+				}
+
+				for _, replayPolicy := range testerConfig.ReplayPolicies {
+					settings = append(settings, &consumerSetting{
+						AckPolicy:     ackPolicy,
+						DeliverPolicy: deliverPolicy,
+						MaxAckPending: maxAckPending,
+						ReplayPolicy:  replayPolicy,
+					})
+				}
+			}
+		}
+	}
+	return settings
+}
+
+// MeasureConsumerTime 測量指定 Consumer 設定的接收效能
+//
+// 除了 new 以外都會先發布 messageCount 筆訊息再訂閱，new 則是訂閱後再發布 messageCount 筆訊息 (計時包含發布時間)，
+// by_start_sequence 和 by_start_time 都從一半的位置開始接收 (by_start_time 以該筆訊息在 Server 上的時間為開始時間，每次測量前會以 streamConfig 重建 Stream)
+func (tester *jetStreamConsumerTester) MeasureConsumerTime(ctx context.Context, js nats.JetStreamContext, streamConfig *nats.StreamConfig, subject string, messageCount, messageSize int, setting *consumerSetting) (*report.Result, error) {
+	fmt.Printf("\n開始測量 JetStream Consumer 的接收效能 (次數： %d, 訊息大小：%d, Ack: %s, Deliver: %s, MaxAckPending: %d, Replay: %s)\n",
+		messageCount,
+		messageSize,
+		setting.AckPolicy,
+		setting.DeliverPolicy,
+		setting.MaxAckPending,
+		setting.ReplayPolicy,
+	)
+
+	// 重建 Stream 測試用 (JetStream 需要顯示管理 Stream)
+	if _, err := utils.RecreateJetStreamStreamIfExists(ctx, js, streamConfig); err != nil {
+		return nil, xerrors.Errorf("重建 Stream %s 失敗: %w", streamConfig.Name, err)
+	}
+
+	ackOpt, err := parseAckPolicy(setting.AckPolicy)
+	if err != nil {
+		return nil, xerrors.Errorf("設定 Consumer 失敗: %w", err)
+	}
+
+	replayOpt, err := parseReplayPolicy(setting.ReplayPolicy)
+	if err != nil {
+		return nil, xerrors.Errorf("設定 Consumer 失敗: %w", err)
+	}
+
+	// 序號連續的訊息 (new 在訂閱後發布的訊息會接在之前的訊息之後)
+	generate := utils.NewMessageGenerator(messageSize, tester.conf.Verify)
+	publishMessages := func(firstSeq, count int) error {
+		return utils.PublishJetStreamMessages(ctx, js, subject, count, func(seq int) []byte {
+			return generate(firstSeq + seq - 1)
+		})
+	}
+
+	// 依照 DeliverPolicy 準備訊息並計算預計收到的序號範圍
+	halfCount := messageCount / 2
+	firstSeq, lastSeq := 1, messageCount
+	publishAfterSubscribe := false
+
+	var deliverOpt nats.SubOpt
+	switch setting.DeliverPolicy {
+	case "all":
+		deliverOpt = nats.DeliverAll()
+	case "last":
+		deliverOpt = nats.DeliverLast()
+		firstSeq = messageCount
+	case "new":
+		deliverOpt = nats.DeliverNew()
+		firstSeq, lastSeq = messageCount+1, messageCount*2
+		publishAfterSubscribe = true
+	case "by_start_sequence":
+		deliverOpt = nats.StartSequence(uint64(halfCount + 1))
+		firstSeq = halfCount + 1
+	case "by_start_time":
+		// 開始時間在發布後才能取得 (使用 Server 儲存訊息的時間，避免 Client 和 Server 的時鐘誤差)
+		firstSeq = halfCount + 1
+	default:
+		return nil, xerrors.Errorf("設定 Consumer 失敗: 不支援的 deliver_policy %s", setting.DeliverPolicy)
+	}
+
+	// 訂閱前的訊息
+	if err := publishMessages(1, messageCount); err != nil {
+		return nil, xerrors.Errorf("發布大量訊息失敗: %w", err)
+	}
+
+	if setting.DeliverPolicy == "by_start_time" {
+		startMsg, err := js.GetMsg(streamConfig.Name, uint64(firstSeq), nats.Context(ctx))
+		if err != nil {
+			return nil, xerrors.Errorf("取得第 %d 筆訊息的時間失敗: %w", firstSeq, err)
+		}
+		deliverOpt = nats.StartTime(startMsg.Time)
+	}
+
+	opts := []nats.SubOpt{nats.ManualAck(), ackOpt, deliverOpt, replayOpt}
+	if setting.MaxAckPending > 0 {
+		opts = append(opts, nats.MaxAckPending(setting.MaxAckPending))
+	}
+
+	expectedCount := lastSeq - firstSeq + 1
+	var checker *utils.IntegrityChecker
+	if tester.conf.Verify {
+		checker = utils.NewIntegrityChecker(1, firstSeq, lastSeq)
+	}
+	receiver := utils.NewMessageReceiverWithChecker(expectedCount, checker)
+
+	now := time.Now()
+	sub, err := js.Subscribe(subject, func(msg *nats.Msg) {
+		if setting.AckPolicy != "none" {
+			_ = msg.Ack()
+		}
+
+		receiver.Receive(msg.Data)
+	}, opts...)
+	if err != nil {
+		return nil, xerrors.Errorf("訂閱 %s 失敗: %w", subject, err)
+	}
+	defer sub.Unsubscribe()
+
+	if publishAfterSubscribe {
+		if err := publishMessages(messageCount+1, messageCount); err != nil {
+			return nil, xerrors.Errorf("發布大量訊息失敗: %w", err)
+		}
+	}
+
+	if err := receiver.Wait(ctx); err != nil {
+		return nil, xerrors.Errorf("等待接收訊息失敗: %w", err)
+	}
+	// 沒有收到全部訊息時不計入最後等待的時間
+	elapsedTime := time.Since(now) - receiver.IdleTime()
+
+	result := report.NewThroughputResult("JetStream Consumer", expectedCount, messageSize, elapsedTime)
+	result.SetParam("ack_policy", setting.AckPolicy)
+	result.SetParam("deliver_policy", setting.DeliverPolicy)
+	result.SetParam("max_ack_pending", setting.MaxAckPending)
+	result.SetParam("replay_policy", setting.ReplayPolicy)
+	result.SetIntegrity(receiver.Integrity())
+	return result, nil
+}
+
+func parseAckPolicy(ackPolicy string) (nats.SubOpt, error) {
+	switch ackPolicy {
+	case "none":
+		return nats.AckNone(), nil
+	case "all":
+		return nats.AckAll(), nil
+	case "explicit":
+		return nats.AckExplicit(), nil
+	default:
+		return nil, xerrors.Errorf("不支援的 ack_policy %s", ackPolicy)
+	}
+}
+
+func parseReplayPolicy(replayPolicy string) (nats.SubOpt, error) {
+	switch replayPolicy {
+	case "instant":
+		return nats.ReplayInstant(), nil
+	case "original":
+		return nats.ReplayOriginal(), nil
+	default:
+		return nil, xerrors.Errorf("不支援的 replay_policy %s", replayPolicy)
+	}
+}
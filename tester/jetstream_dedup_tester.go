@@ -0,0 +1,247 @@
+package tester
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"golang.org/x/xerrors"
+
+	"github.com/marco79423/nats-jetstream-test/config"
+	"github.com/marco79423/nats-jetstream-test/report"
+	"github.com/marco79423/nats-jetstream-test/tester/utils"
+)
+
+// dedupRecentIDCount 重複發布時最多從最近幾筆的 Msg-Id 中挑選
+const dedupRecentIDCount = 100
+
+func NewJetStreamDedupTester(conf *config.Config) ITester {
+	return &jetStreamDedupTester{
+		conf: conf,
+	}
+}
+
+type jetStreamDedupTester struct {
+	conf *config.Config
+}
+
+func (tester *jetStreamDedupTester) Name() string {
+	return "測試 JetStream 使用 Nats-Msg-Id 去除重複訊息的效能和正確性"
+}
+
+func (tester *jetStreamDedupTester) Key() string {
+	return "jetstream_dedup_tester"
+}
+
+func (tester *jetStreamDedupTester) Test(ctx context.Context) ([]*report.Result, error) {
+	natsConn, err := utils.ConnectNATS(tester.conf, tester.Key())
+	if err != nil {
+		return nil, xerrors.Errorf("取得 NATS 連線失敗: %w", err)
+	}
+	defer natsConn.Close()
+
+	// 取得 JetStream 的 Context
+	js, err := natsConn.JetStream()
+	if err != nil {
+		return nil, xerrors.Errorf("取得 JetStream 的 Context 失敗: %w", err)
+	}
+
+	rand.Seed(time.Now().UnixNano())
+	testerConfig := tester.conf.Testers.JetStreamDedupTester
+	times := testerConfig.Times
+	messageSizes := testerConfig.MessageSizes
+	fmt.Printf("Stream: %s, Subject: %s, Times: %d, MessageSizes: %v, DuplicateRatios: %v, DuplicateWindow: %v\n",
+		testerConfig.Stream,
+		testerConfig.Subject,
+		times,
+		messageSizes,
+		testerConfig.DuplicateRatios,
+		testerConfig.DuplicateWindow,
+	)
+
+	var results []*report.Result
+	for _, messageSize := range messageSizes {
+		// 不帶 Msg-Id 的發布效能 (比較用)
+		if _, err := tester.recreateStream(ctx, js); err != nil {
+			return nil, xerrors.Errorf("測試 JetStream 的發布效能失敗: %w", err)
+		}
+		baselineResult, err := utils.MeasureJetStreamPublishMsgTime(ctx, js, testerConfig.Subject, times, messageSize)
+		if err != nil {
+			return nil, xerrors.Errorf("測試 JetStream 的發布效能失敗: %w", err)
+		}
+		results = append(results, baselineResult)
+
+		for _, duplicateRatio := range testerConfig.DuplicateRatios {
+			streamInfo, err := tester.recreateStream(ctx, js)
+			if err != nil {
+				return nil, xerrors.Errorf("測試 JetStream 去除重複訊息失敗: %w", err)
+			}
+
+			result, err := tester.MeasureDedupPublishTime(ctx, js, streamInfo.Config.Duplicates, times, messageSize, duplicateRatio)
+			if err != nil {
+				return nil, xerrors.Errorf("測試 JetStream 去除重複訊息失敗: %w", err)
+			}
+			results = append(results, result)
+		}
+
+		if testerConfig.CheckWindowExpiry {
+			streamInfo, err := tester.recreateStream(ctx, js)
+			if err != nil {
+				return nil, xerrors.Errorf("測試 Duplicates Window 失敗: %w", err)
+			}
+
+			result, err := tester.MeasureWindowExpiry(ctx, js, streamInfo.Config.Duplicates, messageSize)
+			if err != nil {
+				return nil, xerrors.Errorf("測試 Duplicates Window 失敗: %w", err)
+			}
+			results = append(results, result)
+		}
+	}
+
+	return results, nil
+}
+
+// MeasureDedupPublishTime 以 Nats-Msg-Id 發布 messageCount 筆訊息，其中 duplicateRatio 比例的訊息會重複使用最近的 Msg-Id
+//
+// duplicateWindow 為 Server 實際使用的 Window，重複的 Msg-Id 只會從第一次發布在 Window 一半以內的 Msg-Id 挑選 (發布較慢或訊息較大時也不會超過 Window)，
+// 結果會比對 Server 回報為重複的數量和 Stream 實際保存的訊息數量是否符合預期
+func (tester *jetStreamDedupTester) MeasureDedupPublishTime(ctx context.Context, js nats.JetStreamContext, duplicateWindow time.Duration, messageCount, messageSize int, duplicateRatio float64) (*report.Result, error) {
+	testerConfig := tester.conf.Testers.JetStreamDedupTester
+	subject := testerConfig.Subject
+	fmt.Printf("開始測量 JetStream 去除重複訊息的發布效能 (次數： %d, 訊息大小：%d, 重複比例：%v)\n", messageCount, messageSize, duplicateRatio)
+
+	message := []byte(utils.GenerateRandomString(messageSize))
+	var msgIDs []string
+	var publishedAt []time.Time // 每個 Msg-Id 第一次發布的時間
+	expectedDuplicates := 0
+	reportedDuplicates := 0
+
+	now := time.Now()
+	for i := 0; i < messageCount; i++ {
+		if err := ctx.Err(); err != nil {
+			return nil, xerrors.Errorf("發布 %s 失敗: %w", subject, err)
+		}
+
+		// 依比例決定要重複發布之前的 Msg-Id 還是使用新的 Msg-Id
+		msgID := fmt.Sprintf("%s-%d", subject, len(msgIDs)+1)
+		recentCount := recentMsgIDCount(publishedAt, duplicateWindow/2)
+		isDuplicate := recentCount > 0 && float64(expectedDuplicates) < float64(i+1)*duplicateRatio
+		if isDuplicate {
+			msgID = msgIDs[len(msgIDs)-1-rand.Intn(recentCount)]
+			expectedDuplicates++
+		} else {
+			msgIDs = append(msgIDs, msgID)
+			publishedAt = append(publishedAt, time.Now())
+		}
+
+		pubAck, err := js.Publish(subject, message, nats.MsgId(msgID))
+		if err != nil {
+			return nil, xerrors.Errorf("發布 %s 失敗: %w", subject, err)
+		}
+		if pubAck.Duplicate {
+			reportedDuplicates++
+		}
+	}
+	elapsedTime := time.Since(now)
+
+	streamInfo, err := js.StreamInfo(testerConfig.Stream)
+	if err != nil {
+		return nil, xerrors.Errorf("取得 Stream %s 的資訊失敗: %w", testerConfig.Stream, err)
+	}
+	storedMessages := int(streamInfo.State.Msgs)
+
+	result := report.NewThroughputResult("JetStream Dedup Publish", messageCount, messageSize, elapsedTime)
+	result.SetParam("duplicate_ratio", duplicateRatio)
+	result.SetParam("duplicate_window", testerConfig.DuplicateWindow)
+	result.SetMetric("expected_duplicates", float64(expectedDuplicates))
+	result.SetMetric("reported_duplicates", float64(reportedDuplicates))
+	result.SetMetric("expected_messages", float64(len(msgIDs)))
+	result.SetMetric("stored_messages", float64(storedMessages))
+	if reportedDuplicates != expectedDuplicates || storedMessages != len(msgIDs) {
+		result.AddFailure("去除重複訊息的結果不符合預期 (預期重複： %d, 回報重複： %d, 預期保存： %d, 實際保存： %d)",
+			expectedDuplicates,
+			reportedDuplicates,
+			len(msgIDs),
+			storedMessages,
+		)
+	}
+	return result, nil
+}
+
+// recentMsgIDCount 最近幾筆 Msg-Id 的第一次發布時間在 maxAge 以內 (最多 dedupRecentIDCount 筆)
+func recentMsgIDCount(publishedAt []time.Time, maxAge time.Duration) int {
+	count := 0
+	for idx := len(publishedAt) - 1; idx >= 0 && count < dedupRecentIDCount; idx-- {
+		if time.Since(publishedAt[idx]) >= maxAge {
+			break
+		}
+		count++
+	}
+	return count
+}
+
+// MeasureWindowExpiry 確認超過 Duplicates Window 後相同的 Msg-Id 不再被視為重複 (需要等待 Window 的時間)
+//
+// duplicateWindow 為 Server 實際使用的 Window (設定為 0 時 Server 會使用預設的 2 分鐘)
+func (tester *jetStreamDedupTester) MeasureWindowExpiry(ctx context.Context, js nats.JetStreamContext, duplicateWindow time.Duration, messageSize int) (*report.Result, error) {
+	testerConfig := tester.conf.Testers.JetStreamDedupTester
+	subject := testerConfig.Subject
+	fmt.Printf("開始確認 JetStream 的 Duplicates Window (Window： %v, 訊息大小：%d)\n", duplicateWindow, messageSize)
+
+	message := []byte(utils.GenerateRandomString(messageSize))
+	msgID := fmt.Sprintf("%s-window-expiry", subject)
+
+	now := time.Now()
+	if _, err := js.Publish(subject, message, nats.MsgId(msgID)); err != nil {
+		return nil, xerrors.Errorf("發布 %s 失敗: %w", subject, err)
+	}
+
+	// Window 內重複發布應該被視為重複
+	pubAck, err := js.Publish(subject, message, nats.MsgId(msgID))
+	if err != nil {
+		return nil, xerrors.Errorf("發布 %s 失敗: %w", subject, err)
+	}
+	duplicateWithinWindow := pubAck.Duplicate
+
+	// 多等一秒，確保 Server 已清掉過期的 Msg-Id
+	if err := utils.SleepWithContext(ctx, duplicateWindow+time.Second); err != nil {
+		return nil, xerrors.Errorf("等待 Duplicates Window 過期失敗: %w", err)
+	}
+
+	pubAck, err = js.Publish(subject, message, nats.MsgId(msgID))
+	if err != nil {
+		return nil, xerrors.Errorf("發布 %s 失敗: %w", subject, err)
+	}
+	duplicateAfterWindow := pubAck.Duplicate
+	elapsedTime := time.Since(now)
+
+	result := report.NewThroughputResult("JetStream Dedup Window Expiry", 3, messageSize, elapsedTime)
+	result.SetParam("duplicate_window", testerConfig.DuplicateWindow)
+	result.SetMetric("effective_duplicate_window_ms", utils.DurationMetric(duplicateWindow))
+	result.SetMetric("duplicate_within_window", utils.BoolMetric(duplicateWithinWindow))
+	result.SetMetric("duplicate_after_window", utils.BoolMetric(duplicateAfterWindow))
+	if !duplicateWithinWindow || duplicateAfterWindow {
+		result.AddFailure("Duplicates Window 的結果不符合預期 (Window 內重複： %v, Window 後重複： %v)", duplicateWithinWindow, duplicateAfterWindow)
+	}
+	return result, nil
+}
+
+// recreateStream 重建設定 Duplicates Window 的 Stream (回傳的 Stream 資訊包含 Server 實際使用的 Window)
+func (tester *jetStreamDedupTester) recreateStream(ctx context.Context, js nats.JetStreamContext) (*nats.StreamInfo, error) {
+	testerConfig := tester.conf.Testers.JetStreamDedupTester
+
+	// 重建 Stream 測試用 (JetStream 需要顯示管理 Stream)
+	streamInfo, err := utils.RecreateJetStreamStreamIfExists(ctx, js, &nats.StreamConfig{
+		Name: testerConfig.Stream,
+		Subjects: []string{
+			testerConfig.Subject,
+		},
+		Duplicates: testerConfig.DuplicateWindow,
+	})
+	if err != nil {
+		return nil, xerrors.Errorf("重建 Stream %s 失敗: %w", testerConfig.Stream, err)
+	}
+	return streamInfo, nil
+}
@@ -0,0 +1,75 @@
+package tester
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/marco79423/nats-jetstream-test/config"
+	"github.com/marco79423/nats-jetstream-test/report"
+	"github.com/marco79423/nats-jetstream-test/tester/utils"
+	"github.com/nats-io/nats.go"
+	"golang.org/x/xerrors"
+)
+
+func NewJetStreamLoadTester(conf *config.Config) ITester {
+	return &jetStreamLoadTester{
+		conf: conf,
+	}
+}
+
+type jetStreamLoadTester struct {
+	conf *config.Config
+}
+
+func (tester *jetStreamLoadTester) Name() string {
+	return "測試 JetStream 多發布者和多訂閱者的壓測效能"
+}
+
+func (tester *jetStreamLoadTester) Key() string {
+	return "jetstream_load_tester"
+}
+
+func (tester *jetStreamLoadTester) Test(ctx context.Context) ([]*report.Result, error) {
+	natsConn, err := utils.ConnectNATS(tester.conf, tester.Key())
+	if err != nil {
+		return nil, xerrors.Errorf("取得 NATS 連線失敗: %w", err)
+	}
+	defer natsConn.Close()
+
+	// 取得 JetStream 的 Context
+	js, err := natsConn.JetStream()
+	if err != nil {
+		return nil, xerrors.Errorf("取得 JetStream 的 Context 失敗: %w", err)
+	}
+
+	streamName := tester.conf.Testers.JetStreamLoadTester.Stream
+	subject := tester.conf.Testers.JetStreamLoadTester.Subject
+	publishers := tester.conf.Testers.JetStreamLoadTester.Publishers
+	subscribers := tester.conf.Testers.JetStreamLoadTester.Subscribers
+	times := tester.conf.Testers.JetStreamLoadTester.Times
+	messageSizes := tester.conf.Testers.JetStreamLoadTester.MessageSizes
+	fmt.Printf("Stream: %s, Subject: %s, Publishers: %d, Subscribers: %d, Times: %d, MessageSizes: %v\n", streamName, subject, publishers, subscribers, times, messageSizes)
+
+	var results []*report.Result
+	for _, messageSize := range messageSizes {
+		// 重建 Stream 測試用 (JetStream 需要顯示管理 Stream)
+		if _, err := utils.RecreateJetStreamStreamIfExists(ctx, js, &nats.StreamConfig{
+			Name: streamName,
+			Subjects: []string{
+				subject,
+			},
+		}); err != nil {
+			return nil, xerrors.Errorf("重建 Stream %s 失敗: %w", streamName, err)
+		}
+
+		// 測量 JetStream 壓測效能
+		factory := utils.NewJetStreamLoadClientFactory(tester.conf, tester.Key(), subject)
+		loadResults, err := utils.MeasureLoad(ctx, factory, publishers, subscribers, times, messageSize, tester.conf.Verify)
+		if err != nil {
+			return nil, xerrors.Errorf("測試 JetStream 的壓測效能失敗: %w", err)
+		}
+		results = append(results, loadResults...)
+	}
+
+	return results, nil
+}
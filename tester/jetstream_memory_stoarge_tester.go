@@ -1,12 +1,14 @@
 package tester
 
 import (
+	"context"
 	"fmt"
 
 	"github.com/nats-io/nats.go"
 	"golang.org/x/xerrors"
 
 	"github.com/marco79423/nats-jetstream-test/config"
+	"github.com/marco79423/nats-jetstream-test/report"
 	"github.com/marco79423/nats-jetstream-test/tester/utils"
 )
 
@@ -28,17 +30,17 @@ func (tester *jetStreamMemoryStorageTester) Key() string {
 	return "jetstream_memory_storage_tester"
 }
 
-func (tester *jetStreamMemoryStorageTester) Test() error {
+func (tester *jetStreamMemoryStorageTester) Test(ctx context.Context) ([]*report.Result, error) {
 	natsConn, err := utils.ConnectNATS(tester.conf, tester.Key())
 	if err != nil {
-		return xerrors.Errorf("取得 NATS 連線失敗: %w", err)
+		return nil, xerrors.Errorf("取得 NATS 連線失敗: %w", err)
 	}
 	defer natsConn.Close()
 
 	// 取得 JetStream 的 Context
 	js, err := natsConn.JetStream()
 	if err != nil {
-		return xerrors.Errorf("取得 JetStream 的 Context 失敗: %w", err)
+		return nil, xerrors.Errorf("取得 JetStream 的 Context 失敗: %w", err)
 	}
 
 	// JetStream 需要顯示管理 Stream
@@ -48,123 +50,60 @@ func (tester *jetStreamMemoryStorageTester) Test() error {
 	messageSizes := tester.conf.Testers.JetStreamMemoryStorageTester.MessageSizes
 	fmt.Printf("Stream: %s, Subject: %s, Times: %d, MessageSize: %d\n", streamName, subject, times, messageSizes)
 
+	var results []*report.Result
 	for _, messageSize := range messageSizes {
-		if err := tester.TestJetStreamMemoryStoragePerformance(js, streamName, subject, times, messageSize); err != nil {
-			return xerrors.Errorf("測試 JetStream MemoryStorage 的效能: %w", err)
+		memoryStorageResults, err := tester.MeasurePublishAndSubscribePerformance(ctx, js, nats.MemoryStorage, streamName, subject, times, messageSize)
+		if err != nil {
+			return nil, xerrors.Errorf("測試 JetStream MemoryStorage 的效能: %w", err)
 		}
+		results = append(results, memoryStorageResults...)
 
-		if err := tester.TestJetStreamFileStoragePerformance(js, streamName, subject, times, messageSize); err != nil {
-			return xerrors.Errorf("測試 JetStream FileStorage 的效能: %w", err)
+		fileStorageResults, err := tester.MeasurePublishAndSubscribePerformance(ctx, js, nats.FileStorage, streamName, subject, times, messageSize)
+		if err != nil {
+			return nil, xerrors.Errorf("測試 JetStream FileStorage 的效能: %w", err)
 		}
+		results = append(results, fileStorageResults...)
 	}
 
-	return nil
+	return results, nil
 }
 
-func (tester *jetStreamMemoryStorageTester) TestJetStreamFileStoragePerformance(js nats.JetStreamContext, streamName, subject string, times, messageSize int) error {
-	fmt.Println("\n開始測試 JetStream FileStorage 的效能")
+func (tester *jetStreamMemoryStorageTester) MeasurePublishAndSubscribePerformance(ctx context.Context, js nats.JetStreamContext, storage nats.StorageType, streamName, subject string, times, messageSize int) ([]*report.Result, error) {
+	fmt.Printf("\n開始測試 JetStream %sStorage 的效能\n", storage)
 
-	if _, err := utils.RecreateJetStreamStreamIfExists(js, &nats.StreamConfig{
-		Name: streamName,
-		Subjects: []string{
-			subject,
-		},
-		Storage: nats.MemoryStorage,
-	}); err != nil {
-		return xerrors.Errorf("建立 Stream %s 失敗: %w", streamName, err)
-	}
-
-	// 測量 JetStream 發布效能
-	if err := utils.MeasureJetStreamPublishMsgTime(js, subject, times, messageSize); err != nil {
-		return xerrors.Errorf("測試 JetStream 的發布效能失敗: %w", err)
-	}
-
-	if _, err := utils.RecreateJetStreamStreamIfExists(js, &nats.StreamConfig{
-		Name: streamName,
-		Subjects: []string{
-			subject,
-		},
-		Storage: nats.FileStorage, // 預設
-	}); err != nil {
-		return xerrors.Errorf("建立 Stream %s 失敗: %w", streamName, err)
-	}
-
-	// 測量 JetStream 訂閱效能 (Subscribe)
-	if err := utils.MeasureJetStreamSubscribeTime(js, subject, times, messageSize); err != nil {
-		return xerrors.Errorf("測試 JetStream 的接收效能失敗: %w", err)
-	}
-
-	return nil
-}
-
-func (tester *jetStreamMemoryStorageTester) TestJetStreamMemoryStoragePerformance(js nats.JetStreamContext, streamName, subject string, times, messageSize int) error {
-	fmt.Println("\n開始測試 JetStream MemoryStorage 的效能")
-
-	if _, err := utils.RecreateJetStreamStreamIfExists(js, &nats.StreamConfig{
-		Name: streamName,
-		Subjects: []string{
-			subject,
-		},
-		Storage: nats.MemoryStorage,
-	}); err != nil {
-		return xerrors.Errorf("建立 Stream %s 失敗: %w", streamName, err)
-	}
-
-	// 測量 JetStream 發布效能
-	if err := utils.MeasureJetStreamPublishMsgTime(js, subject, times, messageSize); err != nil {
-		return xerrors.Errorf("測試 JetStream 的發布效能失敗: %w", err)
-	}
-
-	if _, err := utils.RecreateJetStreamStreamIfExists(js, &nats.StreamConfig{
-		Name: streamName,
-		Subjects: []string{
-			subject,
-		},
-		Storage: nats.MemoryStorage,
-	}); err != nil {
-		return xerrors.Errorf("建立 Stream %s 失敗: %w", streamName, err)
-	}
-
-	// 測量 JetStream 訂閱效能 (Subscribe)
-	if err := utils.MeasureJetStreamSubscribeTime(js, subject, times, messageSize); err != nil {
-		return xerrors.Errorf("測試 JetStream 的接收效能失敗: %w", err)
-	}
-
-	return nil
-}
-
-func (tester *jetStreamMemoryStorageTester) MeasurePublishAndSubscribePerformance(js nats.JetStreamContext, storage nats.StorageType, streamName, subject string, times, messageSize int) error {
-	fmt.Println("\n開始測試 JetStream MemoryStorage 的效能")
-
-	if _, err := utils.RecreateJetStreamStreamIfExists(js, &nats.StreamConfig{
+	if _, err := utils.RecreateJetStreamStreamIfExists(ctx, js, &nats.StreamConfig{
 		Name: streamName,
 		Subjects: []string{
 			subject,
 		},
 		Storage: storage,
 	}); err != nil {
-		return xerrors.Errorf("建立 Stream %s 失敗: %w", streamName, err)
+		return nil, xerrors.Errorf("建立 Stream %s 失敗: %w", streamName, err)
 	}
 
 	// 測量 JetStream 發布效能
-	if err := utils.MeasureJetStreamPublishMsgTime(js, subject, times, messageSize); err != nil {
-		return xerrors.Errorf("測試 JetStream 的發布效能失敗: %w", err)
+	publishResult, err := utils.MeasureJetStreamPublishMsgTime(ctx, js, subject, times, messageSize)
+	if err != nil {
+		return nil, xerrors.Errorf("測試 JetStream 的發布效能失敗: %w", err)
 	}
+	publishResult.SetParam("storage", storage)
 
-	if _, err := utils.RecreateJetStreamStreamIfExists(js, &nats.StreamConfig{
+	if _, err := utils.RecreateJetStreamStreamIfExists(ctx, js, &nats.StreamConfig{
 		Name: streamName,
 		Subjects: []string{
 			subject,
 		},
 		Storage: storage,
 	}); err != nil {
-		return xerrors.Errorf("建立 Stream %s 失敗: %w", streamName, err)
+		return nil, xerrors.Errorf("建立 Stream %s 失敗: %w", streamName, err)
 	}
 
 	// 測量 JetStream 訂閱效能 (Subscribe)
-	if err := utils.MeasureJetStreamSubscribeTime(js, subject, times, messageSize); err != nil {
-		return xerrors.Errorf("測試 JetStream 的接收效能失敗: %w", err)
+	subscribeResult, err := utils.MeasureJetStreamSubscribeTime(ctx, js, subject, times, messageSize, tester.conf.Verify)
+	if err != nil {
+		return nil, xerrors.Errorf("測試 JetStream 的接收效能失敗: %w", err)
 	}
+	subscribeResult.SetParam("storage", storage)
 
-	return nil
+	return []*report.Result{publishResult, subscribeResult}, nil
 }
@@ -1,6 +1,7 @@
 package tester
 
 import (
+	"context"
 	"fmt"
 	"time"
 
@@ -8,6 +9,7 @@ import (
 	"golang.org/x/xerrors"
 
 	"github.com/marco79423/nats-jetstream-test/config"
+	"github.com/marco79423/nats-jetstream-test/report"
 	"github.com/marco79423/nats-jetstream-test/tester/utils"
 )
 
@@ -29,17 +31,17 @@ func (tester *jetStreamPurgeStreamTester) Key() string {
 	return "jetstream_purge_stream_tester"
 }
 
-func (tester *jetStreamPurgeStreamTester) Test() error {
+func (tester *jetStreamPurgeStreamTester) Test(ctx context.Context) ([]*report.Result, error) {
 	natsConn, err := utils.ConnectNATS(tester.conf, tester.Key())
 	if err != nil {
-		return xerrors.Errorf("取得 NATS 連線失敗: %w", err)
+		return nil, xerrors.Errorf("取得 NATS 連線失敗: %w", err)
 	}
 	defer natsConn.Close()
 
 	// 取得 JetStream 的 Context
 	js, err := natsConn.JetStream()
 	if err != nil {
-		return xerrors.Errorf("取得 JetStream 的 Context 失敗: %w", err)
+		return nil, xerrors.Errorf("取得 JetStream 的 Context 失敗: %w", err)
 	}
 
 	streamName := tester.conf.Testers.JetStreamPurgeStreamTester.Stream
@@ -48,45 +50,44 @@ func (tester *jetStreamPurgeStreamTester) Test() error {
 	messageSizes := tester.conf.Testers.JetStreamPurgeStreamTester.MessageSizes
 	fmt.Printf("Stream: %s, Subject: %s, Counts: %d, MessageSize: %v\n", streamName, subject, counts, messageSizes)
 
+	var results []*report.Result
 	for _, count := range counts {
 		for _, messageSize := range messageSizes {
-			if err := tester.MeasurePurgeStreamTime(js, streamName, subject, count, messageSize); err != nil {
-				return xerrors.Errorf("測試 Purge Stream 失敗: %w", err)
+			result, err := tester.MeasurePurgeStreamTime(ctx, js, streamName, subject, count, messageSize)
+			if err != nil {
+				return nil, xerrors.Errorf("測試 Purge Stream 失敗: %w", err)
 			}
+			results = append(results, result)
 		}
 	}
 
-	return nil
+	return results, nil
 }
 
-func (tester *jetStreamPurgeStreamTester) MeasurePurgeStreamTime(js nats.JetStreamContext, streamName, subject string, count, messageSize int) error {
+func (tester *jetStreamPurgeStreamTester) MeasurePurgeStreamTime(ctx context.Context, js nats.JetStreamContext, streamName, subject string, count, messageSize int) (*report.Result, error) {
 	fmt.Printf("\n開始測量 JetStream 的 Purge Stream 效能 (次數： %d, 訊息大小：%d)\n", count, messageSize)
 
 	// 重建 Stream
-	if _, err := utils.RecreateJetStreamStreamIfExists(js, &nats.StreamConfig{
+	if _, err := utils.RecreateJetStreamStreamIfExists(ctx, js, &nats.StreamConfig{
 		Name: streamName,
 		Subjects: []string{
 			subject,
 		},
 	}); err != nil {
-		return xerrors.Errorf("測量 JetStream 發布訊息所需的時間失敗: %w", err)
+		return nil, xerrors.Errorf("測量 JetStream 發布訊息所需的時間失敗: %w", err)
 	}
 
 	// 發布足夠的訊息
-	if err := utils.PublishJetStreamMessagesWithSize(js, subject, count, messageSize); err != nil {
-		return xerrors.Errorf("測量 JetStream 發布訊息所需的時間失敗: %w", err)
+	if err := utils.PublishJetStreamMessagesWithSize(ctx, js, subject, count, messageSize); err != nil {
+		return nil, xerrors.Errorf("測量 JetStream 發布訊息所需的時間失敗: %w", err)
 	}
 
 	// 清空資訊
 	now := time.Now()
 	if err := js.PurgeStream(streamName); err != nil {
-		return xerrors.Errorf("Purge Stream 失敗: %w", err)
+		return nil, xerrors.Errorf("Purge Stream 失敗: %w", err)
 	}
 	elapsedTime := time.Since(now)
-	fmt.Printf("清除 %d 筆花費時間 %v (每筆平均花費 %v)\n",
-		count,
-		elapsedTime,
-		elapsedTime/time.Duration(count),
-	)
-	return nil
+
+	return report.NewThroughputResult("JetStream Purge Stream", count, messageSize, elapsedTime), nil
 }
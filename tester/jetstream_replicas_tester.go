@@ -0,0 +1,107 @@
+package tester
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/nats-io/nats.go"
+	"golang.org/x/xerrors"
+
+	"github.com/marco79423/nats-jetstream-test/config"
+	"github.com/marco79423/nats-jetstream-test/report"
+	"github.com/marco79423/nats-jetstream-test/tester/utils"
+)
+
+func NewJetStreamReplicasTester(conf *config.Config) ITester {
+	return &jetStreamReplicasTester{
+		conf: conf,
+	}
+}
+
+type jetStreamReplicasTester struct {
+	conf *config.Config
+}
+
+func (tester *jetStreamReplicasTester) Name() string {
+	return "測試 JetStream 不同副本數 (Replicas) 的 Stream 效能 (需要 JetStream Cluster)"
+}
+
+func (tester *jetStreamReplicasTester) Key() string {
+	return "jetstream_replicas_tester"
+}
+
+func (tester *jetStreamReplicasTester) Test(ctx context.Context) ([]*report.Result, error) {
+	natsConn, err := utils.ConnectNATS(tester.conf, tester.Key())
+	if err != nil {
+		return nil, xerrors.Errorf("取得 NATS 連線失敗: %w", err)
+	}
+	defer natsConn.Close()
+
+	// 取得 JetStream 的 Context
+	js, err := natsConn.JetStream()
+	if err != nil {
+		return nil, xerrors.Errorf("取得 JetStream 的 Context 失敗: %w", err)
+	}
+
+	testerConfig := tester.conf.Testers.JetStreamReplicasTester
+	streamName := testerConfig.Stream
+	subject := testerConfig.Subject
+	times := testerConfig.Times
+	messageSizes := testerConfig.MessageSizes
+	fmt.Printf("Stream: %s, Subject: %s, Times: %d, MessageSizes: %v, Replicas: %v\n", streamName, subject, times, messageSizes, testerConfig.Replicas)
+
+	var results []*report.Result
+	for _, replicas := range testerConfig.Replicas {
+		for _, messageSize := range messageSizes {
+			streamConfig := &nats.StreamConfig{
+				Name: streamName,
+				Subjects: []string{
+					subject,
+				},
+				Replicas: replicas,
+			}
+
+			// 測量發布後等待 Ack 的延遲
+			if err := tester.recreateStream(ctx, js, streamConfig); err != nil {
+				return nil, xerrors.Errorf("測試 R%d 的發布 Ack 延遲失敗: %w", replicas, err)
+			}
+			publishResult, err := utils.MeasureJetStreamPublishAckLatency(ctx, js, subject, times, messageSize, testerConfig.Percentiles, testerConfig.ShowChart)
+			if err != nil {
+				return nil, xerrors.Errorf("測試 R%d 的發布 Ack 延遲失敗: %w", replicas, err)
+			}
+			results = append(results, publishResult.SetParam("replicas", replicas))
+
+			// 測量 Async 發布效能
+			if err := tester.recreateStream(ctx, js, streamConfig); err != nil {
+				return nil, xerrors.Errorf("測試 R%d 的 Async 發布效能失敗: %w", replicas, err)
+			}
+			asyncPublishResult, err := utils.MeasureJetStreamAsyncPublishMsgTime(ctx, js, subject, times, messageSize)
+			if err != nil {
+				return nil, xerrors.Errorf("測試 R%d 的 Async 發布效能失敗: %w", replicas, err)
+			}
+			results = append(results, asyncPublishResult.SetParam("replicas", replicas))
+
+			// 測量訂閱效能
+			if err := tester.recreateStream(ctx, js, streamConfig); err != nil {
+				return nil, xerrors.Errorf("測試 R%d 的接收效能失敗: %w", replicas, err)
+			}
+			subscribeResult, err := utils.MeasureJetStreamSubscribeTime(ctx, js, subject, times, messageSize, tester.conf.Verify)
+			if err != nil {
+				return nil, xerrors.Errorf("測試 R%d 的接收效能失敗: %w", replicas, err)
+			}
+			results = append(results, subscribeResult.SetParam("replicas", replicas))
+		}
+	}
+
+	return results, nil
+}
+
+// recreateStream 重建指定副本數的 Stream (副本數超過節點數時 Server 會回傳錯誤)
+func (tester *jetStreamReplicasTester) recreateStream(ctx context.Context, js nats.JetStreamContext, streamConfig *nats.StreamConfig) error {
+	fmt.Printf("\n重建 Stream %s (Replicas: %d)\n", streamConfig.Name, streamConfig.Replicas)
+
+	if _, err := utils.RecreateJetStreamStreamIfExists(ctx, js, streamConfig); err != nil {
+		return xerrors.Errorf("重建 Stream %s 失敗: %w", streamConfig.Name, err)
+	}
+	return nil
+}
@@ -0,0 +1,190 @@
+package tester
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"golang.org/x/xerrors"
+
+	"github.com/marco79423/nats-jetstream-test/config"
+	"github.com/marco79423/nats-jetstream-test/report"
+	"github.com/marco79423/nats-jetstream-test/tester/utils"
+)
+
+func NewJetStreamRetentionTester(conf *config.Config) ITester {
+	return &jetStreamRetentionTester{
+		conf: conf,
+	}
+}
+
+type jetStreamRetentionTester struct {
+	conf *config.Config
+}
+
+func (tester *jetStreamRetentionTester) Name() string {
+	return "測試 JetStream 不同 Retention 和 Limits 設定的發布效能"
+}
+
+func (tester *jetStreamRetentionTester) Key() string {
+	return "jetstream_retention_tester"
+}
+
+func (tester *jetStreamRetentionTester) Test(ctx context.Context) ([]*report.Result, error) {
+	natsConn, err := utils.ConnectNATS(tester.conf, tester.Key())
+	if err != nil {
+		return nil, xerrors.Errorf("取得 NATS 連線失敗: %w", err)
+	}
+	defer natsConn.Close()
+
+	// 取得 JetStream 的 Context
+	js, err := natsConn.JetStream()
+	if err != nil {
+		return nil, xerrors.Errorf("取得 JetStream 的 Context 失敗: %w", err)
+	}
+
+	testerConfig := tester.conf.Testers.JetStreamRetentionTester
+	fmt.Printf("Stream: %s, Subject: %s, Times: %d, MessageSizes: %v\n", testerConfig.Stream, testerConfig.Subject, testerConfig.Times, testerConfig.MessageSizes)
+	fmt.Printf("Retentions: %v, Discards: %v, MaxMsgs: %d, MaxBytes: %d, MaxAge: %v\n",
+		testerConfig.Retentions,
+		testerConfig.Discards,
+		testerConfig.MaxMsgs,
+		testerConfig.MaxBytes,
+		testerConfig.MaxAge,
+	)
+
+	var results []*report.Result
+	for _, messageSize := range testerConfig.MessageSizes {
+		for _, retention := range testerConfig.Retentions {
+			retentionPolicy, err := parseRetentionPolicy(retention)
+			if err != nil {
+				return nil, xerrors.Errorf("設定 Stream 失敗: %w", err)
+			}
+
+			for _, discard := range testerConfig.Discards {
+				discardPolicy, err := parseDiscardPolicy(discard)
+				if err != nil {
+					return nil, xerrors.Errorf("設定 Stream 失敗: %w", err)
+				}
+
+				result, err := tester.MeasureRetentionPublishTime(ctx, js, &nats.StreamConfig{
+					Name: testerConfig.Stream,
+					Subjects: []string{
+						testerConfig.Subject,
+					},
+					Retention: retentionPolicy,
+					Discard:   discardPolicy,
+					MaxMsgs:   testerConfig.MaxMsgs,
+					MaxBytes:  testerConfig.MaxBytes,
+					MaxAge:    testerConfig.MaxAge,
+				}, testerConfig.Times, messageSize)
+				if err != nil {
+					return nil, xerrors.Errorf("測試 JetStream (Retention: %s, Discard: %s) 的發布效能失敗: %w", retention, discard, err)
+				}
+				results = append(results, result.SetParam("retention", retention).SetParam("discard", discard))
+			}
+		}
+	}
+
+	return results, nil
+}
+
+// MeasureRetentionPublishTime 以指定的 Stream 設定發布 messageCount 筆訊息 (超過限制時不會中斷)，統計發布錯誤和 Stream 實際保存的訊息
+//
+// create_consumer 開啟時會先建立不 Ack 的 Durable Consumer，讓 Interest 和 WorkQueue 的 Stream 保留訊息
+func (tester *jetStreamRetentionTester) MeasureRetentionPublishTime(ctx context.Context, js nats.JetStreamContext, streamConfig *nats.StreamConfig, messageCount, messageSize int) (*report.Result, error) {
+	testerConfig := tester.conf.Testers.JetStreamRetentionTester
+	subject := testerConfig.Subject
+	fmt.Printf("\n開始測量 JetStream 的發布效能 (次數： %d, 訊息大小：%d, Retention: %s, Discard: %s)\n",
+		messageCount,
+		messageSize,
+		streamConfig.Retention,
+		streamConfig.Discard,
+	)
+
+	// 重建 Stream 測試用 (JetStream 需要顯示管理 Stream)
+	if _, err := utils.RecreateJetStreamStreamIfExists(ctx, js, streamConfig); err != nil {
+		return nil, xerrors.Errorf("重建 Stream %s 失敗: %w", streamConfig.Name, err)
+	}
+
+	if testerConfig.CreateConsumer {
+		if _, err := js.AddConsumer(streamConfig.Name, &nats.ConsumerConfig{
+			Durable:   tester.Key(),
+			AckPolicy: nats.AckExplicitPolicy,
+		}); err != nil {
+			return nil, xerrors.Errorf("建立 Consumer 失敗: %w", err)
+		}
+	}
+
+	message := []byte(utils.GenerateRandomString(messageSize))
+	publishErrors := 0
+
+	now := time.Now()
+	for i := 0; i < messageCount; i++ {
+		if err := ctx.Err(); err != nil {
+			return nil, xerrors.Errorf("發布 %s 失敗: %w", subject, err)
+		}
+
+		if _, err := js.Publish(subject, message); err != nil {
+			publishErrors++
+			if publishErrors == 1 {
+				fmt.Printf("第 %d 筆訊息發布失敗: %v\n", i+1, err)
+			}
+		}
+	}
+	elapsedTime := time.Since(now)
+
+	streamInfo, err := js.StreamInfo(streamConfig.Name)
+	if err != nil {
+		return nil, xerrors.Errorf("取得 Stream %s 的資訊失敗: %w", streamConfig.Name, err)
+	}
+
+	result := report.NewThroughputResult("JetStream Retention Publish", messageCount, messageSize, elapsedTime)
+	result.SetParam("max_msgs", streamConfig.MaxMsgs)
+	result.SetParam("max_bytes", streamConfig.MaxBytes)
+	result.SetParam("max_age", streamConfig.MaxAge)
+	result.SetMetric("publish_errors", float64(publishErrors))
+	result.SetMetric("stored_messages", float64(streamInfo.State.Msgs))
+	result.SetMetric("stored_bytes", float64(streamInfo.State.Bytes))
+	result.SetMetric("first_seq", float64(streamInfo.State.FirstSeq))
+
+	// 等訊息超過 MaxAge 後再確認一次保存的訊息 (多等一秒讓 Server 清除過期的訊息)
+	if streamConfig.MaxAge > 0 {
+		if err := utils.SleepWithContext(ctx, streamConfig.MaxAge+time.Second); err != nil {
+			return nil, xerrors.Errorf("等待訊息過期失敗: %w", err)
+		}
+
+		streamInfo, err := js.StreamInfo(streamConfig.Name)
+		if err != nil {
+			return nil, xerrors.Errorf("取得 Stream %s 的資訊失敗: %w", streamConfig.Name, err)
+		}
+		result.SetMetric("stored_messages_after_max_age", float64(streamInfo.State.Msgs))
+	}
+
+	return result, nil
+}
+
+func parseRetentionPolicy(retention string) (nats.RetentionPolicy, error) {
+	switch retention {
+	case "limits":
+		return nats.LimitsPolicy, nil
+	case "interest":
+		return nats.InterestPolicy, nil
+	case "workqueue":
+		return nats.WorkQueuePolicy, nil
+	default:
+		return 0, xerrors.Errorf("不支援的 retention %s", retention)
+	}
+}
+
+func parseDiscardPolicy(discard string) (nats.DiscardPolicy, error) {
+	switch discard {
+	case "old":
+		return nats.DiscardOld, nil
+	case "new":
+		return nats.DiscardNew, nil
+	default:
+		return 0, xerrors.Errorf("不支援的 discard %s", discard)
+	}
+}
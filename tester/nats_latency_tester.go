@@ -0,0 +1,74 @@
+package tester
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/marco79423/nats-jetstream-test/config"
+	"github.com/marco79423/nats-jetstream-test/report"
+	"github.com/marco79423/nats-jetstream-test/tester/utils"
+	"github.com/nats-io/nats.go"
+	"golang.org/x/xerrors"
+)
+
+func NewNATSLatencyTester(conf *config.Config) ITester {
+	return &natsLatencyTester{
+		conf: conf,
+	}
+}
+
+type natsLatencyTester struct {
+	conf *config.Config
+}
+
+func (tester *natsLatencyTester) Name() string {
+	return "測試 NATS 的延遲"
+}
+
+func (tester *natsLatencyTester) Key() string {
+	return "nats_latency_tester"
+}
+
+func (tester *natsLatencyTester) Test(ctx context.Context) ([]*report.Result, error) {
+	natsConn, err := utils.ConnectNATS(tester.conf, tester.Key())
+	if err != nil {
+		return nil, xerrors.Errorf("取得 NATS 連線失敗: %w", err)
+	}
+	defer natsConn.Close()
+
+	subject := tester.conf.Testers.NATSLatencyTester.Subject
+	times := tester.conf.Testers.NATSLatencyTester.Times
+	rates := tester.conf.Testers.NATSLatencyTester.Rates
+	fmt.Printf("Subject: %s, Times: %d, Rates: %v\n", subject, times, rates)
+
+	var results []*report.Result
+	for _, rate := range utils.RatesOrUnlimited(rates) {
+		result, err := utils.MeasureLatency(ctx, "NATS", &utils.LatencyOptions{
+			Times:       times,
+			Rate:        rate,
+			Percentiles: tester.conf.Testers.NATSLatencyTester.Percentiles,
+			ShowChart:   tester.conf.Testers.NATSLatencyTester.ShowChart,
+		}, func(onMessage func(data []byte)) (func(), error) {
+			sub, err := natsConn.Subscribe(subject, func(msg *nats.Msg) {
+				onMessage(msg.Data)
+			})
+			if err != nil {
+				return nil, xerrors.Errorf("訂閱 %s 失敗: %w", subject, err)
+			}
+
+			// 確保 Server 已收到訂閱
+			if err := natsConn.Flush(); err != nil {
+				return nil, xerrors.Errorf("訂閱 %s 失敗: %w", subject, err)
+			}
+			return func() { _ = sub.Unsubscribe() }, nil
+		}, func(data []byte) error {
+			return natsConn.Publish(subject, data)
+		})
+		if err != nil {
+			return nil, xerrors.Errorf("測試 NATS 的延遲失敗: %w", err)
+		}
+		results = append(results, result)
+	}
+
+	return results, nil
+}
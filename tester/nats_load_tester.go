@@ -0,0 +1,51 @@
+package tester
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/marco79423/nats-jetstream-test/config"
+	"github.com/marco79423/nats-jetstream-test/report"
+	"github.com/marco79423/nats-jetstream-test/tester/utils"
+	"golang.org/x/xerrors"
+)
+
+func NewNATSLoadTester(conf *config.Config) ITester {
+	return &natsLoadTester{
+		conf: conf,
+	}
+}
+
+type natsLoadTester struct {
+	conf *config.Config
+}
+
+func (tester *natsLoadTester) Name() string {
+	return "測試 NATS 多發布者和多訂閱者的壓測效能"
+}
+
+func (tester *natsLoadTester) Key() string {
+	return "nats_load_tester"
+}
+
+func (tester *natsLoadTester) Test(ctx context.Context) ([]*report.Result, error) {
+	subject := tester.conf.Testers.NATSLoadTester.Subject
+	publishers := tester.conf.Testers.NATSLoadTester.Publishers
+	subscribers := tester.conf.Testers.NATSLoadTester.Subscribers
+	times := tester.conf.Testers.NATSLoadTester.Times
+	messageSizes := tester.conf.Testers.NATSLoadTester.MessageSizes
+	fmt.Printf("Subject: %s, Publishers: %d, Subscribers: %d, Times: %d, MessageSizes: %v\n", subject, publishers, subscribers, times, messageSizes)
+
+	var results []*report.Result
+	for _, messageSize := range messageSizes {
+		// 測量 NATS 壓測效能
+		factory := utils.NewNATSLoadClientFactory(tester.conf, tester.Key(), subject)
+		loadResults, err := utils.MeasureLoad(ctx, factory, publishers, subscribers, times, messageSize, tester.conf.Verify)
+		if err != nil {
+			return nil, xerrors.Errorf("測試 NATS 的壓測效能失敗: %w", err)
+		}
+		results = append(results, loadResults...)
+	}
+
+	return results, nil
+}
@@ -1,9 +1,11 @@
 package tester
 
 import (
+	"context"
 	"fmt"
 
 	"github.com/marco79423/nats-jetstream-test/config"
+	"github.com/marco79423/nats-jetstream-test/report"
 	"github.com/marco79423/nats-jetstream-test/tester/utils"
 	"golang.org/x/xerrors"
 )
@@ -26,10 +28,10 @@ func (tester *natsPublishTester) Key() string {
 	return "nats_publish_tester"
 }
 
-func (tester *natsPublishTester) Test() error {
+func (tester *natsPublishTester) Test(ctx context.Context) ([]*report.Result, error) {
 	natsConn, err := utils.ConnectNATS(tester.conf, tester.Key())
 	if err != nil {
-		return xerrors.Errorf("取得 NATS 連線失敗: %w", err)
+		return nil, xerrors.Errorf("取得 NATS 連線失敗: %w", err)
 	}
 	defer natsConn.Close()
 
@@ -38,12 +40,15 @@ func (tester *natsPublishTester) Test() error {
 	messageSizes := tester.conf.Testers.NATSPublishTester.MessageSizes
 	fmt.Printf("Subject: %s, Times: %d, MessageSizes: %v\n", subject, times, messageSizes)
 
+	var results []*report.Result
 	for _, messageSize := range messageSizes {
 		// 測量 NATS 發布效能
-		if err := utils.MeasureNATSPublishMsgTime(natsConn, subject, times, messageSize); err != nil {
-			return xerrors.Errorf("測試 NATS 的發布效能失敗: %w", err)
+		result, err := utils.MeasureNATSPublishMsgTime(ctx, natsConn, subject, times, messageSize)
+		if err != nil {
+			return nil, xerrors.Errorf("測試 NATS 的發布效能失敗: %w", err)
 		}
+		results = append(results, result)
 	}
 
-	return nil
+	return results, nil
 }
@@ -0,0 +1,72 @@
+package tester
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/marco79423/nats-jetstream-test/config"
+	"github.com/marco79423/nats-jetstream-test/report"
+	"github.com/marco79423/nats-jetstream-test/tester/utils"
+	"golang.org/x/xerrors"
+)
+
+func NewNATSRequestReplyTester(conf *config.Config) ITester {
+	return &natsRequestReplyTester{
+		conf: conf,
+	}
+}
+
+type natsRequestReplyTester struct {
+	conf *config.Config
+}
+
+func (tester *natsRequestReplyTester) Name() string {
+	return "測試 NATS 的 Request-Reply 效能"
+}
+
+func (tester *natsRequestReplyTester) Key() string {
+	return "nats_request_reply_tester"
+}
+
+func (tester *natsRequestReplyTester) Test(ctx context.Context) ([]*report.Result, error) {
+	natsConn, err := utils.ConnectNATS(tester.conf, tester.Key())
+	if err != nil {
+		return nil, xerrors.Errorf("取得 NATS 連線失敗: %w", err)
+	}
+	defer natsConn.Close()
+
+	subject := tester.conf.Testers.NATSRequestReplyTester.Subject
+	times := tester.conf.Testers.NATSRequestReplyTester.Times
+	messageSizes := tester.conf.Testers.NATSRequestReplyTester.MessageSizes
+	responders := tester.conf.Testers.NATSRequestReplyTester.Responders
+	queueGroup := tester.conf.Testers.NATSRequestReplyTester.QueueGroup
+	fmt.Printf("Subject: %s, Times: %d, MessageSizes: %v, Responders: %d, QueueGroup: %s\n", subject, times, messageSizes, responders, queueGroup)
+
+	// 啟動 Responder
+	stopResponders, err := utils.StartNATSResponders(tester.conf, tester.Key(), subject, queueGroup, responders)
+	if err != nil {
+		return nil, xerrors.Errorf("啟動 Responder 失敗: %w", err)
+	}
+	defer stopResponders()
+
+	var results []*report.Result
+	for _, messageSize := range messageSizes {
+		// 測量 NATS Request-Reply 效能
+		result, err := utils.MeasureNATSRequestReplyTime(ctx,
+			natsConn,
+			subject,
+			times,
+			messageSize,
+			tester.conf.Testers.NATSRequestReplyTester.Percentiles,
+			tester.conf.Testers.NATSRequestReplyTester.ShowChart,
+		)
+		if err != nil {
+			return nil, xerrors.Errorf("測試 NATS 的 Request-Reply 效能失敗: %w", err)
+		}
+		result.SetParam("responders", responders)
+		result.SetParam("queue_group", queueGroup)
+		results = append(results, result)
+	}
+
+	return results, nil
+}
@@ -0,0 +1,54 @@
+package tester
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/marco79423/nats-jetstream-test/config"
+	"github.com/marco79423/nats-jetstream-test/report"
+	"github.com/marco79423/nats-jetstream-test/tester/utils"
+	"golang.org/x/xerrors"
+)
+
+func NewNATSSubscribeTester(conf *config.Config) ITester {
+	return &natsSubscribeTester{
+		conf: conf,
+	}
+}
+
+type natsSubscribeTester struct {
+	conf *config.Config
+}
+
+func (tester *natsSubscribeTester) Name() string {
+	return "測試 NATS 的接收效能"
+}
+
+func (tester *natsSubscribeTester) Key() string {
+	return "nats_subscribe_tester"
+}
+
+func (tester *natsSubscribeTester) Test(ctx context.Context) ([]*report.Result, error) {
+	natsConn, err := utils.ConnectNATS(tester.conf, tester.Key())
+	if err != nil {
+		return nil, xerrors.Errorf("取得 NATS 連線失敗: %w", err)
+	}
+	defer natsConn.Close()
+
+	subject := tester.conf.Testers.NATSSubscribeTester.Subject
+	times := tester.conf.Testers.NATSSubscribeTester.Times
+	messageSizes := tester.conf.Testers.NATSSubscribeTester.MessageSizes
+	fmt.Printf("Subject: %s, Times: %d, MessageSizes: %v\n", subject, times, messageSizes)
+
+	var results []*report.Result
+	for _, messageSize := range messageSizes {
+		// 測量 NATS 訂閱效能
+		result, err := utils.MeasureNATSSubscribeTime(ctx, natsConn, subject, times, messageSize, tester.conf.Verify)
+		if err != nil {
+			return nil, xerrors.Errorf("測試 NATS 的接收效能失敗: %w", err)
+		}
+		results = append(results, result)
+	}
+
+	return results, nil
+}
@@ -0,0 +1,346 @@
+package tester
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"golang.org/x/xerrors"
+
+	"github.com/marco79423/nats-jetstream-test/config"
+	"github.com/marco79423/nats-jetstream-test/report"
+	"github.com/marco79423/nats-jetstream-test/tester/utils"
+)
+
+// NewScenarioTesters 為設定檔中的每個情境建立 Tester
+func NewScenarioTesters(conf *config.Config) []ITester {
+	var testers []ITester
+	for _, scenario := range conf.Scenarios {
+		testers = append(testers, NewScenarioTester(conf, scenario))
+	}
+	return testers
+}
+
+// NewScenarioTester 建立執行指定情境的 Tester
+func NewScenarioTester(conf *config.Config, scenario *config.ScenarioConfig) ITester {
+	return &scenarioTester{
+		conf:     conf,
+		scenario: scenario,
+	}
+}
+
+type scenarioTester struct {
+	conf     *config.Config
+	scenario *config.ScenarioConfig
+}
+
+func (tester *scenarioTester) Name() string {
+	if tester.scenario.Description != "" {
+		return tester.scenario.Description
+	}
+	return fmt.Sprintf("執行情境 %s (%s %s)", tester.scenario.Name, tester.scenario.Transport, tester.scenario.Operation)
+}
+
+func (tester *scenarioTester) Key() string {
+	return tester.scenario.Name
+}
+
+func (tester *scenarioTester) Test(ctx context.Context) ([]*report.Result, error) {
+	scenario := tester.scenario
+	fmt.Printf("Transport: %s, Operation: %s, Subject: %s, Times: %d, MessageSizes: %v\n",
+		scenario.Transport,
+		scenario.Operation,
+		scenario.Subject,
+		scenario.Times,
+		scenario.MessageSizes,
+	)
+
+	switch scenario.Transport {
+	case "jetstream":
+		return tester.testJetStream(ctx)
+	case "streaming":
+		return tester.testStreaming(ctx)
+	case "nats":
+		return tester.testNATS(ctx)
+	default:
+		return nil, xerrors.Errorf("不支援的 transport %s", scenario.Transport)
+	}
+}
+
+// testJetStream 執行 JetStream 的情境 (每次測量前都會重建 Stream)
+func (tester *scenarioTester) testJetStream(ctx context.Context) ([]*report.Result, error) {
+	natsConn, err := utils.ConnectNATS(tester.conf, tester.Key())
+	if err != nil {
+		return nil, xerrors.Errorf("取得 NATS 連線失敗: %w", err)
+	}
+	defer natsConn.Close()
+
+	// 取得 JetStream 的 Context
+	js, err := natsConn.JetStream()
+	if err != nil {
+		return nil, xerrors.Errorf("取得 JetStream 的 Context 失敗: %w", err)
+	}
+
+	scenario := tester.scenario
+	streamConfig, err := tester.streamConfig()
+	if err != nil {
+		return nil, xerrors.Errorf("設定 Stream 失敗: %w", err)
+	}
+	consumerConfig := tester.consumerConfig()
+	fmt.Printf("Stream: %s, Storage: %s, Replicas: %d, Retention: %s, Discard: %s, MaxMsgs: %d, MaxBytes: %d, MaxAge: %v\n",
+		streamConfig.Name,
+		streamConfig.Storage,
+		streamConfig.Replicas,
+		streamConfig.Retention,
+		streamConfig.Discard,
+		streamConfig.MaxMsgs,
+		streamConfig.MaxBytes,
+		streamConfig.MaxAge,
+	)
+
+	var results []*report.Result
+	for _, messageSize := range scenario.MessageSizes {
+		// consume 會自己重建 Stream (部分 DeliverPolicy 需要在重建後分段發布)
+		if scenario.Operation == "consume" {
+			consumerTester := &jetStreamConsumerTester{conf: tester.conf}
+			result, err := consumerTester.MeasureConsumerTime(ctx, js, streamConfig, scenario.Subject, scenario.Times, messageSize, &consumerSetting{
+				AckPolicy:     consumerConfig.AckPolicy,
+				DeliverPolicy: consumerConfig.DeliverPolicy,
+				MaxAckPending: consumerConfig.MaxAckPending,
+				ReplayPolicy:  consumerConfig.ReplayPolicy,
+			})
+			if err != nil {
+				return nil, xerrors.Errorf("執行情境 %s 失敗: %w", scenario.Name, err)
+			}
+			results = append(results, result)
+			continue
+		}
+
+		// 重建 Stream 測試用 (JetStream 需要顯示管理 Stream)
+		if _, err := utils.RecreateJetStreamStreamIfExists(ctx, js, streamConfig); err != nil {
+			return nil, xerrors.Errorf("重建 Stream %s 失敗: %w", streamConfig.Name, err)
+		}
+
+		var result *report.Result
+		switch scenario.Operation {
+		case "publish":
+			result, err = utils.MeasureJetStreamPublishMsgTime(ctx, js, scenario.Subject, scenario.Times, messageSize)
+		case "async_publish":
+			result, err = utils.MeasureJetStreamAsyncPublishMsgTime(ctx, js, scenario.Subject, scenario.Times, messageSize)
+		case "publish_latency":
+			result, err = utils.MeasureJetStreamPublishAckLatency(ctx, js, scenario.Subject, scenario.Times, messageSize, scenario.Percentiles, scenario.ShowChart)
+		case "subscribe":
+			result, err = utils.MeasureJetStreamSubscribeTime(ctx, js, scenario.Subject, scenario.Times, messageSize, tester.conf.Verify)
+		case "chan_subscribe":
+			result, err = utils.MeasureJetStreamChanSubscribeTime(ctx, js, scenario.Subject, scenario.Times, messageSize, tester.conf.Verify)
+		case "pull_subscribe":
+			var opts []nats.SubOpt
+			if consumerConfig.MaxAckPending > 0 {
+				opts = append(opts, nats.MaxAckPending(consumerConfig.MaxAckPending))
+			}
+			result, err = utils.MeasureJetStreamPullSubscribeTime(ctx, js, consumerConfig.Durable, scenario.Subject, scenario.Times, messageSize, consumerConfig.FetchCount, tester.conf.Verify, opts...)
+			if result != nil && consumerConfig.MaxAckPending > 0 {
+				result.SetParam("max_ack_pending", consumerConfig.MaxAckPending)
+			}
+		case "load":
+			factory := utils.NewJetStreamLoadClientFactory(tester.conf, tester.Key(), scenario.Subject)
+			loadResults, err := utils.MeasureLoad(ctx, factory, tester.publishers(), tester.subscribers(), scenario.Times, messageSize, tester.conf.Verify)
+			if err != nil {
+				return nil, xerrors.Errorf("執行情境 %s 失敗: %w", scenario.Name, err)
+			}
+			results = append(results, loadResults...)
+			continue
+		default:
+			return nil, xerrors.Errorf("JetStream 不支援的 operation %s", scenario.Operation)
+		}
+		if err != nil {
+			return nil, xerrors.Errorf("執行情境 %s 失敗: %w", scenario.Name, err)
+		}
+		results = append(results, result)
+	}
+
+	return results, nil
+}
+
+// testStreaming 執行 Streaming 的情境 (subscribe 每次都使用新的 Channel)
+func (tester *scenarioTester) testStreaming(ctx context.Context) ([]*report.Result, error) {
+	// 取得 Streaming 的連線
+	stanConn, err := utils.ConnectSTAN(ctx, tester.conf, tester.Key())
+	if err != nil {
+		return nil, xerrors.Errorf("取得 STAN 連線失敗: %w", err)
+	}
+	defer stanConn.Close()
+
+	rand.Seed(time.Now().UnixNano())
+	scenario := tester.scenario
+
+	var results []*report.Result
+	for _, messageSize := range scenario.MessageSizes {
+		var result *report.Result
+		switch scenario.Operation {
+		case "publish":
+			result, err = utils.MeasureStreamingPublishTime(ctx, stanConn, scenario.Subject, scenario.Times, messageSize)
+		case "subscribe":
+			channel := fmt.Sprintf("%s.%d", scenario.Subject, rand.Int())
+			result, err = utils.MeasureStreamingSubscribeTime(ctx, stanConn, channel, scenario.Times, messageSize, tester.conf.Verify)
+		case "load":
+			factory := utils.NewStreamingLoadClientFactory(tester.conf, tester.Key(), scenario.Subject)
+			loadResults, err := utils.MeasureLoad(ctx, factory, tester.publishers(), tester.subscribers(), scenario.Times, messageSize, tester.conf.Verify)
+			if err != nil {
+				return nil, xerrors.Errorf("執行情境 %s 失敗: %w", scenario.Name, err)
+			}
+			results = append(results, loadResults...)
+			continue
+		default:
+			return nil, xerrors.Errorf("Streaming 不支援的 operation %s", scenario.Operation)
+		}
+		if err != nil {
+			return nil, xerrors.Errorf("執行情境 %s 失敗: %w", scenario.Name, err)
+		}
+		results = append(results, result)
+	}
+
+	return results, nil
+}
+
+// testNATS 執行 NATS 的情境
+func (tester *scenarioTester) testNATS(ctx context.Context) ([]*report.Result, error) {
+	natsConn, err := utils.ConnectNATS(tester.conf, tester.Key())
+	if err != nil {
+		return nil, xerrors.Errorf("取得 NATS 連線失敗: %w", err)
+	}
+	defer natsConn.Close()
+
+	scenario := tester.scenario
+
+	// 啟動 Responder
+	if scenario.Operation == "request_reply" {
+		stopResponders, err := utils.StartNATSResponders(tester.conf, tester.Key(), scenario.Subject, "", tester.subscribers())
+		if err != nil {
+			return nil, xerrors.Errorf("啟動 Responder 失敗: %w", err)
+		}
+		defer stopResponders()
+	}
+
+	var results []*report.Result
+	for _, messageSize := range scenario.MessageSizes {
+		var result *report.Result
+		switch scenario.Operation {
+		case "publish":
+			result, err = utils.MeasureNATSPublishMsgTime(ctx, natsConn, scenario.Subject, scenario.Times, messageSize)
+		case "subscribe":
+			result, err = utils.MeasureNATSSubscribeTime(ctx, natsConn, scenario.Subject, scenario.Times, messageSize, tester.conf.Verify)
+		case "request_reply":
+			result, err = utils.MeasureNATSRequestReplyTime(ctx, natsConn, scenario.Subject, scenario.Times, messageSize, scenario.Percentiles, scenario.ShowChart)
+		case "load":
+			factory := utils.NewNATSLoadClientFactory(tester.conf, tester.Key(), scenario.Subject)
+			loadResults, err := utils.MeasureLoad(ctx, factory, tester.publishers(), tester.subscribers(), scenario.Times, messageSize, tester.conf.Verify)
+			if err != nil {
+				return nil, xerrors.Errorf("執行情境 %s 失敗: %w", scenario.Name, err)
+			}
+			results = append(results, loadResults...)
+			continue
+		default:
+			return nil, xerrors.Errorf("NATS 不支援的 operation %s", scenario.Operation)
+		}
+		if err != nil {
+			return nil, xerrors.Errorf("執行情境 %s 失敗: %w", scenario.Name, err)
+		}
+		results = append(results, result)
+	}
+
+	return results, nil
+}
+
+// streamConfig 依情境的設定產生 Stream 設定 (沒有設定的欄位使用 Server 的預設值)
+func (tester *scenarioTester) streamConfig() (*nats.StreamConfig, error) {
+	scenario := tester.scenario
+	streamConfig := &nats.StreamConfig{
+		Name: scenario.Name,
+		Subjects: []string{
+			scenario.Subject,
+		},
+	}
+	if scenario.Stream == nil {
+		return streamConfig, nil
+	}
+
+	if scenario.Stream.Name != "" {
+		streamConfig.Name = scenario.Stream.Name
+	}
+
+	switch scenario.Stream.Storage {
+	case "", "file":
+		streamConfig.Storage = nats.FileStorage
+	case "memory":
+		streamConfig.Storage = nats.MemoryStorage
+	default:
+		return nil, xerrors.Errorf("不支援的 storage %s", scenario.Stream.Storage)
+	}
+
+	if scenario.Stream.Retention != "" {
+		retentionPolicy, err := parseRetentionPolicy(scenario.Stream.Retention)
+		if err != nil {
+			return nil, xerrors.Errorf("設定 Retention 失敗: %w", err)
+		}
+		streamConfig.Retention = retentionPolicy
+	}
+
+	if scenario.Stream.Discard != "" {
+		discardPolicy, err := parseDiscardPolicy(scenario.Stream.Discard)
+		if err != nil {
+			return nil, xerrors.Errorf("設定 Discard 失敗: %w", err)
+		}
+		streamConfig.Discard = discardPolicy
+	}
+
+	streamConfig.Replicas = scenario.Stream.Replicas
+	streamConfig.MaxMsgs = scenario.Stream.MaxMsgs
+	streamConfig.MaxBytes = scenario.Stream.MaxBytes
+	streamConfig.MaxAge = scenario.Stream.MaxAge
+	streamConfig.Duplicates = scenario.Stream.DuplicateWindow
+	return streamConfig, nil
+}
+
+// consumerConfig 依情境的設定產生 Consumer 設定 (沒有設定的欄位使用預設值)
+func (tester *scenarioTester) consumerConfig() *config.ScenarioConsumerConfig {
+	consumerConfig := config.ScenarioConsumerConfig{}
+	if tester.scenario.Consumer != nil {
+		consumerConfig = *tester.scenario.Consumer
+	}
+
+	if consumerConfig.Durable == "" {
+		consumerConfig.Durable = tester.scenario.Name
+	}
+	if consumerConfig.AckPolicy == "" {
+		consumerConfig.AckPolicy = "explicit"
+	}
+	if consumerConfig.DeliverPolicy == "" {
+		consumerConfig.DeliverPolicy = "all"
+	}
+	if consumerConfig.ReplayPolicy == "" {
+		consumerConfig.ReplayPolicy = "instant"
+	}
+	if consumerConfig.FetchCount <= 0 {
+		consumerConfig.FetchCount = 1
+	}
+	return &consumerConfig
+}
+
+// publishers load 的發布者數量 (沒有設定時為 1)
+func (tester *scenarioTester) publishers() int {
+	if tester.scenario.Publishers == nil {
+		return 1
+	}
+	return *tester.scenario.Publishers
+}
+
+// subscribers load 的訂閱者數量 (設定為 0 時只測發布) 或 request_reply 的 Responder 數量 (沒有設定時為 1)
+func (tester *scenarioTester) subscribers() int {
+	if tester.scenario.Subscribers == nil {
+		return 1
+	}
+	return *tester.scenario.Subscribers
+}
@@ -0,0 +1,56 @@
+package tester
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/marco79423/nats-jetstream-test/config"
+	"github.com/marco79423/nats-jetstream-test/report"
+	"github.com/marco79423/nats-jetstream-test/tester/utils"
+	"golang.org/x/xerrors"
+)
+
+func NewStreamingLoadTester(conf *config.Config) ITester {
+	return &streamingLoadTester{
+		conf: conf,
+	}
+}
+
+type streamingLoadTester struct {
+	conf *config.Config
+}
+
+func (tester *streamingLoadTester) Name() string {
+	return "測試 Streaming 多發布者和多訂閱者的壓測效能"
+}
+
+func (tester *streamingLoadTester) Key() string {
+	return "streaming_load_tester"
+}
+
+func (tester *streamingLoadTester) Test(ctx context.Context) ([]*report.Result, error) {
+	rand.Seed(time.Now().UnixNano())
+	channel := tester.conf.Testers.StreamingLoadTester.Channel
+	publishers := tester.conf.Testers.StreamingLoadTester.Publishers
+	subscribers := tester.conf.Testers.StreamingLoadTester.Subscribers
+	times := tester.conf.Testers.StreamingLoadTester.Times
+	messageSizes := tester.conf.Testers.StreamingLoadTester.MessageSizes
+	fmt.Printf("Channel: %s, Publishers: %d, Subscribers: %d, Times: %d, MessageSizes: %v\n", channel, publishers, subscribers, times, messageSizes)
+
+	var results []*report.Result
+	for _, messageSize := range messageSizes {
+		channel := fmt.Sprintf("%s.%d", channel, rand.Int())
+
+		// 測量 Streaming 壓測效能
+		factory := utils.NewStreamingLoadClientFactory(tester.conf, tester.Key(), channel)
+		loadResults, err := utils.MeasureLoad(ctx, factory, publishers, subscribers, times, messageSize, tester.conf.Verify)
+		if err != nil {
+			return nil, xerrors.Errorf("測試 Streaming 的壓測效能失敗: %w", err)
+		}
+		results = append(results, loadResults...)
+	}
+
+	return results, nil
+}
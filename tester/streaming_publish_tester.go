@@ -1,11 +1,13 @@
 package tester
 
 import (
+	"context"
 	"fmt"
 	"math/rand"
 	"time"
 
 	"github.com/marco79423/nats-jetstream-test/config"
+	"github.com/marco79423/nats-jetstream-test/report"
 	"github.com/marco79423/nats-jetstream-test/tester/utils"
 	"golang.org/x/xerrors"
 )
@@ -28,11 +30,11 @@ func (tester *streamingPublishTester) Key() string {
 	return "streaming_publish_tester"
 }
 
-func (tester *streamingPublishTester) Test() error {
+func (tester *streamingPublishTester) Test(ctx context.Context) ([]*report.Result, error) {
 	// 取得 Streaming 的連線
-	stanConn, err := utils.ConnectSTAN(tester.conf, tester.Key())
+	stanConn, err := utils.ConnectSTAN(ctx, tester.conf, tester.Key())
 	if err != nil {
-		return xerrors.Errorf("取得 STAN 連線失敗: %w", err)
+		return nil, xerrors.Errorf("取得 STAN 連線失敗: %w", err)
 	}
 	defer stanConn.Close()
 
@@ -43,11 +45,15 @@ func (tester *streamingPublishTester) Test() error {
 	fmt.Printf("Channel: %s, Times: %d, MessageSizes: %v\n", channel, times, messageSizes)
 
 	// 測試 Streaming 發布效能
+
+	var results []*report.Result
 	for _, messageSize := range messageSizes {
-		if err := utils.MeasureStreamingPublishTime(stanConn, channel, times, messageSize); err != nil {
-			return xerrors.Errorf("測量 Streaming 的發布效能失敗: %w", err)
+		result, err := utils.MeasureStreamingPublishTime(ctx, stanConn, channel, times, messageSize)
+		if err != nil {
+			return nil, xerrors.Errorf("測量 Streaming 的發布效能失敗: %w", err)
 		}
+		results = append(results, result)
 	}
 
-	return nil
+	return results, nil
 }
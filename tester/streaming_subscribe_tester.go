@@ -1,11 +1,13 @@
 package tester
 
 import (
+	"context"
 	"fmt"
 	"math/rand"
 	"time"
 
 	"github.com/marco79423/nats-jetstream-test/config"
+	"github.com/marco79423/nats-jetstream-test/report"
 	"github.com/marco79423/nats-jetstream-test/tester/utils"
 	"golang.org/x/xerrors"
 )
@@ -28,11 +30,11 @@ func (tester *streamingSubscribeTester) Key() string {
 	return "streaming_subscribe_tester"
 }
 
-func (tester *streamingSubscribeTester) Test() error {
+func (tester *streamingSubscribeTester) Test(ctx context.Context) ([]*report.Result, error) {
 	// 取得 Streaming 的連線
-	stanConn, err := utils.ConnectSTAN(tester.conf, tester.Key())
+	stanConn, err := utils.ConnectSTAN(ctx, tester.conf, tester.Key())
 	if err != nil {
-		return xerrors.Errorf("取得 STAN 連線失敗: %w", err)
+		return nil, xerrors.Errorf("取得 STAN 連線失敗: %w", err)
 	}
 	defer stanConn.Close()
 
@@ -42,14 +44,17 @@ func (tester *streamingSubscribeTester) Test() error {
 	messageSizes := tester.conf.Testers.StreamingSubscribeTester.MessageSizes
 	fmt.Printf("Channel: %s, Times: %d, MessageSizes: %v\n", channel, times, messageSizes)
 
+	var results []*report.Result
 	for _, messageSize := range messageSizes {
 		channel := fmt.Sprintf("%s.%d", channel, rand.Int())
 
 		// 測試 Streaming 訂閱效能
-		if err := utils.MeasureStreamingSubscribeTime(stanConn, channel, times, messageSize); err != nil {
-			return xerrors.Errorf("測量 Streaming 的接收效能失敗: %w", err)
+		result, err := utils.MeasureStreamingSubscribeTime(ctx, stanConn, channel, times, messageSize, tester.conf.Verify)
+		if err != nil {
+			return nil, xerrors.Errorf("測量 Streaming 的接收效能失敗: %w", err)
 		}
+		results = append(results, result)
 	}
 
-	return nil
+	return results, nil
 }
@@ -1,24 +1,32 @@
 package tester
 
 import (
+	"context"
 	"fmt"
+	"os"
+	"strings"
+	"time"
 
 	"github.com/marco79423/nats-jetstream-test/config"
+	"github.com/marco79423/nats-jetstream-test/embedded"
+	"github.com/marco79423/nats-jetstream-test/monitor"
+	"github.com/marco79423/nats-jetstream-test/profiling"
+	"github.com/marco79423/nats-jetstream-test/report"
+	"github.com/marco79423/nats-jetstream-test/tester/utils"
 	"golang.org/x/xerrors"
 )
 
+// cancelGracePeriod 中斷後等待 Tester 停止的時間
+const cancelGracePeriod = 10 * time.Second
+
 type ITester interface {
 	Name() string
 	Key() string
-	Test() error
+	Test(ctx context.Context) ([]*report.Result, error) // ctx 取消時 (中斷或超過時間限制) 需要盡快停止並回傳錯誤
 }
 
-func RunTesters() error {
-	conf, err := config.GetConfig()
-	if err != nil {
-		return xerrors.Errorf("取得設定檔失敗: %w", err)
-	}
-
+// NewTesters 取得所有註冊的 Tester 和設定檔中的情境 (servers 為內嵌的 Server，沒有啟用時為 nil)
+func NewTesters(conf *config.Config, servers *embedded.Servers) []ITester {
 	testers := []ITester{
 		NewJetStreamPublishTester(conf),
 		NewJetStreamAsyncPublishTester(conf),
@@ -26,28 +34,265 @@ func RunTesters() error {
 		NewNATSPublishTester(conf),
 
 		NewStreamingSubscribeTester(conf),
+		NewNATSSubscribeTester(conf),
+		NewNATSRequestReplyTester(conf),
 		NewStreamingLatencyTester(conf),
 		NewJetStreamLatencyTester(conf),
+		NewNATSLatencyTester(conf),
 		NewJetStreamSubscribeTester(conf),
 		NewJetStreamChanSubscribeTester(conf),
 		NewJetStreamPullSubscribeTester(conf),
+		NewJetStreamConsumerTester(conf),
+		NewJetStreamReplicasTester(conf),
+		NewFaultInjectionTester(conf, servers),
+		NewJetStreamDedupTester(conf),
+		NewJetStreamRetentionTester(conf),
 		NewJetStreamPurgeStreamTester(conf),
 		NewJetStreamMemoryStorageTester(conf),
+
+		NewJetStreamLoadTester(conf),
+		NewStreamingLoadTester(conf),
+		NewNATSLoadTester(conf),
+	}
+
+	return append(testers, NewScenarioTesters(conf)...)
+}
+
+func RunTesters(ctx context.Context, conf *config.Config) (*report.Report, error) {
+	// 連線前先檢查設定，避免測到一半才因為設定錯誤而失敗
+	if err := conf.Validate(); err != nil {
+		return nil, xerrors.Errorf("檢查設定失敗: %w", err)
+	}
+
+	// 啟動內嵌的 Server
+	var servers *embedded.Servers
+	if conf.Embedded.Enabled {
+		var err error
+		servers, err = embedded.Start(conf)
+		if err != nil {
+			return nil, xerrors.Errorf("啟動內嵌的 Server 失敗: %w", err)
+		}
+		defer servers.Shutdown()
 	}
 
+	// 每次執行使用不重複的資源名稱，避免和其他執行或之前留下的資料互相影響
+	var runID string
+	if conf.Isolation.Enabled {
+		runID = config.NewRunID()
+		conf.ApplyIsolation(runID)
+		fmt.Printf("Run ID: %s (資源名稱的前綴為 %s)\n\n", runID, conf.IsolationPrefix())
+	}
+
+	testers := NewTesters(conf, servers)
+	serverMonitor := monitor.New(conf)
+	profiler := profiling.New(conf)
+
+	testReport := report.NewReport()
+	testReport.RunID = runID
 	for idx, testerKey := range conf.EnabledTesters {
+		// 中斷時不再執行剩下的 Tester，但仍會輸出已完成的報告
+		if ctx.Err() != nil {
+			testReport.Interrupted = true
+			break
+		}
+
 		for _, tester := range testers {
 			if tester.Key() == testerKey {
 				fmt.Printf("======== [%d] 開始 %s ========\n", idx+1, tester.Name())
-				if err := tester.Test(); err != nil {
-					return xerrors.Errorf("測試 %s 失敗: %w", tester.Name(), err)
+				now := time.Now()
+				testerReport := &report.TesterReport{
+					Key:  tester.Key(),
+					Name: tester.Name(),
+				}
+				err := runTester(ctx, conf, tester, testerReport, serverMonitor, profiler)
+				testerReport.ElapsedTime = time.Since(now)
+				if err == nil {
+					err = checkResultFailures(testerReport.Results)
+				}
+
+				// 失敗時記錄錯誤並繼續執行下一個 Tester
+				fmt.Println()
+				if err := report.WriteText(os.Stdout, testerReport.Results); err != nil {
+					return nil, xerrors.Errorf("輸出 %s 的測試結果失敗: %w", tester.Name(), err)
 				}
+				if err != nil {
+					testerReport.Error = err.Error()
+					fmt.Printf("%s 失敗: %s\n", tester.Name(), testerReport.Error)
+				}
+				if err := report.WriteServerMetrics(os.Stdout, testerReport.Server); err != nil {
+					return nil, xerrors.Errorf("輸出 %s 的 Server 監控數據失敗: %w", tester.Name(), err)
+				}
+				if err := report.WriteClientMetrics(os.Stdout, testerReport.Client); err != nil {
+					return nil, xerrors.Errorf("輸出 %s 的 Client Runtime 統計失敗: %w", tester.Name(), err)
+				}
+				testReport.AddTesterReport(testerReport)
 				fmt.Printf("======== [%d] 結束 %s ========\n\n", idx+1, tester.Name())
 
 				break
 			}
 		}
 	}
+	if ctx.Err() != nil {
+		testReport.Interrupted = true
+	}
+	testReport.Finish()
+
+	if err := report.WriteSummary(os.Stdout, testReport); err != nil {
+		return nil, xerrors.Errorf("輸出測試摘要失敗: %w", err)
+	}
+
+	if err := saveReport(conf, testReport); err != nil {
+		return nil, xerrors.Errorf("儲存測試報告失敗: %w", err)
+	}
+
+	if testReport.Interrupted {
+		return testReport, xerrors.Errorf("測試被中斷: %w", ctx.Err())
+	}
+	if failedTesters := testReport.FailedTesters(); len(failedTesters) > 0 {
+		return testReport, xerrors.Errorf("%d 個 Tester 失敗: %s", len(failedTesters), strings.Join(failedTesters, ", "))
+	}
+	return testReport, nil
+}
+
+// runTester 執行暖身後重複測量，並合併每次測量的結果 (設定 timeout 時，暖身和測量全部需要在時間內完成)
+//
+// 失敗、中斷或超過時間限制時 (或設定 isolation.teardown 時) 會刪除測試建立的 Stream，避免留下測試資料和 Durable Consumer，
+// 結果會填入 testerReport，serverMonitor 不為 nil 時會在執行前後 (刪除 Stream 前) 擷取 Server 監控端點的數據，
+// profiler 不為 nil 時會記錄執行期間 Client 的 Runtime 統計和 Profile
+func runTester(ctx context.Context, conf *config.Config, tester ITester, testerReport *report.TesterReport, serverMonitor *monitor.Monitor, profiler *profiling.Profiler) error {
+	if conf.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, conf.Timeout)
+		defer cancel()
+	}
+
+	if serverMonitor != nil {
+		testerReport.Server = &report.ServerMetrics{Before: serverMonitor.Snapshot()}
+	}
+	var profilingSession *profiling.Session
+	if profiler != nil {
+		profilingSession = profiler.Start(tester.Key())
+	}
+
+	ctx, resources := utils.WithResources(ctx)
+	results, err := runIterations(ctx, conf, tester)
+	testerReport.Results = results
+
+	if profilingSession != nil {
+		testerReport.Client = profilingSession.Stop()
+	}
+	if serverMonitor != nil {
+		testerReport.Server.After = serverMonitor.Snapshot()
+	}
+
+	if err != nil || conf.Isolation.Teardown {
+		cleanupResources(conf, resources)
+	}
+	return err
+}
+
+// checkResultFailures 有結果的正確性檢查失敗時回傳錯誤 (Tester 會被記為失敗，但仍保留結果)
+func checkResultFailures(results []*report.Result) error {
+	var failures []string
+	for _, result := range results {
+		for _, failure := range result.Failures {
+			failures = append(failures, fmt.Sprintf("[%s] %s", result.Operation, failure))
+		}
+	}
+	if len(failures) > 0 {
+		return xerrors.Errorf("%d 項驗證失敗:\n  - %s", len(failures), strings.Join(failures, "\n  - "))
+	}
+	return nil
+}
+
+// runIterations 執行暖身後重複測量
+func runIterations(ctx context.Context, conf *config.Config, tester ITester) ([]*report.Result, error) {
+	for i := 0; i < conf.Warmup; i++ {
+		fmt.Printf("-------- 暖身 %d/%d --------\n", i+1, conf.Warmup)
+		if _, err := testWithContext(ctx, tester); err != nil {
+			return nil, xerrors.Errorf("暖身失敗: %w", err)
+		}
+	}
+
+	iterations := conf.Iterations
+	if iterations <= 1 {
+		return testWithContext(ctx, tester)
+	}
+
+	iterationResults := make([][]*report.Result, 0, iterations)
+	for i := 0; i < iterations; i++ {
+		fmt.Printf("-------- 測量 %d/%d --------\n", i+1, iterations)
+		results, err := testWithContext(ctx, tester)
+		if err != nil {
+			return nil, xerrors.Errorf("第 %d 次測量失敗: %w", i+1, err)
+		}
+		iterationResults = append(iterationResults, results)
+	}
+
+	results, err := report.AggregateIterations(iterationResults)
+	if err != nil {
+		return nil, xerrors.Errorf("合併測量結果失敗: %w", err)
+	}
+	return results, nil
+}
+
+// testWithContext 在 goroutine 中執行測試，Tester 發生 panic 時會轉為錯誤
+//
+// ctx 取消後最多再等待 cancelGracePeriod 讓 Tester 停止並關閉連線，之後就不再等待並回傳錯誤
+// (Tester 會在背景繼續執行到結束，Tester 等待 Server 的操作都需要遵守 ctx，避免影響之後的 Tester)
+func testWithContext(ctx context.Context, tester ITester) ([]*report.Result, error) {
+	type testResult struct {
+		results []*report.Result
+		err     error
+	}
+
+	done := make(chan testResult, 1)
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				done <- testResult{err: xerrors.Errorf("發生 panic: %v", r)}
+			}
+		}()
+
+		results, err := tester.Test(ctx)
+		done <- testResult{results: results, err: err}
+	}()
+
+	select {
+	case result := <-done:
+		if result.err != nil && ctx.Err() != nil {
+			return nil, xerrors.Errorf("測試被中斷 (%v): %w", ctx.Err(), result.err)
+		}
+		return result.results, result.err
+	case <-ctx.Done():
+	}
+
+	select {
+	case result := <-done:
+		if result.err != nil {
+			return nil, xerrors.Errorf("測試被中斷 (%v): %w", ctx.Err(), result.err)
+		}
+		return nil, xerrors.Errorf("測試被中斷: %w", ctx.Err())
+	case <-time.After(cancelGracePeriod):
+		return nil, xerrors.Errorf("測試被中斷 (%v) 且沒有在 %v 內停止，仍在背景執行", ctx.Err(), cancelGracePeriod)
+	}
+}
+
+// saveReport 依設定輸出 JSON 和 CSV 報告
+func saveReport(conf *config.Config, testReport *report.Report) error {
+	if conf.Report.JSONPath != "" {
+		if err := report.SaveJSONFile(conf.Report.JSONPath, testReport); err != nil {
+			return xerrors.Errorf("儲存 JSON 報告失敗: %w", err)
+		}
+		fmt.Printf("JSON 報告已儲存至 %s\n", conf.Report.JSONPath)
+	}
+
+	if conf.Report.CSVPath != "" {
+		if err := report.SaveCSVFile(conf.Report.CSVPath, testReport.Results()); err != nil {
+			return xerrors.Errorf("儲存 CSV 報告失敗: %w", err)
+		}
+		fmt.Printf("CSV 報告已儲存至 %s\n", conf.Report.CSVPath)
+	}
 
 	return nil
 }
@@ -1,8 +1,10 @@
 package utils
 
 import (
+	"context"
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/marco79423/nats-jetstream-test/config"
 	"github.com/nats-io/nats.go"
@@ -10,21 +12,27 @@ import (
 	"golang.org/x/xerrors"
 )
 
-// ConnectNATS 取得 NATS 的連線
-func ConnectNATS(conf *config.Config, name string) (*nats.Conn, error) {
+// ConnectNATS 取得 NATS 的連線 (options 會覆蓋預設的設定)
+func ConnectNATS(conf *config.Config, name string, options ...nats.Option) (*nats.Conn, error) {
+	jetStreamConfig := conf.NATSJetStream
+	securityOptions, err := SecurityOptions(jetStreamConfig.Token, jetStreamConfig.Username, jetStreamConfig.Password, jetStreamConfig.CredsFile, jetStreamConfig.NKeySeedFile, jetStreamConfig.TLS)
+	if err != nil {
+		return nil, xerrors.Errorf("設定 NATS 連線失敗: %w", err)
+	}
+
 	natsConn, err := nats.Connect(
-		strings.Join(conf.NATSJetStream.Servers, ","),
-		nats.Name(name),
-		nats.Token(conf.NATSJetStream.Token),
-		nats.UserInfo(conf.NATSJetStream.Username, conf.NATSJetStream.Password),
-
-		nats.MaxReconnects(-1),
-		nats.ReconnectHandler(func(conn *nats.Conn) {
-			fmt.Println("NATS 重連成功")
-		}),
-		nats.ErrorHandler(func(conn *nats.Conn, subscription *nats.Subscription, err error) {
-			fmt.Println("NATS 連線錯誤: %w", err)
-		}),
+		strings.Join(jetStreamConfig.Servers, ","),
+		append(append([]nats.Option{
+			nats.Name(name),
+
+			nats.MaxReconnects(-1),
+			nats.ReconnectHandler(func(conn *nats.Conn) {
+				fmt.Println("NATS 重連成功")
+			}),
+			nats.ErrorHandler(func(conn *nats.Conn, subscription *nats.Subscription, err error) {
+				fmt.Println("NATS 連線錯誤: %w", err)
+			}),
+		}, securityOptions...), options...)...,
 	)
 	if err != nil {
 		return nil, xerrors.Errorf("取得 NATS 連線失敗: %w", err)
@@ -33,16 +41,30 @@ func ConnectNATS(conf *config.Config, name string) (*nats.Conn, error) {
 	return natsConn, nil
 }
 
-// ConnectSTAN 取得 NATS Streaming 的連線
-func ConnectSTAN(conf *config.Config, name string) (stan.Conn, error) {
+// minPubAckWait 等待 NATS Streaming 發布確認的最短時間 (ctx 的期限快到或已過時使用)
+const minPubAckWait = 100 * time.Millisecond
+
+// ConnectSTAN 取得 NATS Streaming 的連線 (ctx 有期限時等待發布確認的時間不會超過剩餘的時間)
+func ConnectSTAN(ctx context.Context, conf *config.Config, name string) (stan.Conn, error) {
+	return ConnectSTANWithClientID(ctx, conf, name, conf.NATSStreaming.ClientID)
+}
+
+// ConnectSTANWithClientID 以指定的 Client ID 取得 NATS Streaming 的連線 (同時有多個連線時 Client ID 不可重複，natsOptions 會覆蓋預設的設定)
+func ConnectSTANWithClientID(ctx context.Context, conf *config.Config, name, clientID string, natsOptions ...nats.Option) (stan.Conn, error) {
+	streamingConfig := conf.NATSStreaming
+	securityOptions, err := SecurityOptions(streamingConfig.Token, streamingConfig.Username, streamingConfig.Password, streamingConfig.CredsFile, streamingConfig.NKeySeedFile, streamingConfig.TLS)
+	if err != nil {
+		return nil, xerrors.Errorf("設定 STAN 連線失敗: %w", err)
+	}
+
 	stanConn, err := stan.Connect(
-		conf.NATSStreaming.ClusterID,
-		conf.NATSStreaming.ClientID,
-		stan.NatsURL(strings.Join(conf.NATSStreaming.Servers, ",")),
-		stan.NatsOptions(
+		streamingConfig.ClusterID,
+		clientID,
+		stan.NatsURL(strings.Join(streamingConfig.Servers, ",")),
+		stan.NatsOptions(append(append([]nats.Option{
 			nats.Name(name),
-			nats.Token(conf.NATSStreaming.Token),
-		),
+		}, securityOptions...), natsOptions...)...),
+		stan.PubAckWait(pubAckWait(ctx)),
 	)
 	if err != nil {
 		return nil, xerrors.Errorf("取得 STAN 連線失敗: %w", err)
@@ -50,3 +72,49 @@ func ConnectSTAN(conf *config.Config, name string) (stan.Conn, error) {
 
 	return stanConn, nil
 }
+
+// pubAckWait 等待發布確認的時間 (同步發布會一直等到收到確認，所以 ctx 有期限時不超過剩餘的時間，避免超過時間限制後仍在等待)
+func pubAckWait(ctx context.Context) time.Duration {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return stan.DefaultAckWait
+	}
+
+	wait := time.Until(deadline)
+	if wait > stan.DefaultAckWait {
+		return stan.DefaultAckWait
+	}
+	if wait < minPubAckWait {
+		return minPubAckWait
+	}
+	return wait
+}
+
+// SecurityOptions 依設定產生認證 (Token, 帳號密碼, .creds 檔案, NKey) 和 TLS 的連線設定 (沒有設定的項目會略過)
+func SecurityOptions(token, username, password, credsFile, nkeySeedFile string, tlsConfig config.TLSConfig) ([]nats.Option, error) {
+	var options []nats.Option
+	if token != "" {
+		options = append(options, nats.Token(token))
+	}
+	if username != "" {
+		options = append(options, nats.UserInfo(username, password))
+	}
+	if credsFile != "" {
+		options = append(options, nats.UserCredentials(credsFile))
+	}
+	if nkeySeedFile != "" {
+		nkeyOption, err := nats.NkeyOptionFromSeed(nkeySeedFile)
+		if err != nil {
+			return nil, xerrors.Errorf("讀取 NKey Seed 檔案 %s 失敗: %w", nkeySeedFile, err)
+		}
+		options = append(options, nkeyOption)
+	}
+
+	if tlsConfig.CAFile != "" {
+		options = append(options, nats.RootCAs(tlsConfig.CAFile))
+	}
+	if tlsConfig.CertFile != "" {
+		options = append(options, nats.ClientCert(tlsConfig.CertFile, tlsConfig.KeyFile))
+	}
+	return options, nil
+}
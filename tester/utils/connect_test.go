@@ -0,0 +1,39 @@
+package utils
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/nats-io/stan.go"
+)
+
+func TestPubAckWait(t *testing.T) {
+	testCases := []struct {
+		name    string
+		timeout time.Duration // 0 代表沒有期限
+		wantMin time.Duration
+		wantMax time.Duration
+	}{
+		{name: "no deadline", timeout: 0, wantMin: stan.DefaultAckWait, wantMax: stan.DefaultAckWait},
+		{name: "deadline after default", timeout: time.Hour, wantMin: stan.DefaultAckWait, wantMax: stan.DefaultAckWait},
+		{name: "deadline before default", timeout: 5 * time.Second, wantMin: 4 * time.Second, wantMax: 5 * time.Second},
+		{name: "deadline almost reached", timeout: time.Millisecond, wantMin: minPubAckWait, wantMax: minPubAckWait},
+		{name: "deadline exceeded", timeout: -time.Second, wantMin: minPubAckWait, wantMax: minPubAckWait},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			ctx := context.Background()
+			if testCase.timeout != 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, testCase.timeout)
+				defer cancel()
+			}
+
+			if got := pubAckWait(ctx); got < testCase.wantMin || got > testCase.wantMax {
+				t.Errorf("pubAckWait() = %v，預期在 %v ~ %v 之間", got, testCase.wantMin, testCase.wantMax)
+			}
+		})
+	}
+}
@@ -0,0 +1,272 @@
+package utils
+
+import (
+	"math"
+	"math/bits"
+	"sync"
+	"time"
+
+	"github.com/marco79423/nats-jetstream-test/report"
+)
+
+// DefaultPercentiles 預設要計算的百分位數
+var DefaultPercentiles = []float64{50, 90, 99, 99.9}
+
+// DefaultChartBucketCount 延遲分布圖預設的區間數量
+const DefaultChartBucketCount = 20
+
+// Histogram HDR 風格的延遲直方圖 (以 ns 為單位)
+//
+// 數值小於 2^subBucketBits 時每個值都有獨立的 bucket，超過後每翻倍一次就用
+// 2^(subBucketBits-1) 個 bucket 切分，因此相對誤差固定在有效位數的範圍內
+type Histogram struct {
+	mutex sync.Mutex
+
+	subBucketBits uint
+	counts        []int64
+
+	totalCount int64
+	min        int64
+	max        int64
+	sum        float64
+	sumSquares float64
+}
+
+// NewHistogram 建立直方圖 (significantFigures 為有效位數，範圍 1 ~ 5)
+func NewHistogram(significantFigures int) *Histogram {
+	if significantFigures < 1 {
+		significantFigures = 1
+	}
+	if significantFigures > 5 {
+		significantFigures = 5
+	}
+
+	// 每個區段至少要有 2 * 10^significantFigures 個 bucket 才能維持精度
+	subBucketCount := 2 * math.Pow10(significantFigures)
+	return &Histogram{
+		subBucketBits: uint(math.Ceil(math.Log2(subBucketCount))),
+	}
+}
+
+// RecordDuration 記錄一筆延遲
+func (histogram *Histogram) RecordDuration(duration time.Duration) {
+	histogram.RecordValue(int64(duration))
+}
+
+// RecordValue 記錄一筆數值 (負數視為 0)
+func (histogram *Histogram) RecordValue(value int64) {
+	if value < 0 {
+		value = 0
+	}
+
+	histogram.mutex.Lock()
+	defer histogram.mutex.Unlock()
+
+	idx := histogram.bucketIndex(value)
+	if idx >= len(histogram.counts) {
+		counts := make([]int64, idx+1)
+		copy(counts, histogram.counts)
+		histogram.counts = counts
+	}
+	histogram.counts[idx]++
+
+	if histogram.totalCount == 0 || value < histogram.min {
+		histogram.min = value
+	}
+	if value > histogram.max {
+		histogram.max = value
+	}
+	histogram.totalCount++
+	histogram.sum += float64(value)
+	histogram.sumSquares += float64(value) * float64(value)
+}
+
+// TotalCount 記錄的總筆數
+func (histogram *Histogram) TotalCount() int64 {
+	histogram.mutex.Lock()
+	defer histogram.mutex.Unlock()
+
+	return histogram.totalCount
+}
+
+// Mean 平均值
+func (histogram *Histogram) Mean() time.Duration {
+	histogram.mutex.Lock()
+	defer histogram.mutex.Unlock()
+
+	return time.Duration(histogram.mean())
+}
+
+// StdDev 標準差
+func (histogram *Histogram) StdDev() time.Duration {
+	histogram.mutex.Lock()
+	defer histogram.mutex.Unlock()
+
+	if histogram.totalCount == 0 {
+		return 0
+	}
+
+	mean := histogram.mean()
+	variance := histogram.sumSquares/float64(histogram.totalCount) - mean*mean
+	if variance < 0 {
+		variance = 0
+	}
+	return time.Duration(math.Sqrt(variance))
+}
+
+// Percentile 取得百分位數 (例如 99.9)
+func (histogram *Histogram) Percentile(percentile float64) time.Duration {
+	histogram.mutex.Lock()
+	defer histogram.mutex.Unlock()
+
+	return time.Duration(histogram.percentile(percentile))
+}
+
+// Distribution 將 min ~ max 平均切成數個區間 (範圍小於 bucketCount 時區間會比較少)，並計算每個區間的筆數
+func (histogram *Histogram) Distribution(bucketCount int) []*report.LatencyBucket {
+	histogram.mutex.Lock()
+	defer histogram.mutex.Unlock()
+
+	if histogram.totalCount == 0 || bucketCount <= 0 {
+		return nil
+	}
+
+	// 數值的範圍小於區間數量時減少區間，避免出現超過最大值的空區間
+	if valueRange := histogram.max - histogram.min; valueRange < int64(bucketCount) {
+		bucketCount = int(valueRange)
+		if bucketCount < 1 {
+			bucketCount = 1
+		}
+	}
+
+	width := (histogram.max - histogram.min) / int64(bucketCount)
+	if width == 0 {
+		width = 1
+	}
+
+	buckets := make([]*report.LatencyBucket, bucketCount)
+	for i := range buckets {
+		buckets[i] = &report.LatencyBucket{
+			From: time.Duration(histogram.min + int64(i)*width),
+			To:   time.Duration(histogram.min + int64(i+1)*width),
+		}
+	}
+	buckets[bucketCount-1].To = time.Duration(histogram.max)
+
+	for idx, count := range histogram.counts {
+		if count == 0 {
+			continue
+		}
+
+		value := histogram.clamp(histogram.medianEquivalentValue(idx))
+		bucketIdx := int((value - histogram.min) / width)
+		if bucketIdx >= bucketCount {
+			bucketIdx = bucketCount - 1
+		}
+		buckets[bucketIdx].Count += count
+	}
+
+	return buckets
+}
+
+// LatencyStats 轉為報告用的延遲統計 (chartBucketCount 為 0 代表不需要分布圖)
+func (histogram *Histogram) LatencyStats(percentiles []float64, chartBucketCount int) *report.LatencyStats {
+	if len(percentiles) == 0 {
+		percentiles = DefaultPercentiles
+	}
+
+	histogram.mutex.Lock()
+	stats := &report.LatencyStats{
+		Average: time.Duration(histogram.mean()),
+		Min:     time.Duration(histogram.min),
+		Max:     time.Duration(histogram.max),
+	}
+	for _, percentile := range percentiles {
+		stats.Percentiles = append(stats.Percentiles, &report.LatencyPercentile{
+			Percentile: percentile,
+			Value:      time.Duration(histogram.percentile(percentile)),
+		})
+	}
+	histogram.mutex.Unlock()
+
+	stats.StdDev = histogram.StdDev()
+	stats.Distribution = histogram.Distribution(chartBucketCount)
+	return stats
+}
+
+func (histogram *Histogram) mean() float64 {
+	if histogram.totalCount == 0 {
+		return 0
+	}
+	return histogram.sum / float64(histogram.totalCount)
+}
+
+func (histogram *Histogram) percentile(percentile float64) int64 {
+	if histogram.totalCount == 0 {
+		return 0
+	}
+
+	if percentile > 100 {
+		percentile = 100
+	}
+	// 扣掉浮點數的誤差 (例如 99.9 / 100 * 1000 會略大於 999)，避免排名多進位一筆
+	target := int64(math.Ceil(percentile/100*float64(histogram.totalCount) - 1e-9))
+	if target < 1 {
+		target = 1
+	}
+
+	var count int64
+	for idx, bucketCount := range histogram.counts {
+		count += bucketCount
+		if count >= target {
+			return histogram.clamp(histogram.highestEquivalentValue(idx))
+		}
+	}
+	return histogram.max
+}
+
+// clamp 確保估計值落在實際記錄的最小值和最大值之間
+func (histogram *Histogram) clamp(value int64) int64 {
+	if value < histogram.min {
+		return histogram.min
+	}
+	if value > histogram.max {
+		return histogram.max
+	}
+	return value
+}
+
+func (histogram *Histogram) bucketIndex(value int64) int {
+	subBucketCount := int64(1) << histogram.subBucketBits
+	if value < subBucketCount {
+		return int(value)
+	}
+
+	halfCount := subBucketCount >> 1
+	shift := uint(bits.Len64(uint64(value))) - histogram.subBucketBits
+	mantissa := value >> shift
+	return int(subBucketCount + int64(shift-1)*halfCount + (mantissa - halfCount))
+}
+
+func (histogram *Histogram) bucketRange(idx int) (int64, int64) {
+	subBucketCount := int64(1) << histogram.subBucketBits
+	if int64(idx) < subBucketCount {
+		return int64(idx), int64(idx)
+	}
+
+	halfCount := subBucketCount >> 1
+	offset := int64(idx) - subBucketCount
+	shift := uint(offset/halfCount) + 1
+	mantissa := offset%halfCount + halfCount
+	return mantissa << shift, (mantissa+1)<<shift - 1
+}
+
+func (histogram *Histogram) highestEquivalentValue(idx int) int64 {
+	_, highest := histogram.bucketRange(idx)
+	return highest
+}
+
+func (histogram *Histogram) medianEquivalentValue(idx int) int64 {
+	lowest, highest := histogram.bucketRange(idx)
+	return lowest + (highest-lowest)/2
+}
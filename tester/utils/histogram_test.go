@@ -0,0 +1,222 @@
+package utils
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestHistogramPercentile(t *testing.T) {
+	sequence := func(from, to, step int64) []int64 {
+		var values []int64
+		for value := from; value <= to; value += step {
+			values = append(values, value)
+		}
+		return values
+	}
+
+	testCases := []struct {
+		name       string
+		values     []int64
+		percentile float64
+		want       int64
+	}{
+		{name: "empty", values: nil, percentile: 50, want: 0},
+		{name: "single value", values: []int64{42}, percentile: 99.9, want: 42},
+		{name: "p0 returns min", values: sequence(1, 100, 1), percentile: 0, want: 1},
+		{name: "p50", values: sequence(1, 100, 1), percentile: 50, want: 50},
+		{name: "p90", values: sequence(1, 100, 1), percentile: 90, want: 90},
+		{name: "p99", values: sequence(1, 100, 1), percentile: 99, want: 99},
+		{name: "p99.9 rounds up", values: sequence(1, 100, 1), percentile: 99.9, want: 100},
+		{name: "p100 returns max", values: sequence(1, 100, 1), percentile: 100, want: 100},
+		{name: "over 100 is clamped", values: sequence(1, 100, 1), percentile: 150, want: 100},
+		{name: "negative values recorded as zero", values: []int64{-5, -1, 10}, percentile: 50, want: 0},
+		{name: "outlier", values: append(sequence(1, 999, 1), int64(time.Second)), percentile: 99.9, want: 999},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			histogram := NewHistogram(3)
+			for _, value := range testCase.values {
+				histogram.RecordValue(value)
+			}
+
+			if got := histogram.Percentile(testCase.percentile); got != time.Duration(testCase.want) {
+				t.Errorf("Percentile(%v) = %d，預期為 %d", testCase.percentile, got, testCase.want)
+			}
+		})
+	}
+}
+
+func TestHistogramPercentilePrecision(t *testing.T) {
+	testCases := []struct {
+		name               string
+		significantFigures int
+		maxRelativeError   float64
+	}{
+		{name: "1 significant figure", significantFigures: 1, maxRelativeError: 0.1},
+		{name: "2 significant figures", significantFigures: 2, maxRelativeError: 0.01},
+		{name: "3 significant figures", significantFigures: 3, maxRelativeError: 0.001},
+	}
+
+	// 1µs ~ 10s 的數值 (超過可以精確記錄的範圍)
+	var values []int64
+	for value := int64(time.Microsecond); value <= int64(10*time.Second); value = value*3/2 + 7 {
+		values = append(values, value)
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			for idx, value := range values {
+				histogram := NewHistogram(testCase.significantFigures)
+				// 最小和最大值放在兩側，避免估計值被夾在實際的範圍內
+				histogram.RecordValue(0)
+				histogram.RecordValue(value)
+				histogram.RecordValue(math.MaxInt32 * 100)
+
+				got := int64(histogram.Percentile(50))
+				if relativeError := math.Abs(float64(got-value)) / float64(value); relativeError > testCase.maxRelativeError {
+					t.Fatalf("第 %d 個數值 %d 的 p50 為 %d，相對誤差 %.5f 超過 %.5f", idx+1, value, got, relativeError, testCase.maxRelativeError)
+				}
+				if got < value {
+					t.Fatalf("第 %d 個數值 %d 的 p50 為 %d，不應該小於實際數值", idx+1, value, got)
+				}
+			}
+		})
+	}
+}
+
+func TestHistogramStats(t *testing.T) {
+	testCases := []struct {
+		name       string
+		values     []time.Duration
+		wantCount  int64
+		wantMean   time.Duration
+		wantStdDev time.Duration
+	}{
+		{name: "empty", values: nil},
+		{name: "single", values: []time.Duration{time.Millisecond}, wantCount: 1, wantMean: time.Millisecond},
+		{name: "same values", values: []time.Duration{5, 5, 5, 5}, wantCount: 4, wantMean: 5},
+		{name: "spread", values: []time.Duration{2, 4, 4, 4, 5, 5, 7, 9}, wantCount: 8, wantMean: 5, wantStdDev: 2},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			histogram := NewHistogram(3)
+			for _, value := range testCase.values {
+				histogram.RecordDuration(value)
+			}
+
+			if got := histogram.TotalCount(); got != testCase.wantCount {
+				t.Errorf("TotalCount() = %d，預期為 %d", got, testCase.wantCount)
+			}
+			if got := histogram.Mean(); got != testCase.wantMean {
+				t.Errorf("Mean() = %v，預期為 %v", got, testCase.wantMean)
+			}
+			if got := histogram.StdDev(); got != testCase.wantStdDev {
+				t.Errorf("StdDev() = %v，預期為 %v", got, testCase.wantStdDev)
+			}
+		})
+	}
+}
+
+func TestHistogramDistribution(t *testing.T) {
+	sequence := func(from, to int64) []int64 {
+		var values []int64
+		for value := from; value <= to; value++ {
+			values = append(values, value)
+		}
+		return values
+	}
+
+	testCases := []struct {
+		name        string
+		values      []int64
+		bucketCount int
+		wantBuckets int
+	}{
+		{name: "empty", values: nil, bucketCount: 20, wantBuckets: 0},
+		{name: "no buckets", values: sequence(1, 100), bucketCount: 0, wantBuckets: 0},
+		{name: "single value", values: []int64{42}, bucketCount: 20, wantBuckets: 1},
+		{name: "same values", values: []int64{7, 7, 7}, bucketCount: 20, wantBuckets: 1},
+		{name: "range smaller than bucket count", values: sequence(1, 5), bucketCount: 20, wantBuckets: 4},
+		{name: "range equal to bucket count", values: sequence(0, 20), bucketCount: 20, wantBuckets: 20},
+		{name: "range not divisible", values: sequence(1, 1000), bucketCount: 7, wantBuckets: 7},
+		{name: "large range", values: append(sequence(1, 1000), int64(time.Second)), bucketCount: 20, wantBuckets: 20},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			histogram := NewHistogram(3)
+			for _, value := range testCase.values {
+				histogram.RecordValue(value)
+			}
+
+			buckets := histogram.Distribution(testCase.bucketCount)
+			if len(buckets) != testCase.wantBuckets {
+				t.Fatalf("區間有 %d 個，預期為 %d 個", len(buckets), testCase.wantBuckets)
+			}
+			if len(buckets) == 0 {
+				return
+			}
+
+			min, max := testCase.values[0], testCase.values[0]
+			for _, value := range testCase.values {
+				if value < min {
+					min = value
+				}
+				if value > max {
+					max = value
+				}
+			}
+
+			var total int64
+			for idx, bucket := range buckets {
+				total += bucket.Count
+				if bucket.From > bucket.To {
+					t.Errorf("第 %d 個區間 %v ~ %v 的起點大於終點", idx+1, bucket.From, bucket.To)
+				}
+				if bucket.To > time.Duration(max) || bucket.From > time.Duration(max) {
+					t.Errorf("第 %d 個區間 %v ~ %v 超過最大值 %v", idx+1, bucket.From, bucket.To, time.Duration(max))
+				}
+				if idx > 0 && bucket.From != buckets[idx-1].To {
+					t.Errorf("第 %d 個區間的起點 %v 和前一個區間的終點 %v 不連續", idx+1, bucket.From, buckets[idx-1].To)
+				}
+			}
+			if buckets[0].From != time.Duration(min) || buckets[len(buckets)-1].To != time.Duration(max) {
+				t.Errorf("區間範圍為 %v ~ %v，預期為 %v ~ %v", buckets[0].From, buckets[len(buckets)-1].To, time.Duration(min), time.Duration(max))
+			}
+			if total != int64(len(testCase.values)) {
+				t.Errorf("區間共 %d 筆，預期為 %d 筆", total, len(testCase.values))
+			}
+		})
+	}
+}
+
+func TestHistogramLatencyStats(t *testing.T) {
+	histogram := NewHistogram(3)
+	for value := 1; value <= 1000; value++ {
+		histogram.RecordDuration(time.Duration(value))
+	}
+
+	stats := histogram.LatencyStats(nil, 10)
+	if stats.Min != 1 || stats.Max != 1000 {
+		t.Errorf("最小和最大值為 %v ~ %v，預期為 1ns ~ 1µs", stats.Min, stats.Max)
+	}
+	if len(stats.Percentiles) != len(DefaultPercentiles) {
+		t.Fatalf("百分位數有 %d 個，預期為 %d 個", len(stats.Percentiles), len(DefaultPercentiles))
+	}
+	for idx, want := range []time.Duration{500, 900, 990, 999} {
+		if percentile := stats.Percentiles[idx]; percentile.Value != want {
+			t.Errorf("p%v = %v，預期為 %v", percentile.Percentile, percentile.Value, want)
+		}
+	}
+
+	var total int64
+	for _, bucket := range stats.Distribution {
+		total += bucket.Count
+	}
+	if len(stats.Distribution) != 10 || total != 1000 {
+		t.Errorf("分布圖有 %d 個區間共 %d 筆，預期為 10 個區間共 1000 筆", len(stats.Distribution), total)
+	}
+}
@@ -0,0 +1,285 @@
+package utils
+
+import (
+	"context"
+	"encoding/binary"
+	"hash/crc32"
+	"sync"
+	"time"
+
+	"golang.org/x/xerrors"
+
+	"github.com/marco79423/nats-jetstream-test/report"
+)
+
+// IntegrityHeaderSize 驗證用的訊息標頭大小 (Publisher ID 4 bytes + 序號 8 bytes + Checksum 4 bytes)
+const IntegrityHeaderSize = 16
+
+// ReceiveIdleTimeout 開啟驗證時，超過這段時間都沒有收到新的訊息就視為剩下的訊息已遺失
+const ReceiveIdleTimeout = 10 * time.Second
+
+// MessageGenerator 產生第 seq 筆訊息 (seq 從 1 開始)
+type MessageGenerator func(seq int) []byte
+
+// NewMessageGenerator 產生指定大小的訊息 (開啟驗證時訊息開頭會帶有序號和 Checksum)
+func NewMessageGenerator(messageSize int, verify bool) MessageGenerator {
+	return NewPublisherMessageGenerator(0, messageSize, verify)
+}
+
+// NewPublisherMessageGenerator 產生指定發布者的訊息 (多個發布者時用 publisherID 區分序號，訊息大小至少為 IntegrityHeaderSize)
+func NewPublisherMessageGenerator(publisherID, messageSize int, verify bool) MessageGenerator {
+	if !verify {
+		message := []byte(GenerateRandomString(messageSize))
+		return func(seq int) []byte {
+			return message
+		}
+	}
+
+	bodySize := messageSize - IntegrityHeaderSize
+	if bodySize < 0 {
+		bodySize = 0
+	}
+	body := []byte(GenerateRandomString(bodySize))
+	return func(seq int) []byte {
+		return EncodeIntegrityMessage(publisherID, seq, body)
+	}
+}
+
+// EncodeIntegrityMessage 產生帶有發布者、序號和 Checksum 的訊息
+func EncodeIntegrityMessage(publisherID, seq int, body []byte) []byte {
+	data := make([]byte, IntegrityHeaderSize+len(body))
+	binary.BigEndian.PutUint32(data[0:4], uint32(publisherID))
+	binary.BigEndian.PutUint64(data[4:12], uint64(seq))
+	copy(data[IntegrityHeaderSize:], body)
+	binary.BigEndian.PutUint32(data[12:16], integrityChecksum(data))
+	return data
+}
+
+// DecodeIntegrityMessage 取得訊息的發布者和序號 (Checksum 不符時回傳錯誤)
+func DecodeIntegrityMessage(data []byte) (int, int, error) {
+	if len(data) < IntegrityHeaderSize {
+		return 0, 0, xerrors.Errorf("訊息大小 %d 小於標頭大小", len(data))
+	}
+
+	if binary.BigEndian.Uint32(data[12:16]) != integrityChecksum(data) {
+		return 0, 0, xerrors.New("Checksum 不符")
+	}
+
+	publisherID := int(binary.BigEndian.Uint32(data[0:4]))
+	seq := int(binary.BigEndian.Uint64(data[4:12]))
+	return publisherID, seq, nil
+}
+
+// integrityChecksum 計算 Checksum (不包含 Checksum 欄位本身)
+func integrityChecksum(data []byte) uint32 {
+	hash := crc32.NewIEEE()
+	_, _ = hash.Write(data[:12])
+	_, _ = hash.Write(data[IntegrityHeaderSize:])
+	return hash.Sum32()
+}
+
+// IntegrityChecker 檢查收到的訊息是否有遺失、重複、亂序或損毀
+type IntegrityChecker struct {
+	mu sync.Mutex
+
+	publisherCount int
+	firstSeq       int // 預計收到的序號範圍 (每個發布者都相同)
+	lastSeq        int
+
+	seen     map[integrityKey]bool
+	lastSeqs map[int]int // 每個發布者目前收到最大的序號
+	stats    report.IntegrityStats
+}
+
+type integrityKey struct {
+	publisherID int
+	seq         int
+}
+
+// NewIntegrityChecker 建立訊息驗證 (預計收到每個發布者從 firstSeq 到 lastSeq 的訊息)
+func NewIntegrityChecker(publisherCount, firstSeq, lastSeq int) *IntegrityChecker {
+	return &IntegrityChecker{
+		publisherCount: publisherCount,
+		firstSeq:       firstSeq,
+		lastSeq:        lastSeq,
+		seen:           make(map[integrityKey]bool),
+		lastSeqs:       make(map[int]int),
+	}
+}
+
+// Check 檢查收到的訊息，回傳是否為第一次收到的有效訊息
+func (checker *IntegrityChecker) Check(data []byte) bool {
+	checker.mu.Lock()
+	defer checker.mu.Unlock()
+
+	checker.stats.Received++
+
+	publisherID, seq, err := DecodeIntegrityMessage(data)
+	if err != nil {
+		checker.stats.Corrupted++
+		return false
+	}
+
+	key := integrityKey{publisherID: publisherID, seq: seq}
+	if checker.seen[key] {
+		checker.stats.Duplicated++
+		return false
+	}
+	checker.seen[key] = true
+
+	if seq < checker.lastSeqs[publisherID] {
+		checker.stats.OutOfOrder++
+	} else {
+		checker.lastSeqs[publisherID] = seq
+	}
+	return true
+}
+
+// UniqueCount 收到的不重複有效訊息數量
+func (checker *IntegrityChecker) UniqueCount() int {
+	checker.mu.Lock()
+	defer checker.mu.Unlock()
+
+	return len(checker.seen)
+}
+
+// Stats 取得驗證的統計 (沒收到的序號都視為遺失)
+func (checker *IntegrityChecker) Stats() *report.IntegrityStats {
+	checker.mu.Lock()
+	defer checker.mu.Unlock()
+
+	receivedInRange := 0
+	for key := range checker.seen {
+		if key.seq >= checker.firstSeq && key.seq <= checker.lastSeq {
+			receivedInRange++
+		}
+	}
+
+	stats := checker.stats
+	stats.Lost = checker.publisherCount*(checker.lastSeq-checker.firstSeq+1) - receivedInRange
+	return &stats
+}
+
+// MessageReceiver 統計收到的訊息數量，開啟驗證時會同時檢查每筆訊息 (重複和損毀的訊息不會計入)
+type MessageReceiver struct {
+	mu sync.Mutex
+
+	expectedCount  int
+	receivedCount  int
+	lastReceivedAt time.Time         // 最後一次收到有效訊息的時間
+	idleTime       time.Duration     // 沒有收到全部訊息時，最後一筆訊息之後等待的時間
+	checker        *IntegrityChecker // 沒有開啟驗證時為 nil
+	done           chan struct{}
+}
+
+// NewMessageReceiver 建立預計收到 expectedCount 筆訊息 (序號從 1 開始) 的接收者
+func NewMessageReceiver(expectedCount int, verify bool) *MessageReceiver {
+	var checker *IntegrityChecker
+	if verify {
+		checker = NewIntegrityChecker(1, 1, expectedCount)
+	}
+	return NewMessageReceiverWithChecker(expectedCount, checker)
+}
+
+// NewMessageReceiverWithChecker 以指定的驗證建立接收者 (checker 為 nil 代表不驗證)
+func NewMessageReceiverWithChecker(expectedCount int, checker *IntegrityChecker) *MessageReceiver {
+	return &MessageReceiver{
+		expectedCount: expectedCount,
+		checker:       checker,
+		done:          make(chan struct{}),
+	}
+}
+
+// Receive 收到訊息
+func (receiver *MessageReceiver) Receive(data []byte) {
+	if receiver.checker != nil && !receiver.checker.Check(data) {
+		return
+	}
+
+	receiver.mu.Lock()
+	defer receiver.mu.Unlock()
+
+	receiver.receivedCount++
+	receiver.lastReceivedAt = time.Now()
+	if receiver.receivedCount == receiver.expectedCount {
+		close(receiver.done)
+	}
+}
+
+// Done 收到全部訊息時會關閉
+func (receiver *MessageReceiver) Done() <-chan struct{} {
+	return receiver.done
+}
+
+// Wait 等待收到全部訊息 (ctx 取消時回傳錯誤)
+//
+// 沒有開啟驗證時會一直等到收到全部訊息，開啟驗證時超過 ReceiveIdleTimeout 沒有收到新的訊息就不再等待 (剩下的訊息視為遺失)，
+// 多等待的時間可以用 IdleTime 取得 (計算花費時間時需要扣除)
+func (receiver *MessageReceiver) Wait(ctx context.Context) error {
+	if receiver.checker == nil {
+		select {
+		case <-receiver.done:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+
+	waitStartedAt := time.Now()
+	for {
+		select {
+		case <-receiver.done:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+			if receiver.stopIfIdle(waitStartedAt) {
+				return nil
+			}
+		}
+	}
+}
+
+// stopIfIdle 超過 ReceiveIdleTimeout 沒有收到新的訊息時記錄等待的時間並回傳 true (都沒有收到訊息時從 waitStartedAt 開始計算)
+func (receiver *MessageReceiver) stopIfIdle(waitStartedAt time.Time) bool {
+	receiver.mu.Lock()
+	defer receiver.mu.Unlock()
+
+	lastReceivedAt := receiver.lastReceivedAt
+	if lastReceivedAt.IsZero() {
+		lastReceivedAt = waitStartedAt
+	}
+	idleTime := time.Since(lastReceivedAt)
+	if idleTime <= ReceiveIdleTimeout {
+		return false
+	}
+	receiver.idleTime = idleTime
+	return true
+}
+
+// IdleTime Wait 因為太久沒有收到訊息而停止時，最後一筆訊息之後等待的時間 (收到全部訊息時為 0)
+func (receiver *MessageReceiver) IdleTime() time.Duration {
+	receiver.mu.Lock()
+	defer receiver.mu.Unlock()
+
+	return receiver.idleTime
+}
+
+// ReceivedCount 目前收到的訊息數量
+func (receiver *MessageReceiver) ReceivedCount() int {
+	receiver.mu.Lock()
+	defer receiver.mu.Unlock()
+
+	return receiver.receivedCount
+}
+
+// Integrity 取得驗證的統計 (沒有開啟驗證時為 nil)
+func (receiver *MessageReceiver) Integrity() *report.IntegrityStats {
+	if receiver.checker == nil {
+		return nil
+	}
+	return receiver.checker.Stats()
+}
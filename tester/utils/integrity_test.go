@@ -0,0 +1,138 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/marco79423/nats-jetstream-test/report"
+)
+
+func TestEncodeDecodeIntegrityMessage(t *testing.T) {
+	testCases := []struct {
+		name        string
+		publisherID int
+		seq         int
+		body        []byte
+	}{
+		{name: "empty body", publisherID: 0, seq: 1, body: nil},
+		{name: "with body", publisherID: 3, seq: 42, body: []byte("hello world")},
+		{name: "large seq", publisherID: 1<<32 - 1, seq: 1 << 40, body: []byte{0, 1, 2, 3}},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			data := EncodeIntegrityMessage(testCase.publisherID, testCase.seq, testCase.body)
+			if len(data) != IntegrityHeaderSize+len(testCase.body) {
+				t.Fatalf("訊息大小為 %d，預期為 %d", len(data), IntegrityHeaderSize+len(testCase.body))
+			}
+
+			publisherID, seq, err := DecodeIntegrityMessage(data)
+			if err != nil {
+				t.Fatalf("解析訊息失敗: %v", err)
+			}
+			if publisherID != testCase.publisherID || seq != testCase.seq {
+				t.Errorf("解析結果為 (%d, %d)，預期為 (%d, %d)", publisherID, seq, testCase.publisherID, testCase.seq)
+			}
+		})
+	}
+}
+
+func TestDecodeIntegrityMessageRejectsInvalidData(t *testing.T) {
+	valid := EncodeIntegrityMessage(1, 7, []byte("payload"))
+	corrupt := func(idx int) []byte {
+		data := append([]byte{}, valid...)
+		data[idx] ^= 0xff
+		return data
+	}
+
+	testCases := []struct {
+		name string
+		data []byte
+	}{
+		{name: "empty", data: nil},
+		{name: "shorter than header", data: valid[:IntegrityHeaderSize-1]},
+		{name: "publisher id changed", data: corrupt(0)},
+		{name: "seq changed", data: corrupt(11)},
+		{name: "checksum changed", data: corrupt(12)},
+		{name: "body changed", data: corrupt(IntegrityHeaderSize)},
+		{name: "body truncated", data: valid[:len(valid)-1]},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			if _, _, err := DecodeIntegrityMessage(testCase.data); err == nil {
+				t.Errorf("應該回傳錯誤")
+			}
+		})
+	}
+}
+
+func TestIntegrityChecker(t *testing.T) {
+	message := func(publisherID, seq int) []byte {
+		return EncodeIntegrityMessage(publisherID, seq, []byte("body"))
+	}
+
+	testCases := []struct {
+		name           string
+		publisherCount int
+		firstSeq       int
+		lastSeq        int
+		messages       [][]byte
+		want           report.IntegrityStats
+	}{
+		{
+			name:           "all received in order",
+			publisherCount: 1, firstSeq: 1, lastSeq: 3,
+			messages: [][]byte{message(0, 1), message(0, 2), message(0, 3)},
+			want:     report.IntegrityStats{Received: 3},
+		},
+		{
+			name:           "lost",
+			publisherCount: 1, firstSeq: 1, lastSeq: 4,
+			messages: [][]byte{message(0, 1), message(0, 3)},
+			want:     report.IntegrityStats{Received: 2, Lost: 2},
+		},
+		{
+			name:           "duplicated",
+			publisherCount: 1, firstSeq: 1, lastSeq: 2,
+			messages: [][]byte{message(0, 1), message(0, 1), message(0, 2)},
+			want:     report.IntegrityStats{Received: 3, Duplicated: 1},
+		},
+		{
+			name:           "out of order",
+			publisherCount: 1, firstSeq: 1, lastSeq: 3,
+			messages: [][]byte{message(0, 1), message(0, 3), message(0, 2)},
+			want:     report.IntegrityStats{Received: 3, OutOfOrder: 1},
+		},
+		{
+			name:           "corrupted",
+			publisherCount: 1, firstSeq: 1, lastSeq: 2,
+			messages: [][]byte{message(0, 1), []byte("not a valid message")},
+			want:     report.IntegrityStats{Received: 2, Lost: 1, Corrupted: 1},
+		},
+		{
+			name:           "multiple publishers are ordered separately",
+			publisherCount: 2, firstSeq: 1, lastSeq: 2,
+			messages: [][]byte{message(0, 1), message(1, 1), message(1, 2), message(0, 2)},
+			want:     report.IntegrityStats{Received: 4},
+		},
+		{
+			name:           "messages outside range are not counted as received",
+			publisherCount: 1, firstSeq: 3, lastSeq: 4,
+			messages: [][]byte{message(0, 1), message(0, 3)},
+			want:     report.IntegrityStats{Received: 2, Lost: 1},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			checker := NewIntegrityChecker(testCase.publisherCount, testCase.firstSeq, testCase.lastSeq)
+			for _, data := range testCase.messages {
+				checker.Check(data)
+			}
+
+			if got := *checker.Stats(); got != testCase.want {
+				t.Errorf("驗證結果為 %+v，預期為 %+v", got, testCase.want)
+			}
+		})
+	}
+}
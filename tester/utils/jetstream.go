@@ -1,15 +1,21 @@
 package utils
 
 import (
+	"context"
 	"fmt"
-	"sync"
 	"time"
 
 	"github.com/nats-io/nats.go"
 	"golang.org/x/xerrors"
+
+	"github.com/marco79423/nats-jetstream-test/report"
 )
 
-func RecreateJetStreamStreamIfExists(js nats.JetStreamContext, config *nats.StreamConfig) (*nats.StreamInfo, error) {
+// RecreateJetStreamStreamIfExists 重建 Stream (會記錄到 ctx 的 Resources，測試中斷時可以刪除)
+func RecreateJetStreamStreamIfExists(ctx context.Context, js nats.JetStreamContext, config *nats.StreamConfig) (*nats.StreamInfo, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, xerrors.Errorf("重建 Stream 失敗: %w", err)
+	}
 	streamName := config.Name
 
 	// 可透過 StreamInfo 確認 Stream 是否存在
@@ -32,15 +38,23 @@ func RecreateJetStreamStreamIfExists(js nats.JetStreamContext, config *nats.Stre
 	if err != nil {
 		return nil, xerrors.Errorf("重建 Stream 失敗: %w", err)
 	}
+	TrackJetStreamStream(ctx, streamName)
 
 	return stream, nil
 }
 
 // PublishJetStreamMessagesWithSize 發布大量訊息 (Subject, 數量)
-func PublishJetStreamMessagesWithSize(jetStreamCtx nats.JetStreamContext, subject string, messageCount, messageSize int) error {
-	message := GenerateRandomString(messageSize)
+func PublishJetStreamMessagesWithSize(ctx context.Context, jetStreamCtx nats.JetStreamContext, subject string, messageCount, messageSize int) error {
+	return PublishJetStreamMessages(ctx, jetStreamCtx, subject, messageCount, NewMessageGenerator(messageSize, false))
+}
+
+// PublishJetStreamMessages 發布大量訊息 (訊息由 generate 產生，ctx 取消時停止)
+func PublishJetStreamMessages(ctx context.Context, jetStreamCtx nats.JetStreamContext, subject string, messageCount int, generate MessageGenerator) error {
 	for i := 0; i < messageCount; i++ {
-		if _, err := jetStreamCtx.Publish(subject, []byte(message)); err != nil {
+		if err := ctx.Err(); err != nil {
+			return xerrors.Errorf("發布大量訊息 (Subject: %s, 數量： %d): %w", subject, messageCount, err)
+		}
+		if _, err := jetStreamCtx.Publish(subject, generate(i+1)); err != nil {
 			return xerrors.Errorf("發布大量訊息 (Subject: %s, 數量： %d): %w", subject, messageCount, err)
 		}
 		// fmt.Println(i)
@@ -49,154 +63,200 @@ func PublishJetStreamMessagesWithSize(jetStreamCtx nats.JetStreamContext, subjec
 }
 
 // AsyncPublishJetStreamMessagesWithSize 發布大量訊息 Async (Subject, 數量)
-func AsyncPublishJetStreamMessagesWithSize(jetStreamCtx nats.JetStreamContext, subject string, messageCount, messageSize int) error {
+func AsyncPublishJetStreamMessagesWithSize(ctx context.Context, jetStreamCtx nats.JetStreamContext, subject string, messageCount, messageSize int) error {
 	message := GenerateRandomString(messageSize)
 	for i := 0; i < messageCount; i++ {
+		if err := ctx.Err(); err != nil {
+			return xerrors.Errorf("發布大量訊息 (Subject: %s, 數量： %d): %w", subject, messageCount, err)
+		}
 		if _, err := jetStreamCtx.PublishAsync(subject, []byte(message)); err != nil {
 			return xerrors.Errorf("發布大量訊息 (Subject: %s, 數量： %d): %w", subject, messageCount, err)
 		}
 		// fmt.Println(i)
 	}
 
-	<-jetStreamCtx.PublishAsyncComplete()
-	return nil
+	select {
+	case <-jetStreamCtx.PublishAsyncComplete():
+		return nil
+	case <-ctx.Done():
+		return xerrors.Errorf("等待發布完成失敗: %w", ctx.Err())
+	}
 }
 
 // MeasureJetStreamPublishMsgTime 測試 JetStream 發布效能
-func MeasureJetStreamPublishMsgTime(jetStreamCtx nats.JetStreamContext, subject string, messageCount, messageSize int) error {
+func MeasureJetStreamPublishMsgTime(ctx context.Context, jetStreamCtx nats.JetStreamContext, subject string, messageCount, messageSize int) (*report.Result, error) {
 	fmt.Printf("開始測試 JetStream 的發布 (Publish) 效能 (次數: %d, 訊息大小： %d)\n", messageCount, messageSize)
 
 	now := time.Now()
-	if err := PublishJetStreamMessagesWithSize(jetStreamCtx, subject, messageCount, messageSize); err != nil {
-		return xerrors.Errorf("測量 JetStream 發布訊息所需的時間失敗: %w", subject, err)
+	if err := PublishJetStreamMessagesWithSize(ctx, jetStreamCtx, subject, messageCount, messageSize); err != nil {
+		return nil, xerrors.Errorf("測量 JetStream 發布訊息所需的時間失敗: %w", err)
 	}
 	elapsedTime := time.Since(now)
 
-	fmt.Printf("全部 %d 筆發布花費時間 %v (訊息大小： %v, 每筆平均花費 %v)\n",
-		messageCount,
-		elapsedTime,
-		messageSize,
-		elapsedTime/time.Duration(messageCount),
-	)
-
-	return nil
+	return report.NewThroughputResult("JetStream Publish", messageCount, messageSize, elapsedTime), nil
 }
 
 // MeasureJetStreamAsyncPublishMsgTime 測試 JetStream 發布效能 (Async)
-func MeasureJetStreamAsyncPublishMsgTime(jetStreamCtx nats.JetStreamContext, subject string, messageCount, messageSize int) error {
+func MeasureJetStreamAsyncPublishMsgTime(ctx context.Context, jetStreamCtx nats.JetStreamContext, subject string, messageCount, messageSize int) (*report.Result, error) {
 	fmt.Printf("開始測試 JetStream 的發布 (AsyncPublish) 效能 (次數: %d, 訊息大小： %d)\n", messageCount, messageSize)
 
 	now := time.Now()
-	if err := AsyncPublishJetStreamMessagesWithSize(jetStreamCtx, subject, messageCount, messageSize); err != nil {
-		return xerrors.Errorf("測量 JetStream 發布訊息所需的時間失敗: %w", subject, err)
+	if err := AsyncPublishJetStreamMessagesWithSize(ctx, jetStreamCtx, subject, messageCount, messageSize); err != nil {
+		return nil, xerrors.Errorf("測量 JetStream 發布訊息所需的時間失敗: %w", err)
 	}
 	elapsedTime := time.Since(now)
 
-	fmt.Printf("全部 %d 筆發布花費時間 %v (訊息大小： %v, 每筆平均花費 %v)\n",
-		messageCount,
-		elapsedTime,
-		messageSize,
-		elapsedTime/time.Duration(messageCount),
-	)
+	return report.NewThroughputResult("JetStream AsyncPublish", messageCount, messageSize, elapsedTime), nil
+}
 
-	return nil
+// MeasureJetStreamPublishAckLatency 測試 JetStream 發布效能和等待 Ack 的延遲 (每筆都等到 Server 回覆 Ack 才發布下一筆)
+func MeasureJetStreamPublishAckLatency(ctx context.Context, jetStreamCtx nats.JetStreamContext, subject string, messageCount, messageSize int, percentiles []float64, showChart bool) (*report.Result, error) {
+	fmt.Printf("開始測試 JetStream 的發布 Ack 延遲 (次數: %d, 訊息大小： %d)\n", messageCount, messageSize)
+
+	// 有效位數 3 位 (誤差約 0.1%)
+	histogram := NewHistogram(3)
+
+	message := []byte(GenerateRandomString(messageSize))
+	now := time.Now()
+	for i := 0; i < messageCount; i++ {
+		if err := ctx.Err(); err != nil {
+			return nil, xerrors.Errorf("測量 JetStream 發布 Ack 延遲失敗: %w", err)
+		}
+
+		startTime := time.Now()
+		if _, err := jetStreamCtx.Publish(subject, message); err != nil {
+			return nil, xerrors.Errorf("發布 %s 失敗: %w", subject, err)
+		}
+		histogram.RecordDuration(time.Since(startTime))
+	}
+	elapsedTime := time.Since(now)
+
+	chartBucketCount := 0
+	if showChart {
+		chartBucketCount = DefaultChartBucketCount
+	}
+	latency := histogram.LatencyStats(percentiles, chartBucketCount)
+
+	return report.NewLatencyResult("JetStream Publish Ack", messageCount, messageSize, elapsedTime, latency), nil
 }
 
-// MeasureJetStreamSubscribeTime 測量 JetStream 訂閱效能 (Subscribe)
-func MeasureJetStreamSubscribeTime(jetStreamCtx nats.JetStreamContext, subject string, messageCount, messageSize int) error {
+// MeasureJetStreamSubscribeTime 測量 JetStream 訂閱效能 (Subscribe，verify 代表是否驗證訊息)
+func MeasureJetStreamSubscribeTime(ctx context.Context, jetStreamCtx nats.JetStreamContext, subject string, messageCount, messageSize int, verify bool) (*report.Result, error) {
 	fmt.Printf("開始測量 JetStream (Subscribe) 的接收效能 (次數： %d, 訊息大小：%d)\n", messageCount, messageSize)
 
-	if err := PublishJetStreamMessagesWithSize(jetStreamCtx, subject, messageCount, messageSize); err != nil {
-		return xerrors.Errorf("測量 JetStream 訂閱所需的時間失敗: %w", subject, err)
+	if err := PublishJetStreamMessages(ctx, jetStreamCtx, subject, messageCount, NewMessageGenerator(messageSize, verify)); err != nil {
+		return nil, xerrors.Errorf("測量 JetStream 訂閱所需的時間失敗: %w", err)
 	}
 
-	wg := sync.WaitGroup{}
-	wg.Add(messageCount)
+	receiver := NewMessageReceiver(messageCount, verify)
 	now := time.Now()
-	if _, err := jetStreamCtx.Subscribe(subject, func(msg *nats.Msg) {
+	sub, err := jetStreamCtx.Subscribe(subject, func(msg *nats.Msg) {
 		// fmt.Printf("Received a JetStream message: %s\n", string(msg.Data))
-		wg.Done()
-	}); err != nil {
-		return xerrors.Errorf("訂閱 %s 失敗: %w", subject, err)
+		receiver.Receive(msg.Data)
+	})
+	if err != nil {
+		return nil, xerrors.Errorf("訂閱 %s 失敗: %w", subject, err)
+	}
+	defer sub.Unsubscribe()
+	if err := receiver.Wait(ctx); err != nil {
+		return nil, xerrors.Errorf("等待接收訊息失敗: %w", err)
 	}
-	wg.Wait()
 
-	elapsedTime := time.Since(now)
-	fmt.Printf("全部 %d 筆接收花費時間 %v (每筆平均花費 %v)\n",
-		messageCount,
-		elapsedTime,
-		elapsedTime/time.Duration(messageCount),
-	)
-	return nil
+	// 沒有收到全部訊息時不計入最後等待的時間
+	elapsedTime := time.Since(now) - receiver.IdleTime()
+
+	result := report.NewThroughputResult("JetStream Subscribe", messageCount, messageSize, elapsedTime)
+	result.SetIntegrity(receiver.Integrity())
+	return result, nil
 }
 
-// MeasureJetStreamChanSubscribeTime 測量 JetStream 訂閱效能 (Chan Subscribe)
-func MeasureJetStreamChanSubscribeTime(jetStreamCtx nats.JetStreamContext, subject string, messageCount, messageSize int) error {
+// MeasureJetStreamChanSubscribeTime 測量 JetStream 訂閱效能 (Chan Subscribe，verify 代表是否驗證訊息)
+func MeasureJetStreamChanSubscribeTime(ctx context.Context, jetStreamCtx nats.JetStreamContext, subject string, messageCount, messageSize int, verify bool) (*report.Result, error) {
 	fmt.Printf("開始測量 JetStream (Chan Subscribe) 的接收效能 (次數： %d, 訊息大小：%d)\n", messageCount, messageSize)
 
-	if err := PublishJetStreamMessagesWithSize(jetStreamCtx, subject, messageCount, messageSize); err != nil {
-		return xerrors.Errorf("測量 JetStream 訂閱所需的時間失敗: %w", subject, err)
+	if err := PublishJetStreamMessages(ctx, jetStreamCtx, subject, messageCount, NewMessageGenerator(messageSize, verify)); err != nil {
+		return nil, xerrors.Errorf("測量 JetStream 訂閱所需的時間失敗: %w", err)
 	}
 
+	receiver := NewMessageReceiver(messageCount, verify)
 	now := time.Now()
 	msgChan := make(chan *nats.Msg, 10000)
-	if _, err := jetStreamCtx.ChanSubscribe(subject, msgChan); err != nil {
-		return xerrors.Errorf("訂閱 %s 失敗: %w", subject, err)
+	sub, err := jetStreamCtx.ChanSubscribe(subject, msgChan)
+	if err != nil {
+		return nil, xerrors.Errorf("訂閱 %s 失敗: %w", subject, err)
 	}
-
-	receiveCount := 0
-	for msg := range msgChan {
-		_ = msg
-		// fmt.Printf("Received a JetStream message: %s\n", string(msg.Data))
-
-		receiveCount += 1
-		if receiveCount == messageCount {
-			break
+	defer sub.Unsubscribe()
+
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		for {
+			select {
+			case msg := <-msgChan:
+				// fmt.Printf("Received a JetStream message: %s\n", string(msg.Data))
+				receiver.Receive(msg.Data)
+			case <-stop:
+				return
+			}
 		}
+	}()
+	if err := receiver.Wait(ctx); err != nil {
+		return nil, xerrors.Errorf("等待接收訊息失敗: %w", err)
 	}
 
-	elapsedTime := time.Since(now)
-	fmt.Printf("全部 %d 筆接收花費時間 %v (每筆平均花費 %v)\n",
-		messageCount,
-		elapsedTime,
-		elapsedTime/time.Duration(messageCount),
-	)
-	return nil
+	// 沒有收到全部訊息時不計入最後等待的時間
+	elapsedTime := time.Since(now) - receiver.IdleTime()
+
+	result := report.NewThroughputResult("JetStream Chan Subscribe", messageCount, messageSize, elapsedTime)
+	result.SetIntegrity(receiver.Integrity())
+	return result, nil
 }
 
-// MeasureJetStreamPullSubscribeTime 測量 JetStream 訂閱效能 (Pull Subscribe)
-func MeasureJetStreamPullSubscribeTime(jetStreamCtx nats.JetStreamContext, durableName, subject string, messageCount, messageSize, fetchCount int) error {
+// MeasureJetStreamPullSubscribeTime 測量 JetStream 訂閱效能 (Pull Subscribe，verify 代表是否驗證訊息，opts 為額外的 Consumer 設定)
+func MeasureJetStreamPullSubscribeTime(ctx context.Context, jetStreamCtx nats.JetStreamContext, durableName, subject string, messageCount, messageSize, fetchCount int, verify bool, opts ...nats.SubOpt) (*report.Result, error) {
 	fmt.Printf("開始測量 JetStream (Pull Subscribe) 的接收效能 (次數： %d, 訊息大小：%d)\n", messageCount, messageSize)
 
-	if err := PublishJetStreamMessagesWithSize(jetStreamCtx, subject, messageCount, messageSize); err != nil {
-		return xerrors.Errorf("測量 JetStream 訂閱所需的時間失敗: %w", subject, err)
+	if err := PublishJetStreamMessages(ctx, jetStreamCtx, subject, messageCount, NewMessageGenerator(messageSize, verify)); err != nil {
+		return nil, xerrors.Errorf("測量 JetStream 訂閱所需的時間失敗: %w", err)
 	}
 
+	receiver := NewMessageReceiver(messageCount, verify)
 	now := time.Now()
-	sub, err := jetStreamCtx.PullSubscribe(subject, durableName)
+	sub, err := jetStreamCtx.PullSubscribe(subject, durableName, opts...)
 	if err != nil {
-		return xerrors.Errorf("訂閱 %s 失敗: %w", subject, err)
+		return nil, xerrors.Errorf("訂閱 %s 失敗: %w", subject, err)
 	}
+	defer sub.Unsubscribe()
+
+	lastReceivedAt := time.Now()
+	var idleTime time.Duration // 沒有收到全部訊息時，最後一筆訊息之後等待的時間 (不計入花費時間)
+	for receiver.ReceivedCount() < messageCount {
+		if err := ctx.Err(); err != nil {
+			return nil, xerrors.Errorf("等待接收訊息失敗: %w", err)
+		}
 
-	receiveCount := 0
-	for receiveCount < messageCount {
 		msgs, _ := sub.Fetch(fetchCount) // 不同數量也會有區別
 
 		for _, msg := range msgs {
-			_ = msg
 			// fmt.Printf("Received a JetStream message: %s\n", string(msg.Data))
-			receiveCount++
+			receiver.Receive(msg.Data)
 
 			msg.Ack()
 		}
+
+		// 開啟驗證時，太久沒收到訊息就視為剩下的訊息已遺失
+		if len(msgs) > 0 {
+			lastReceivedAt = time.Now()
+		} else if verify && time.Since(lastReceivedAt) > ReceiveIdleTimeout {
+			idleTime = time.Since(lastReceivedAt)
+			break
+		}
 	}
 
-	elapsedTime := time.Since(now)
-	fmt.Printf("全部 %d 筆接收花費時間 %v (一次抓 %d 筆，每筆平均花費 %v)\n",
-		messageCount,
-		elapsedTime,
-		fetchCount,
-		elapsedTime/time.Duration(messageCount),
-	)
-	return nil
+	elapsedTime := time.Since(now) - idleTime
+
+	result := report.NewThroughputResult("JetStream Pull Subscribe", messageCount, messageSize, elapsedTime)
+	result.SetParam("fetch_count", fetchCount)
+	result.SetIntegrity(receiver.Integrity())
+	return result, nil
 }
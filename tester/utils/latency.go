@@ -0,0 +1,126 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/xerrors"
+
+	"github.com/marco79423/nats-jetstream-test/report"
+)
+
+// LatencyOptions 延遲測試的設定
+type LatencyOptions struct {
+	Times       int
+	Rate        float64 // 每秒發布的筆數 (0 代表不限速，一筆接著一筆發布)
+	Percentiles []float64
+	ShowChart   bool
+}
+
+// LatencySubscribeFunc 訂閱訊息 (收到訊息時需呼叫 onMessage)，回傳取消訂閱的函式
+type LatencySubscribeFunc func(onMessage func(data []byte)) (unsubscribe func(), err error)
+
+// LatencyPublishFunc 發布訊息
+type LatencyPublishFunc func(data []byte) error
+
+// MeasureLatency 測量訊息從預定發送時間到收到訊息的延遲 (ctx 取消時停止等待)
+func MeasureLatency(ctx context.Context, transport string, options *LatencyOptions, subscribe LatencySubscribeFunc, publish LatencyPublishFunc) (*report.Result, error) {
+	fmt.Printf("開始測量 %s 的延遲 (次數： %d, 速率： %s)\n", transport, options.Times, FormatRate(options.Rate))
+
+	// 有效位數 3 位 (誤差約 0.1%)
+	histogram := NewHistogram(3)
+
+	receiver := NewMessageReceiver(options.Times, false)
+
+	// 無法解析發送時間的訊息不記錄延遲 (仍然計入收到的數量，避免一直等待)，結束後視為失敗
+	var parseErrorCount int64
+	unsubscribe, err := subscribe(func(data []byte) {
+		intendedTime, err := time.Parse(time.RFC3339Nano, string(data))
+		if err != nil {
+			atomic.AddInt64(&parseErrorCount, 1)
+		} else {
+			histogram.RecordDuration(time.Since(intendedTime))
+		}
+		receiver.Receive(data)
+	})
+	if err != nil {
+		return nil, xerrors.Errorf("訂閱失敗: %w", err)
+	}
+	defer unsubscribe()
+
+	now := time.Now()
+	if err := PublishAtRate(ctx, options.Rate, options.Times, func(intendedTime time.Time) error {
+		return publish([]byte(intendedTime.Format(time.RFC3339Nano)))
+	}); err != nil {
+		return nil, xerrors.Errorf("發布訊息失敗: %w", err)
+	}
+
+	if err := receiver.Wait(ctx); err != nil {
+		return nil, xerrors.Errorf("等待接收訊息失敗: %w", err)
+	}
+	elapsedTime := time.Since(now)
+
+	chartBucketCount := 0
+	if options.ShowChart {
+		chartBucketCount = DefaultChartBucketCount
+	}
+	latency := histogram.LatencyStats(options.Percentiles, chartBucketCount)
+
+	result := report.NewLatencyResult(fmt.Sprintf("%s Latency", transport), options.Times, 0, elapsedTime, latency)
+	result.SetParam("rate", FormatRate(options.Rate))
+	if count := atomic.LoadInt64(&parseErrorCount); count > 0 {
+		result.AddFailure("有 %d 筆訊息無法解析發送時間 (沒有記錄延遲)", count)
+	}
+	return result, nil
+}
+
+// PublishAtRate 以固定速率發布 count 筆訊息 (Open-Loop)
+//
+// 每筆訊息的預定發送時間固定為 開始時間 + i / rate，即使前一筆發送被延誤也不會延後之後的排程，
+// 因此以預定發送時間計算延遲可以避免 Coordinated Omission (rate 為 0 代表不限速，預定發送時間即為當下，ctx 取消時停止)
+func PublishAtRate(ctx context.Context, rate float64, count int, publish func(intendedTime time.Time) error) error {
+	if rate <= 0 {
+		for i := 0; i < count; i++ {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			if err := publish(time.Now()); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	interval := time.Duration(float64(time.Second) / rate)
+	startTime := time.Now()
+	for i := 0; i < count; i++ {
+		intendedTime := startTime.Add(time.Duration(i) * interval)
+		if err := SleepWithContext(ctx, time.Until(intendedTime)); err != nil {
+			return err
+		}
+
+		if err := publish(intendedTime); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// FormatRate 速率的文字 (0 代表不限速)
+func FormatRate(rate float64) string {
+	if rate <= 0 {
+		return "unlimited"
+	}
+	return strconv.FormatFloat(rate, 'f', -1, 64)
+}
+
+// RatesOrUnlimited 沒有設定速率時使用不限速
+func RatesOrUnlimited(rates []float64) []float64 {
+	if len(rates) == 0 {
+		return []float64{0}
+	}
+	return rates
+}
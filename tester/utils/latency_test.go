@@ -0,0 +1,54 @@
+package utils
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestMeasureLatencySkipsMalformedPayload(t *testing.T) {
+	testCases := []struct {
+		name         string
+		malformed    map[int]bool // 第幾筆訊息 (從 0 開始) 改成無法解析的內容
+		wantFailures int
+	}{
+		{name: "all valid", malformed: nil, wantFailures: 0},
+		{name: "one malformed", malformed: map[int]bool{3: true}, wantFailures: 1},
+		{name: "all malformed", malformed: map[int]bool{0: true, 1: true, 2: true, 3: true, 4: true, 5: true, 6: true, 7: true, 8: true, 9: true}, wantFailures: 1},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			var onMessage func(data []byte)
+			subscribe := func(callback func(data []byte)) (func(), error) {
+				onMessage = callback
+				return func() {}, nil
+			}
+
+			sent := 0
+			publish := func(data []byte) error {
+				if testCase.malformed[sent] {
+					data = []byte("not a timestamp")
+				}
+				sent++
+				onMessage(data)
+				return nil
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			result, err := MeasureLatency(ctx, "Test", &LatencyOptions{Times: 10}, subscribe, publish)
+			if err != nil {
+				t.Fatalf("測量延遲失敗: %+v", err)
+			}
+
+			if len(result.Failures) != testCase.wantFailures {
+				t.Errorf("失敗原因為 %v，預期有 %d 個", result.Failures, testCase.wantFailures)
+			}
+			// 無法解析的訊息不應該記錄延遲 (零值的時間會變成約 2000 年的延遲)
+			if result.Latency.Max > time.Second {
+				t.Errorf("最大延遲為 %v，不應該記錄無法解析的訊息", result.Latency.Max)
+			}
+		})
+	}
+}
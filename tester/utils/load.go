@@ -0,0 +1,444 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/stan.go"
+	"golang.org/x/xerrors"
+
+	"github.com/marco79423/nats-jetstream-test/config"
+	"github.com/marco79423/nats-jetstream-test/report"
+)
+
+// LoadPublisher 壓測用的發布者 (每個發布者各自使用一條連線)
+type LoadPublisher interface {
+	Publish(message []byte) error
+	Flush() error
+	Close()
+}
+
+// LoadSubscriber 壓測用的訂閱者 (每個訂閱者各自使用一條連線)
+type LoadSubscriber interface {
+	Close()
+}
+
+// LoadClientFactory 建立壓測用的發布者和訂閱者
+type LoadClientFactory interface {
+	Transport() string
+	NewPublisher(ctx context.Context, clientIdx int) (LoadPublisher, error)
+	NewSubscriber(ctx context.Context, clientIdx int, onMessage func(data []byte)) (LoadSubscriber, error)
+}
+
+// MeasureLoad 測量多個發布者和多個訂閱者同時運作時的效能 (每個發布者發布 messageCount 筆，每個訂閱者都會收到全部的訊息，verify 代表是否驗證訊息，ctx 取消時停止)
+func MeasureLoad(ctx context.Context, factory LoadClientFactory, publisherCount, subscriberCount, messageCount, messageSize int, verify bool) ([]*report.Result, error) {
+	fmt.Printf("開始測量 %s 的壓測效能 (發布者： %d, 訂閱者： %d, 每個發布者的次數： %d, 訊息大小：%d)\n",
+		factory.Transport(),
+		publisherCount,
+		subscriberCount,
+		messageCount,
+		messageSize,
+	)
+
+	// 回傳時停止還在等待的訂閱者 (例如發布失敗時)
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// 先建立訂閱者，確保不會漏掉訊息
+	expectedCount := publisherCount * messageCount
+	receivers := make([]*MessageReceiver, subscriberCount)
+	for i := 0; i < subscriberCount; i++ {
+		var checker *IntegrityChecker
+		if verify {
+			checker = NewIntegrityChecker(publisherCount, 1, messageCount)
+		}
+		receiver := NewMessageReceiverWithChecker(expectedCount, checker)
+		receivers[i] = receiver
+
+		subscriber, err := factory.NewSubscriber(ctx, i, receiver.Receive)
+		if err != nil {
+			return nil, xerrors.Errorf("建立第 %d 個訂閱者失敗: %w", i+1, err)
+		}
+		defer subscriber.Close()
+	}
+
+	publishers := make([]LoadPublisher, publisherCount)
+	for i := range publishers {
+		publisher, err := factory.NewPublisher(ctx, i)
+		if err != nil {
+			return nil, xerrors.Errorf("建立第 %d 個發布者失敗: %w", i+1, err)
+		}
+		defer publisher.Close()
+		publishers[i] = publisher
+	}
+
+	publisherElapsedTimes := make([]time.Duration, publisherCount)
+	publisherErrs := make([]error, publisherCount)
+
+	subscriberWg := sync.WaitGroup{}
+	subscriberWg.Add(subscriberCount)
+	subscriberEndTimes := make([]time.Time, subscriberCount)
+	subscriberErrs := make([]error, subscriberCount)
+
+	publisherWg := sync.WaitGroup{}
+	publisherWg.Add(publisherCount)
+	now := time.Now()
+	for i, receiver := range receivers {
+		go func(clientIdx int, receiver *MessageReceiver) {
+			defer subscriberWg.Done()
+
+			if err := receiver.Wait(ctx); err != nil {
+				subscriberErrs[clientIdx] = xerrors.Errorf("第 %d 個訂閱者等待接收訊息失敗: %w", clientIdx+1, err)
+				return
+			}
+			// 沒有收到全部訊息時不計入最後等待的時間
+			subscriberEndTimes[clientIdx] = time.Now().Add(-receiver.IdleTime())
+		}(i, receiver)
+	}
+
+	for i, publisher := range publishers {
+		go func(clientIdx int, publisher LoadPublisher) {
+			defer publisherWg.Done()
+
+			generate := NewPublisherMessageGenerator(clientIdx, messageSize, verify)
+			startTime := time.Now()
+			for j := 0; j < messageCount; j++ {
+				if err := ctx.Err(); err != nil {
+					publisherErrs[clientIdx] = xerrors.Errorf("第 %d 個發布者發布訊息失敗: %w", clientIdx+1, err)
+					return
+				}
+				if err := publisher.Publish(generate(j + 1)); err != nil {
+					publisherErrs[clientIdx] = xerrors.Errorf("第 %d 個發布者發布訊息失敗: %w", clientIdx+1, err)
+					return
+				}
+			}
+			if err := publisher.Flush(); err != nil {
+				publisherErrs[clientIdx] = xerrors.Errorf("第 %d 個發布者清空緩衝失敗: %w", clientIdx+1, err)
+				return
+			}
+			publisherElapsedTimes[clientIdx] = time.Since(startTime)
+		}(i, publisher)
+	}
+	publisherWg.Wait()
+	publishElapsedTime := time.Since(now)
+
+	for _, err := range publisherErrs {
+		if err != nil {
+			return nil, xerrors.Errorf("測量 %s 的壓測效能失敗: %w", factory.Transport(), err)
+		}
+	}
+
+	subscriberWg.Wait()
+	subscribeElapsedTime := time.Since(now)
+	if subscriberCount > 0 {
+		subscribeElapsedTime = 0
+		for _, endTime := range subscriberEndTimes {
+			if elapsedTime := endTime.Sub(now); elapsedTime > subscribeElapsedTime {
+				subscribeElapsedTime = elapsedTime
+			}
+		}
+	}
+
+	for _, err := range subscriberErrs {
+		if err != nil {
+			return nil, xerrors.Errorf("測量 %s 的壓測效能失敗: %w", factory.Transport(), err)
+		}
+	}
+
+	// 各別客戶端的結果和整體的結果
+	publishOperation := fmt.Sprintf("%s Load Publish", factory.Transport())
+	subscribeOperation := fmt.Sprintf("%s Load Subscribe", factory.Transport())
+
+	var results []*report.Result
+	for clientIdx, elapsedTime := range publisherElapsedTimes {
+		results = append(results, newLoadResult(publishOperation, clientIdx+1, publisherCount, subscriberCount, messageCount, messageSize, elapsedTime))
+	}
+	results = append(results, newLoadResult(publishOperation, 0, publisherCount, subscriberCount, expectedCount, messageSize, publishElapsedTime))
+
+	var totalIntegrity *report.IntegrityStats
+	for clientIdx, endTime := range subscriberEndTimes {
+		result := newLoadResult(subscribeOperation, clientIdx+1, publisherCount, subscriberCount, expectedCount, messageSize, endTime.Sub(now))
+		result.SetIntegrity(receivers[clientIdx].Integrity())
+		totalIntegrity = addIntegrityStats(totalIntegrity, result.Integrity)
+		results = append(results, result)
+	}
+	if subscriberCount > 0 {
+		result := newLoadResult(subscribeOperation, 0, publisherCount, subscriberCount, expectedCount*subscriberCount, messageSize, subscribeElapsedTime)
+		result.SetIntegrity(totalIntegrity)
+		results = append(results, result)
+	}
+
+	return results, nil
+}
+
+// addIntegrityStats 加總各訂閱者的驗證統計 (沒有開啟驗證時為 nil)
+func addIntegrityStats(total, stats *report.IntegrityStats) *report.IntegrityStats {
+	if stats == nil {
+		return total
+	}
+	if total == nil {
+		total = &report.IntegrityStats{}
+	}
+
+	total.Received += stats.Received
+	total.Lost += stats.Lost
+	total.Duplicated += stats.Duplicated
+	total.OutOfOrder += stats.OutOfOrder
+	total.Corrupted += stats.Corrupted
+	return total
+}
+
+// newLoadResult 建立壓測的結果 (client 為 0 代表所有客戶端的整體結果)
+func newLoadResult(operation string, client, publisherCount, subscriberCount, messageCount, messageSize int, elapsedTime time.Duration) *report.Result {
+	result := report.NewThroughputResult(operation, messageCount, messageSize, elapsedTime)
+	result.SetParam("publishers", publisherCount)
+	result.SetParam("subscribers", subscriberCount)
+	if client == 0 {
+		result.SetParam("client", "all")
+	} else {
+		result.SetParam("client", client)
+	}
+	return result
+}
+
+// NewNATSLoadClientFactory 建立 NATS 壓測用的客戶端
+func NewNATSLoadClientFactory(conf *config.Config, name, subject string) LoadClientFactory {
+	return &natsLoadClientFactory{
+		conf:    conf,
+		name:    name,
+		subject: subject,
+	}
+}
+
+type natsLoadClientFactory struct {
+	conf    *config.Config
+	name    string
+	subject string
+}
+
+func (factory *natsLoadClientFactory) Transport() string {
+	return "NATS"
+}
+
+func (factory *natsLoadClientFactory) NewPublisher(ctx context.Context, clientIdx int) (LoadPublisher, error) {
+	natsConn, err := ConnectNATS(factory.conf, fmt.Sprintf("%s-publisher-%d", factory.name, clientIdx))
+	if err != nil {
+		return nil, xerrors.Errorf("取得 NATS 連線失敗: %w", err)
+	}
+
+	return &natsLoadPublisher{
+		natsConn: natsConn,
+		subject:  factory.subject,
+	}, nil
+}
+
+func (factory *natsLoadClientFactory) NewSubscriber(ctx context.Context, clientIdx int, onMessage func(data []byte)) (LoadSubscriber, error) {
+	natsConn, err := ConnectNATS(factory.conf, fmt.Sprintf("%s-subscriber-%d", factory.name, clientIdx))
+	if err != nil {
+		return nil, xerrors.Errorf("取得 NATS 連線失敗: %w", err)
+	}
+
+	if _, err := natsConn.Subscribe(factory.subject, func(msg *nats.Msg) {
+		onMessage(msg.Data)
+	}); err != nil {
+		natsConn.Close()
+		return nil, xerrors.Errorf("訂閱 %s 失敗: %w", factory.subject, err)
+	}
+
+	// 確保 Server 已收到訂閱
+	if err := natsConn.Flush(); err != nil {
+		natsConn.Close()
+		return nil, xerrors.Errorf("訂閱 %s 失敗: %w", factory.subject, err)
+	}
+
+	return &natsLoadSubscriber{
+		natsConn: natsConn,
+	}, nil
+}
+
+type natsLoadPublisher struct {
+	natsConn *nats.Conn
+	subject  string
+}
+
+func (publisher *natsLoadPublisher) Publish(message []byte) error {
+	return publisher.natsConn.Publish(publisher.subject, message)
+}
+
+func (publisher *natsLoadPublisher) Flush() error {
+	return publisher.natsConn.Flush()
+}
+
+func (publisher *natsLoadPublisher) Close() {
+	publisher.natsConn.Close()
+}
+
+type natsLoadSubscriber struct {
+	natsConn *nats.Conn
+}
+
+func (subscriber *natsLoadSubscriber) Close() {
+	subscriber.natsConn.Close()
+}
+
+// NewJetStreamLoadClientFactory 建立 JetStream 壓測用的客戶端 (Stream 需要事先建立)
+func NewJetStreamLoadClientFactory(conf *config.Config, name, subject string) LoadClientFactory {
+	return &jetStreamLoadClientFactory{
+		conf:    conf,
+		name:    name,
+		subject: subject,
+	}
+}
+
+type jetStreamLoadClientFactory struct {
+	conf    *config.Config
+	name    string
+	subject string
+}
+
+func (factory *jetStreamLoadClientFactory) Transport() string {
+	return "JetStream"
+}
+
+func (factory *jetStreamLoadClientFactory) NewPublisher(ctx context.Context, clientIdx int) (LoadPublisher, error) {
+	natsConn, err := ConnectNATS(factory.conf, fmt.Sprintf("%s-publisher-%d", factory.name, clientIdx))
+	if err != nil {
+		return nil, xerrors.Errorf("取得 NATS 連線失敗: %w", err)
+	}
+
+	js, err := natsConn.JetStream()
+	if err != nil {
+		natsConn.Close()
+		return nil, xerrors.Errorf("取得 JetStream 的 Context 失敗: %w", err)
+	}
+
+	return &jetStreamLoadPublisher{
+		natsConn: natsConn,
+		js:       js,
+		subject:  factory.subject,
+	}, nil
+}
+
+func (factory *jetStreamLoadClientFactory) NewSubscriber(ctx context.Context, clientIdx int, onMessage func(data []byte)) (LoadSubscriber, error) {
+	natsConn, err := ConnectNATS(factory.conf, fmt.Sprintf("%s-subscriber-%d", factory.name, clientIdx))
+	if err != nil {
+		return nil, xerrors.Errorf("取得 NATS 連線失敗: %w", err)
+	}
+
+	js, err := natsConn.JetStream()
+	if err != nil {
+		natsConn.Close()
+		return nil, xerrors.Errorf("取得 JetStream 的 Context 失敗: %w", err)
+	}
+
+	if _, err := js.Subscribe(factory.subject, func(msg *nats.Msg) {
+		onMessage(msg.Data)
+	}); err != nil {
+		natsConn.Close()
+		return nil, xerrors.Errorf("訂閱 %s 失敗: %w", factory.subject, err)
+	}
+
+	return &natsLoadSubscriber{
+		natsConn: natsConn,
+	}, nil
+}
+
+type jetStreamLoadPublisher struct {
+	natsConn *nats.Conn
+	js       nats.JetStreamContext
+	subject  string
+}
+
+func (publisher *jetStreamLoadPublisher) Publish(message []byte) error {
+	_, err := publisher.js.Publish(publisher.subject, message)
+	return err
+}
+
+func (publisher *jetStreamLoadPublisher) Flush() error {
+	// 同步發布，每筆都已收到 Ack
+	return nil
+}
+
+func (publisher *jetStreamLoadPublisher) Close() {
+	publisher.natsConn.Close()
+}
+
+// NewStreamingLoadClientFactory 建立 Streaming 壓測用的客戶端
+func NewStreamingLoadClientFactory(conf *config.Config, name, channel string) LoadClientFactory {
+	return &streamingLoadClientFactory{
+		conf:    conf,
+		name:    name,
+		channel: channel,
+	}
+}
+
+type streamingLoadClientFactory struct {
+	conf    *config.Config
+	name    string
+	channel string
+}
+
+func (factory *streamingLoadClientFactory) Transport() string {
+	return "Streaming"
+}
+
+func (factory *streamingLoadClientFactory) NewPublisher(ctx context.Context, clientIdx int) (LoadPublisher, error) {
+	clientID := fmt.Sprintf("%s-publisher-%d", factory.conf.NATSStreaming.ClientID, clientIdx)
+	stanConn, err := ConnectSTANWithClientID(ctx, factory.conf, fmt.Sprintf("%s-publisher-%d", factory.name, clientIdx), clientID)
+	if err != nil {
+		return nil, xerrors.Errorf("取得 STAN 連線失敗: %w", err)
+	}
+
+	return &streamingLoadPublisher{
+		stanConn: stanConn,
+		channel:  factory.channel,
+	}, nil
+}
+
+func (factory *streamingLoadClientFactory) NewSubscriber(ctx context.Context, clientIdx int, onMessage func(data []byte)) (LoadSubscriber, error) {
+	clientID := fmt.Sprintf("%s-subscriber-%d", factory.conf.NATSStreaming.ClientID, clientIdx)
+	stanConn, err := ConnectSTANWithClientID(ctx, factory.conf, fmt.Sprintf("%s-subscriber-%d", factory.name, clientIdx), clientID)
+	if err != nil {
+		return nil, xerrors.Errorf("取得 STAN 連線失敗: %w", err)
+	}
+
+	if _, err := stanConn.Subscribe(factory.channel, func(msg *stan.Msg) {
+		onMessage(msg.Data)
+	}); err != nil {
+		stanConn.Close()
+		return nil, xerrors.Errorf("訂閱 %s 失敗: %w", factory.channel, err)
+	}
+
+	return &streamingLoadSubscriber{
+		stanConn: stanConn,
+	}, nil
+}
+
+type streamingLoadPublisher struct {
+	stanConn stan.Conn
+	channel  string
+}
+
+func (publisher *streamingLoadPublisher) Publish(message []byte) error {
+	return publisher.stanConn.Publish(publisher.channel, message)
+}
+
+func (publisher *streamingLoadPublisher) Flush() error {
+	// 同步發布，每筆都已收到 Ack
+	return nil
+}
+
+func (publisher *streamingLoadPublisher) Close() {
+	publisher.stanConn.Close()
+}
+
+type streamingLoadSubscriber struct {
+	stanConn stan.Conn
+}
+
+func (subscriber *streamingLoadSubscriber) Close() {
+	subscriber.stanConn.Close()
+}
@@ -0,0 +1,18 @@
+package utils
+
+import (
+	"time"
+)
+
+// DurationMetric 將時間轉為測量值 (毫秒)
+func DurationMetric(duration time.Duration) float64 {
+	return float64(duration) / float64(time.Millisecond)
+}
+
+// BoolMetric 將布林值轉為測量值 (true 為 1，false 為 0)
+func BoolMetric(value bool) float64 {
+	if value {
+		return 1
+	}
+	return 0
+}
@@ -1,19 +1,32 @@
 package utils
 
 import (
+	"context"
 	"fmt"
-	"sync"
 	"time"
 
 	"github.com/nats-io/nats.go"
 	"golang.org/x/xerrors"
+
+	"github.com/marco79423/nats-jetstream-test/config"
+	"github.com/marco79423/nats-jetstream-test/report"
 )
 
+// RequestTimeout 等待回覆的時間上限
+const RequestTimeout = 5 * time.Second
+
 // PublishNATSMessagesWithSize 發布大量訊息 (Subject, 數量)
-func PublishNATSMessagesWithSize(natsConn *nats.Conn, subject string, times, messageSize int) error {
-	message := GenerateRandomString(messageSize)
+func PublishNATSMessagesWithSize(ctx context.Context, natsConn *nats.Conn, subject string, times, messageSize int) error {
+	return PublishNATSMessages(ctx, natsConn, subject, times, NewMessageGenerator(messageSize, false))
+}
+
+// PublishNATSMessages 發布大量訊息 (訊息由 generate 產生，ctx 取消時停止)
+func PublishNATSMessages(ctx context.Context, natsConn *nats.Conn, subject string, times int, generate MessageGenerator) error {
 	for i := 0; i < times; i++ {
-		err := natsConn.Publish(subject, []byte(message))
+		if err := ctx.Err(); err != nil {
+			return xerrors.Errorf("發布 %s 失敗: %w", subject, err)
+		}
+		err := natsConn.Publish(subject, generate(i+1))
 		if err != nil {
 			return xerrors.Errorf("發布 %s 失敗: %w", subject, err)
 		}
@@ -23,49 +36,125 @@ func PublishNATSMessagesWithSize(natsConn *nats.Conn, subject string, times, mes
 }
 
 // MeasureNATSPublishMsgTime 測試 NATS 發布效能
-func MeasureNATSPublishMsgTime(natsConn *nats.Conn, subject string, times, messageSize int) error {
+func MeasureNATSPublishMsgTime(ctx context.Context, natsConn *nats.Conn, subject string, times, messageSize int) (*report.Result, error) {
 	fmt.Printf("開始測量 NATS 的發布效能 (次數： %d, 訊息大小：%d)\n", times, messageSize)
 
 	now := time.Now()
-	if err := PublishNATSMessagesWithSize(natsConn, subject, times, messageSize); err != nil {
-		return xerrors.Errorf("測量 NATS 發布效能失敗: %w", err)
+	if err := PublishNATSMessagesWithSize(ctx, natsConn, subject, times, messageSize); err != nil {
+		return nil, xerrors.Errorf("測量 NATS 發布效能失敗: %w", err)
 	}
 
 	elapsedTime := time.Since(now)
-	fmt.Printf("全部 %d 筆發布花費時間 %v (訊息大小： %v, 每筆平均花費 %v)\n",
-		times,
-		elapsedTime,
-		messageSize,
-		elapsedTime/time.Duration(times),
-	)
-	return nil
+	return report.NewThroughputResult("NATS Publish", times, messageSize, elapsedTime), nil
 }
 
-// MeasureNATSSubscribeTime 測試 NATS 訂閱效能
-func MeasureNATSSubscribeTime(natsConn *nats.Conn, subject string, messageCount, messageSize int) error {
+// MeasureNATSSubscribeTime 測試 NATS 訂閱效能 (verify 代表是否驗證訊息)
+func MeasureNATSSubscribeTime(ctx context.Context, natsConn *nats.Conn, subject string, messageCount, messageSize int, verify bool) (*report.Result, error) {
 	fmt.Printf("開始測量 NATS 的接收效能 (次數： %d, 訊息大小：%d)\n", messageCount, messageSize)
 
-	if err := PublishNATSMessagesWithSize(natsConn, subject, messageCount, messageSize); err != nil {
-		return xerrors.Errorf("發布大量訊息失敗: %w", err)
+	receiver := NewMessageReceiver(messageCount, verify)
+
+	// NATS 不會保存訊息，所以需要先訂閱再發布
+	sub, err := natsConn.Subscribe(subject, func(msg *nats.Msg) {
+		// fmt.Printf("Received a NATS message: %s\n", string(msg.Data))
+		receiver.Receive(msg.Data)
+	})
+	if err != nil {
+		return nil, xerrors.Errorf("訂閱 %s 失敗: %w", subject, err)
 	}
+	defer sub.Unsubscribe()
 
-	wg := sync.WaitGroup{}
-	wg.Add(messageCount)
+	// 確保 Server 已收到訂閱
+	if err := natsConn.Flush(); err != nil {
+		return nil, xerrors.Errorf("訂閱 %s 失敗: %w", subject, err)
+	}
 
 	now := time.Now()
-	if _, err := natsConn.Subscribe(subject, func(msg *nats.Msg) {
-		// fmt.Printf("Received a NATS message: %s\n", string(msg.Data))
-		wg.Done()
-	}); err != nil {
-		return xerrors.Errorf("訂閱 %s 失敗: %w", subject, err)
+	if err := PublishNATSMessages(ctx, natsConn, subject, messageCount, NewMessageGenerator(messageSize, verify)); err != nil {
+		return nil, xerrors.Errorf("發布大量訊息失敗: %w", err)
+	}
+	if err := receiver.Wait(ctx); err != nil {
+		return nil, xerrors.Errorf("等待接收訊息失敗: %w", err)
+	}
+	// 沒有收到全部訊息時不計入最後等待的時間
+	elapsedTime := time.Since(now) - receiver.IdleTime()
+
+	result := report.NewThroughputResult("NATS Subscribe", messageCount, messageSize, elapsedTime)
+	result.SetIntegrity(receiver.Integrity())
+	return result, nil
+}
+
+// StartNATSResponders 啟動回覆請求的 Responder (每個 Responder 各自使用一條連線，queueGroup 為空代表不使用 Queue Group)，回傳停止的函式
+func StartNATSResponders(conf *config.Config, name, subject, queueGroup string, responderCount int) (func(), error) {
+	var natsConns []*nats.Conn
+	stop := func() {
+		for _, natsConn := range natsConns {
+			natsConn.Close()
+		}
+	}
+
+	for i := 0; i < responderCount; i++ {
+		natsConn, err := ConnectNATS(conf, fmt.Sprintf("%s-responder-%d", name, i))
+		if err != nil {
+			stop()
+			return nil, xerrors.Errorf("取得 NATS 連線失敗: %w", err)
+		}
+		natsConns = append(natsConns, natsConn)
+
+		// 原封不動回覆收到的訊息
+		if _, err := natsConn.QueueSubscribe(subject, queueGroup, func(msg *nats.Msg) {
+			_ = msg.Respond(msg.Data)
+		}); err != nil {
+			stop()
+			return nil, xerrors.Errorf("訂閱 %s 失敗: %w", subject, err)
+		}
+
+		// 確保 Server 已收到訂閱
+		if err := natsConn.Flush(); err != nil {
+			stop()
+			return nil, xerrors.Errorf("訂閱 %s 失敗: %w", subject, err)
+		}
+	}
+
+	return stop, nil
+}
+
+// MeasureNATSRequestReplyTime 測試 NATS Request-Reply 的效能和來回延遲 (需要先啟動 Responder)
+func MeasureNATSRequestReplyTime(ctx context.Context, natsConn *nats.Conn, subject string, messageCount, messageSize int, percentiles []float64, showChart bool) (*report.Result, error) {
+	fmt.Printf("開始測量 NATS 的 Request-Reply 效能 (次數： %d, 訊息大小：%d)\n", messageCount, messageSize)
+
+	// 有效位數 3 位 (誤差約 0.1%)
+	histogram := NewHistogram(3)
+
+	message := []byte(GenerateRandomString(messageSize))
+	now := time.Now()
+	for i := 0; i < messageCount; i++ {
+		if err := ctx.Err(); err != nil {
+			return nil, xerrors.Errorf("測量 NATS 的 Request-Reply 效能失敗: %w", err)
+		}
+
+		startTime := time.Now()
+		if err := requestWithTimeout(ctx, natsConn, subject, message); err != nil {
+			return nil, xerrors.Errorf("發送請求 %s 失敗: %w", subject, err)
+		}
+		histogram.RecordDuration(time.Since(startTime))
 	}
-	wg.Wait()
 	elapsedTime := time.Since(now)
 
-	fmt.Printf("全部 %d 筆接收花費時間 %v (每筆平均花費 %v)\n",
-		messageCount,
-		elapsedTime,
-		elapsedTime/time.Duration(messageCount),
-	)
-	return nil
+	chartBucketCount := 0
+	if showChart {
+		chartBucketCount = DefaultChartBucketCount
+	}
+	latency := histogram.LatencyStats(percentiles, chartBucketCount)
+
+	return report.NewLatencyResult("NATS Request-Reply", messageCount, messageSize, elapsedTime, latency), nil
+}
+
+// requestWithTimeout 發送請求並等待回覆 (最多等待 RequestTimeout，ctx 取消時立即停止)
+func requestWithTimeout(ctx context.Context, natsConn *nats.Conn, subject string, message []byte) error {
+	ctx, cancel := context.WithTimeout(ctx, RequestTimeout)
+	defer cancel()
+
+	_, err := natsConn.RequestWithContext(ctx, subject, message)
+	return err
 }
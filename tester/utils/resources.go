@@ -0,0 +1,65 @@
+package utils
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Resources 記錄測試過程中建立的資源，測試中斷時可以用來清除 (刪除 Stream 時 Consumer 也會一併刪除)
+type Resources struct {
+	mu sync.Mutex
+
+	jetStreamStreams []string
+}
+
+type resourcesKey struct{}
+
+// WithResources 建立會記錄資源的 Context
+func WithResources(ctx context.Context) (context.Context, *Resources) {
+	resources := &Resources{}
+	return context.WithValue(ctx, resourcesKey{}, resources), resources
+}
+
+// TrackJetStreamStream 記錄建立的 Stream (ctx 沒有透過 WithResources 建立時不會記錄)
+func TrackJetStreamStream(ctx context.Context, streamName string) {
+	resources, ok := ctx.Value(resourcesKey{}).(*Resources)
+	if !ok {
+		return
+	}
+
+	resources.mu.Lock()
+	defer resources.mu.Unlock()
+
+	for _, name := range resources.jetStreamStreams {
+		if name == streamName {
+			return
+		}
+	}
+	resources.jetStreamStreams = append(resources.jetStreamStreams, streamName)
+}
+
+// JetStreamStreams 取得建立過的 Stream
+func (resources *Resources) JetStreamStreams() []string {
+	resources.mu.Lock()
+	defer resources.mu.Unlock()
+
+	return append([]string{}, resources.jetStreamStreams...)
+}
+
+// SleepWithContext 等待一段時間，ctx 取消時提前回傳錯誤
+func SleepWithContext(ctx context.Context, duration time.Duration) error {
+	if duration <= 0 {
+		return ctx.Err()
+	}
+
+	timer := time.NewTimer(duration)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
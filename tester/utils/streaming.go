@@ -1,20 +1,29 @@
 package utils
 
 import (
+	"context"
 	"fmt"
-	"sync"
 	"time"
 
 	"github.com/nats-io/stan.go"
 	"github.com/nats-io/stan.go/pb"
 	"golang.org/x/xerrors"
+
+	"github.com/marco79423/nats-jetstream-test/report"
 )
 
 // PublishStreamingMessagesWithSize 發布大量訊息 (Subject, 數量)
-func PublishStreamingMessagesWithSize(stanConn stan.Conn, channel string, times, messageSize int) error {
-	message := GenerateRandomString(messageSize)
+func PublishStreamingMessagesWithSize(ctx context.Context, stanConn stan.Conn, channel string, times, messageSize int) error {
+	return PublishStreamingMessages(ctx, stanConn, channel, times, NewMessageGenerator(messageSize, false))
+}
+
+// PublishStreamingMessages 發布大量訊息 (訊息由 generate 產生，ctx 取消時停止)
+func PublishStreamingMessages(ctx context.Context, stanConn stan.Conn, channel string, times int, generate MessageGenerator) error {
 	for i := 0; i < times; i++ {
-		err := stanConn.Publish(channel, []byte(message))
+		if err := ctx.Err(); err != nil {
+			return xerrors.Errorf("發布 %s 失敗: %w", channel, err)
+		}
+		err := stanConn.Publish(channel, generate(i+1))
 		if err != nil {
 			return xerrors.Errorf("發布 %s 失敗: %w", channel, err)
 		}
@@ -24,49 +33,44 @@ func PublishStreamingMessagesWithSize(stanConn stan.Conn, channel string, times,
 }
 
 // MeasureStreamingPublishTime 測試 Streaming 發布效能
-func  MeasureStreamingPublishTime(stanConn stan.Conn, channel string, times, messageSize int) error {
+func MeasureStreamingPublishTime(ctx context.Context, stanConn stan.Conn, channel string, times, messageSize int) (*report.Result, error) {
 	fmt.Printf("開始測量 Streaming 的發布效能 (次數： %d, 訊息大小：%d)\n", times, messageSize)
 
 	now := time.Now()
-	if err := PublishStreamingMessagesWithSize(stanConn, channel, times, messageSize); err != nil {
-		return xerrors.Errorf("測量 Streaming 發布效能失敗: %w", err)
+	if err := PublishStreamingMessagesWithSize(ctx, stanConn, channel, times, messageSize); err != nil {
+		return nil, xerrors.Errorf("測量 Streaming 發布效能失敗: %w", err)
 	}
 
 	elapsedTime := time.Since(now)
-	fmt.Printf("全部 %d 筆發布花費時間 %v (訊息大小： %v, 每筆平均花費 %v)\n",
-		times,
-		elapsedTime,
-		messageSize,
-		elapsedTime/time.Duration(times),
-	)
-	return nil
+	return report.NewThroughputResult("Streaming Publish", times, messageSize, elapsedTime), nil
 }
 
-// MeasureStreamingSubscribeTime 測試 Streaming 訂閱效能
-func MeasureStreamingSubscribeTime(stanConn stan.Conn, channel string, messageCount, messageSize int) error {
+// MeasureStreamingSubscribeTime 測試 Streaming 訂閱效能 (verify 代表是否驗證訊息)
+func MeasureStreamingSubscribeTime(ctx context.Context, stanConn stan.Conn, channel string, messageCount, messageSize int, verify bool) (*report.Result, error) {
 	fmt.Printf("開始測量 Streaming 的接收效能 (次數： %d, 訊息大小：%d)\n", messageCount, messageSize)
 
-	if err := PublishStreamingMessagesWithSize(stanConn, channel, messageCount, messageSize); err != nil {
-		return xerrors.Errorf("發布大量訊息失敗: %w", err)
+	if err := PublishStreamingMessages(ctx, stanConn, channel, messageCount, NewMessageGenerator(messageSize, verify)); err != nil {
+		return nil, xerrors.Errorf("發布大量訊息失敗: %w", err)
 	}
 
-	wg := sync.WaitGroup{}
-	wg.Add(messageCount)
+	receiver := NewMessageReceiver(messageCount, verify)
 
 	now := time.Now()
-	if _, err := stanConn.Subscribe(channel, func(msg *stan.Msg) {
+	sub, err := stanConn.Subscribe(channel, func(msg *stan.Msg) {
 		// fmt.Printf("Received a Streaming message: %s\n", string(msg.Data))
-		wg.Done()
-	}, stan.StartAt(pb.StartPosition_First)); err != nil {
-		return xerrors.Errorf("訂閱 %s 失敗: %w", channel, err)
+		receiver.Receive(msg.Data)
+	}, stan.StartAt(pb.StartPosition_First))
+	if err != nil {
+		return nil, xerrors.Errorf("訂閱 %s 失敗: %w", channel, err)
 	}
-	wg.Wait()
-	elapsedTime := time.Since(now)
+	defer sub.Unsubscribe()
+	if err := receiver.Wait(ctx); err != nil {
+		return nil, xerrors.Errorf("等待接收訊息失敗: %w", err)
+	}
+	// 沒有收到全部訊息時不計入最後等待的時間
+	elapsedTime := time.Since(now) - receiver.IdleTime()
 
-	fmt.Printf("全部 %d 筆接收花費時間 %v (每筆平均花費 %v)\n",
-		messageCount,
-		elapsedTime,
-		elapsedTime/time.Duration(messageCount),
-	)
-	return nil
+	result := report.NewThroughputResult("Streaming Subscribe", messageCount, messageSize, elapsedTime)
+	result.SetIntegrity(receiver.Integrity())
+	return result, nil
 }